@@ -2,6 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"mcctl/internal/monitoring"
 	"mcctl/internal/server"
 
 	"github.com/manifoldco/promptui"
@@ -11,7 +16,42 @@ import (
 var addCmd = &cobra.Command{
 	Use:   "add",
 	Short: "新しいMinecraftサーバーを追加します",
+	Long: `新しいMinecraftサーバーを対話形式で追加します。
+
+--from-mrpack を指定すると、Modrinth のモッドパック (.mrpack) からサーバーを作成します。
+パックの dependencies からサーバータイプと Minecraft バージョンを決め、
+サーバー側で必要なファイルをダウンロードしてハッシュを検証し、overrides と server-overrides を適用します。
+
+--overlay を指定すると (省略時は mcctl.yaml の defaults.overlays)、minecraft/template/overlays/<name> のファイルをテンプレートの上に順に重ねます。
+server.properties などの .properties ファイルはキー単位で重なります。
+
+--from-curseforge を指定すると、CurseForge のモッドパック (manifest.json を含む zip) からサーバーを作成します。
+files のプロジェクト ID とファイル ID は、--curseforge-dir (既定はユーザーキャッシュ配下の mcctl/curseforge) の
+<projectID>/<fileID>/file.json と jar から解決します。`,
 	Run: func(cmd *cobra.Command, args []string) {
+		overlays, err := overlaysFromFlags(cmd)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+
+		mrpackPath, _ := cmd.Flags().GetString("from-mrpack")
+		curseforgePath, _ := cmd.Flags().GetString("from-curseforge")
+		if dryRun && (mrpackPath != "" || curseforgePath != "") {
+			// モッドパックはダウンロードしたファイルをディスク上で展開してから移動するため、記録できない
+			fmt.Println("--from-mrpack と --from-curseforge は --dry-run に対応していません")
+			return
+		}
+
+		if mrpackPath != "" {
+			addFromMrpack(cmd, userPath(mrpackPath), overlays)
+			return
+		}
+		if curseforgePath != "" {
+			addFromCurseforge(cmd, userPath(curseforgePath), overlays)
+			return
+		}
+
 		// サーバー名入力
 		prompt := promptui.Prompt{Label: "サーバー名"}
 		name, err := prompt.Run()
@@ -21,10 +61,11 @@ var addCmd = &cobra.Command{
 		}
 
 		// バージョン選択
-		versions := []string{"vanilla", "forge", "fabric"}
+		versions := server.GetRegisteredTypes()
 		versionPrompt := promptui.Select{
-			Label: "サーバーバージョンを選択してください",
-			Items: versions,
+			Label:     "サーバーバージョンを選択してください",
+			Items:     versions,
+			CursorPos: max(slices.Index(versions, cfg.Defaults.Type), 0),
 		}
 		_, version, err := versionPrompt.Run()
 		if err != nil {
@@ -33,47 +74,103 @@ var addCmd = &cobra.Command{
 		}
 
 		// アドレス入力
-		addressPrompt := promptui.Prompt{Label: "サーバーのアドレス（例: myserver:25565）"}
+		addressPrompt := promptui.Prompt{Label: "サーバーのアドレス（例: myserver:25565）", Default: defaultAddress(name)}
 		address, err := addressPrompt.Run()
 		if err != nil {
 			fmt.Println("キャンセルされました")
 			return
 		}
 
-		// 管理用JSONファイルに保存
-		s := server.Server{Name: name, Version: version, Address: address}
-		err = server.SaveServerConfig("minecraft/servers.json", s)
-		if err != nil {
-			fmt.Printf("サーバーの保存に失敗しました: %v\n", err)
+		s := server.Server{Name: name, Type: version, Address: address}
+		if err := addServer(s, server.ServiceOptions{}, overlays); err != nil {
+			fmt.Printf("%v\n", err)
 			return
 		}
 
-		// サーバーディレクトリとテンプレートファイルを作成
-		err = server.CreateServerDirectory(name, version)
-		if err != nil {
-			fmt.Printf("サーバーディレクトリの作成に失敗しました: %v\n", err)
-			return
-		}
+		fmt.Printf("サーバー %s (タイプ: %s, アドレス: %s) を追加しました\n", name, version, address)
+		fmt.Printf("%sにサービス '%s' を追加しました\n", server.ComposePath(), name)
+	},
+}
 
-		// velocity.tomlに追加
-		err = server.AddVelocityServerConfig("velocity/velocity.toml", name, address)
-		if err != nil {
-			fmt.Printf("Velocity設定更新失敗: %v\n", err)
-			return
-		}
+// addServer は、サーバーを管理用JSON、サーバーディレクトリ、mcctl.yaml の各プロキシの velocity.toml、
+// minecraft/docker-compose.yml、監視設定に登録します。
+// overlays はサーバーディレクトリを作成した後に順に重ねます。
+func addServer(s server.Server, opts server.ServiceOptions, overlays []string) error {
+	// サービスの環境変数から Minecraft のバージョンやメモリを記録する
+	env, err := server.ServiceEnvironment(s.Type, opts)
+	if err != nil {
+		return err
+	}
+	s.SetRuntime(env)
+	now := time.Now().UTC().Truncate(time.Second)
+	s.CreatedAt = &now
+	for _, proxy := range cfg.Proxies {
+		s.Proxies = append(s.Proxies, proxy.Name)
+	}
+
+	// 管理用JSONファイルに保存
+	err = server.SaveServerConfig(cfg.Paths.Servers, s)
+	if err != nil {
+		return fmt.Errorf("サーバーの保存に失敗しました: %w", err)
+	}
 
-		// minecraft/docker-compose.ymlに追加
-		err = server.AddDockerComposeService("minecraft/docker-compose.yml", name, version)
+	// サーバーディレクトリとテンプレートファイルを作成
+	err = server.CreateServerDirectory(s.Name, s.Type, overlays...)
+	if err != nil {
+		return fmt.Errorf("サーバーディレクトリの作成に失敗しました: %w", err)
+	}
+
+	// velocity.tomlに追加
+	for _, proxy := range cfg.Proxies {
+		err = server.AddVelocityServerConfig(proxy.Config, s.Name, s.Address, cfg.Domain)
 		if err != nil {
-			fmt.Printf("Docker Compose設定更新失敗: %v\n", err)
-			return
+			return fmt.Errorf("Velocity設定更新失敗 (%s): %w", proxy.Name, err)
 		}
+	}
 
-		fmt.Printf("サーバー %s (タイプ: %s, アドレス: %s) を追加しました\n", name, version, address)
-		fmt.Printf("minecraft/docker-compose.ymlにサービス '%s' を追加しました\n", name)
-	},
+	// minecraft/docker-compose.ymlに追加
+	err = server.AddDockerComposeServiceWithOptions(server.ComposePath(), s.Name, s.Type, opts)
+	if err != nil {
+		return fmt.Errorf("Docker Compose設定更新失敗: %w", err)
+	}
+
+	// Prometheus と mc-monitor の監視対象を更新
+	err = monitoring.Sync(monitoringOptions(""))
+	if err != nil {
+		return fmt.Errorf("監視設定更新失敗: %w", err)
+	}
+	return nil
+}
+
+// defaultAddress は、アドレスを省略したときの NAME:PORT です。
+func defaultAddress(name string) string {
+	return fmt.Sprintf("%s:%d", name, cfg.Defaults.Port)
+}
+
+// overlaysFromFlags は --overlay で指定されたオーバーレイを返します。
+// 指定がない場合は mcctl.yaml の defaults.overlays を使います。
+// 存在しないオーバーレイがあれば、何も変更する前にエラーにします。
+func overlaysFromFlags(cmd *cobra.Command) ([]string, error) {
+	overlays, _ := cmd.Flags().GetStringSlice("overlay")
+	if !cmd.Flags().Changed("overlay") {
+		overlays = cfg.Defaults.Overlays
+	}
+	available := server.ListOverlays()
+	for _, o := range overlays {
+		if !slices.Contains(available, o) {
+			return nil, fmt.Errorf("オーバーレイ %s が見つかりません (利用できるオーバーレイ: %s)", o, strings.Join(available, ", "))
+		}
+	}
+	return overlays, nil
 }
 
 func init() {
 	rootCmd.AddCommand(addCmd)
+
+	addCmd.Flags().String("from-mrpack", "", "Modrinth のモッドパック (.mrpack) からサーバーを作成します")
+	addCmd.Flags().String("from-curseforge", "", "CurseForge のモッドパック (zip) からサーバーを作成します")
+	addCmd.Flags().String("curseforge-dir", "", "CurseForge のファイルを解決するディレクトリ")
+	addCmd.Flags().StringSlice("overlay", nil, "サーバーディレクトリに重ねるオーバーレイ (例: hardcore,creative)")
+	addCmd.Flags().String("name", "", "サーバー名 (パックから作成する場合)")
+	addCmd.Flags().String("address", "", "サーバーのアドレス (パックから作成する場合、既定は NAME:<mcctl.yaml の defaults.port>)")
 }
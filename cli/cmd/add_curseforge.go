@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"mcctl/internal/curseforge"
+	"mcctl/internal/mods"
+	"mcctl/internal/server"
+
+	"github.com/spf13/cobra"
+)
+
+// curseforgeDir は、CurseForge のファイルを解決するローカルディレクトリです。
+// MCCTL_CURSEFORGE_DIR で上書きできます。
+func curseforgeDir() string {
+	return cacheDir("MCCTL_CURSEFORGE_DIR", "curseforge")
+}
+
+// addFromCurseforge は CurseForge のモッドパックからサーバーを作成します。
+// ファイルの取得と検証が終わるまで、管理用JSONや docker-compose.yml は変更しません。
+func addFromCurseforge(cmd *cobra.Command, packPath string, overlays []string) {
+	pack, err := curseforge.Open(packPath)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	defer pack.Close()
+
+	serverType, env, err := pack.Runtime()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	m := pack.Manifest
+	fmt.Printf("%s %s (Minecraft %s, %s)\n", m.Name, m.Version, m.Minecraft.Version, serverType)
+
+	name, address, err := importTarget(cmd, m.Name)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	staging, err := os.MkdirTemp(server.MinecraftDir, ".curseforge-")
+	if err != nil {
+		fmt.Printf("作業ディレクトリの作成に失敗しました: %v\n", err)
+		return
+	}
+	defer os.RemoveAll(staging)
+
+	resolverDir, _ := cmd.Flags().GetString("curseforge-dir")
+	if resolverDir == "" {
+		resolverDir = curseforgeDir()
+	} else {
+		resolverDir = userPath(resolverDir)
+	}
+	result, err := pack.Install(staging, &curseforge.FixtureResolver{Dir: resolverDir})
+	if err != nil {
+		fmt.Printf("パックの展開に失敗しました: %v\n", err)
+		return
+	}
+	for _, ref := range result.Skipped {
+		fmt.Printf("required でないためスキップしました: %d/%d\n", ref.ProjectID, ref.FileID)
+	}
+
+	var lock mods.Lock
+	for _, f := range result.Mods {
+		lock.Put(mods.Entry{
+			ID:       strconv.Itoa(f.Ref.ProjectID),
+			Version:  strconv.Itoa(f.Ref.FileID),
+			Filename: f.Filename,
+			SHA512:   f.SHA512,
+			Source:   "curseforge",
+			URL:      f.URL,
+		})
+	}
+	if len(lock.Mods) > 0 {
+		if err := lock.Save(staging); err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+	}
+
+	s := server.Server{Name: name, Type: serverType, Address: address}
+	if err := addImportedServer(s, env, staging, overlays); err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	fmt.Printf("%d 個の MOD と %d 個の overrides を展開しました\n", len(result.Mods), len(result.Overrides))
+	fmt.Printf("サーバー %s (タイプ: %s, アドレス: %s) を追加しました\n", name, serverType, address)
+	fmt.Printf("%sにサービス '%s' を追加しました\n", server.ComposePath(), name)
+}
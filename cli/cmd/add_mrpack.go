@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mcctl/internal/mods"
+	"mcctl/internal/mrpack"
+	"mcctl/internal/server"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+// addFromMrpack は .mrpack からサーバーを作成します。
+// ファイルの取得と検証が終わるまで、管理用JSONや docker-compose.yml は変更しません。
+func addFromMrpack(cmd *cobra.Command, packPath string, overlays []string) {
+	pack, err := mrpack.Open(packPath)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	defer pack.Close()
+
+	serverType, env, err := pack.Runtime()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	fmt.Printf("%s %s (Minecraft %s, %s)\n", pack.Index.Name, pack.Index.VersionID, pack.MinecraftVersion(), serverType)
+
+	name, address, err := importTarget(cmd, pack.Index.Name)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	staging, err := os.MkdirTemp(server.MinecraftDir, ".mrpack-")
+	if err != nil {
+		fmt.Printf("作業ディレクトリの作成に失敗しました: %v\n", err)
+		return
+	}
+	defer os.RemoveAll(staging)
+
+	result, err := pack.Install(staging, &mrpack.DefaultFetcher{})
+	if err != nil {
+		fmt.Printf("パックの展開に失敗しました: %v\n", err)
+		return
+	}
+	for _, p := range result.Skipped {
+		fmt.Printf("サーバーでは使わないためスキップしました: %s\n", p)
+	}
+
+	var lock mods.Lock
+	for _, f := range result.Files {
+		dir, file := filepath.Split(filepath.ToSlash(f.Path))
+		if dir != "mods/" || !strings.HasSuffix(file, ".jar") {
+			continue
+		}
+		lock.Put(mods.Entry{
+			ID:       strings.TrimSuffix(file, ".jar"),
+			Filename: file,
+			SHA512:   f.SHA512,
+			Source:   "mrpack",
+			URL:      f.URL,
+		})
+	}
+	if len(lock.Mods) > 0 {
+		if err := lock.Save(staging); err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+	}
+
+	s := server.Server{Name: name, Type: serverType, Address: address}
+	if err := addImportedServer(s, env, staging, overlays); err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	fmt.Printf("%d 個のファイルと %d 個の overrides を展開しました\n", len(result.Files), len(result.Overrides))
+	fmt.Printf("サーバー %s (タイプ: %s, アドレス: %s) を追加しました\n", name, serverType, address)
+	fmt.Printf("%sにサービス '%s' を追加しました\n", server.ComposePath(), name)
+}
+
+// importTarget は、パックから作成するサーバーの名前とアドレスを決めます。
+// --name を省略した場合は、パック名から作った既定値で入力を求めます。
+func importTarget(cmd *cobra.Command, packName string) (string, string, error) {
+	name, _ := cmd.Flags().GetString("name")
+	if name == "" {
+		prompt := promptui.Prompt{Label: "サーバー名", Default: slugify(packName)}
+		var err error
+		name, err = prompt.Run()
+		if err != nil {
+			return "", "", fmt.Errorf("キャンセルされました")
+		}
+	}
+	if _, found, err := server.FindServer(cfg.Paths.Servers, name); err != nil {
+		return "", "", err
+	} else if found {
+		return "", "", fmt.Errorf("サーバー %s は既に存在します", name)
+	}
+
+	address, _ := cmd.Flags().GetString("address")
+	if address == "" {
+		address = defaultAddress(name)
+	}
+	return name, address, nil
+}
+
+// addImportedServer は、staging に展開したファイルをサーバーディレクトリに移して、サーバーを追加します。
+// サーバータイプの既定のボリュームに含まれないトップレベルのファイルとディレクトリは、/data にマウントします。
+// overlays はパックのファイルを配置した後に重ねます。
+func addImportedServer(s server.Server, env []string, staging string, overlays []string) error {
+	impl, err := server.GetServerType(s.Type)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	for _, p := range append(impl.GetSubdirectories(), impl.GetTemplateFiles()...) {
+		known[p] = true
+	}
+
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return err
+	}
+	var volumes []string
+	for _, entry := range entries {
+		if !known[entry.Name()] && entry.Name() != mods.LockFileName {
+			volumes = append(volumes, fmt.Sprintf("./servers/%s/%s:/data/%s", s.Name, entry.Name(), entry.Name()))
+		}
+	}
+
+	if err := addServer(s, server.ServiceOptions{Environment: env, Volumes: volumes}, nil); err != nil {
+		return err
+	}
+
+	serverDir := server.ServerDirectory(s.Name)
+	if err := placeImportedFiles(staging, serverDir); err != nil {
+		return err
+	}
+	for _, overlay := range overlays {
+		if err := server.ApplyOverlay(serverDir, overlay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// placeImportedFiles は、staging のファイルをサーバーディレクトリに移します。
+// オーバーレイと同じく、.properties ファイルはテンプレートから作ったファイルにキー単位で重ね
+// (enable-rcon や rcon.password などパックが指定しないキーを残すため)、それ以外のファイルは上書きします。
+func placeImportedFiles(staging, serverDir string) error {
+	return filepath.WalkDir(staging, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(staging, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(serverDir, rel)
+		if d.IsDir() {
+			return os.MkdirAll(dst, 0755)
+		}
+
+		if strings.HasSuffix(d.Name(), ".properties") {
+			if current, err := os.ReadFile(dst); err == nil {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(dst, server.MergeProperties(current, data), 0644); err != nil {
+					return fmt.Errorf("%s の配置に失敗しました: %w", rel, err)
+				}
+				return nil
+			}
+		}
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+		if err := os.Rename(path, dst); err != nil {
+			return fmt.Errorf("%s の配置に失敗しました: %w", rel, err)
+		}
+		return nil
+	})
+}
+
+// slugify はパック名をサーバー名に使える形にします。
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+
+	"mcctl/internal/adopt"
+	"mcctl/internal/monitoring"
+	"mcctl/internal/server"
+
+	"github.com/spf13/cobra"
+)
+
+var adoptCmd = &cobra.Command{
+	Use:   "adopt DIR",
+	Short: "手作業で作ったサーバーを mcctl の管理下に置きます",
+	Long: `docker-compose.yml と velocity.toml にはあるが servers.json にないサーバーを servers.json に登録します。
+
+DIR の Dockerfile の ENV (TYPE、VERSION、MEMORY/MAX_MEMORY) からサーバータイプを推定し、
+DIR をビルドコンテキストにしているサービスと、そのサービスを指す Velocity のエントリーを探します。
+サーバー名はサービス名になります。
+
+--move を指定すると、DIR を minecraft/servers/<name> に移動し、サービスの build.context と volumes を書き換えます。
+サービス名とディレクトリ名が違う場合は、--move が必要です。
+
+例:
+  mcctl adopt minecraft/lobby
+  mcctl adopt minecraft/large --move`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		serverType, _ := cmd.Flags().GetString("type")
+		move, _ := cmd.Flags().GetBool("move")
+		if dryRun && move {
+			// ディレクトリの移動は記録できない
+			fmt.Println("--move は --dry-run に対応していません")
+			return
+		}
+
+		opts := adopt.Options{
+			ComposePaths: []string{server.ComposePath(), cfg.Paths.RootCompose},
+			Type:         serverType,
+		}
+		for _, p := range cfg.Proxies {
+			opts.Proxies = append(opts.Proxies, adopt.Proxy{Name: p.Name, Config: p.Config})
+		}
+		c, err := adopt.Inspect(userPath(args[0]), opts)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+
+		if _, found, err := server.FindServer(cfg.Paths.Servers, c.Name); err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		} else if found {
+			fmt.Printf("サーバー %s はすでに %s に登録されています\n", c.Name, cfg.Paths.Servers)
+			return
+		}
+
+		fmt.Printf("サーバー名:   %s\n", c.Name)
+		fmt.Printf("タイプ:       %s\n", c.Type)
+		fmt.Printf("バージョン:   %s\n", valueOrDash(c.MinecraftVersion))
+		fmt.Printf("メモリ:       %s\n", valueOrDash(c.Memory))
+		fmt.Printf("アドレス:     %s\n", c.Address)
+		fmt.Printf("サービス:     %s (%s)\n", c.Service, c.ComposePath)
+		for _, e := range c.Proxies {
+			fmt.Printf("プロキシ:     %s: %s = '%s'\n", e.Proxy, e.Key, e.Address)
+		}
+
+		if move && c.Dir != c.ManagedDir() {
+			if err := c.Move(); err != nil {
+				fmt.Printf("%v\n", err)
+				return
+			}
+			fmt.Printf("ディレクトリを %s に移動しました\n", c.Dir)
+		} else if !c.InManagedLayout() {
+			fmt.Printf("%s はサーバー名 %s から見つけられない場所にあります。--move を指定してください\n", c.Dir, c.Name)
+			return
+		}
+
+		if err := server.SaveServerConfig(cfg.Paths.Servers, c.Server()); err != nil {
+			fmt.Printf("サーバーの保存に失敗しました: %v\n", err)
+			return
+		}
+		if err := monitoring.Sync(monitoringOptions("")); err != nil {
+			fmt.Printf("監視設定の更新に失敗しました: %v\n", err)
+			return
+		}
+
+		for _, n := range c.Notes {
+			fmt.Printf("注意: %s\n", n)
+		}
+		fmt.Printf("サーバー %s を %s に登録しました\n", c.Name, cfg.Paths.Servers)
+	},
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(adoptCmd)
+	adoptCmd.Flags().String("type", "", "サーバータイプ (既定は Dockerfile の TYPE)")
+	adoptCmd.Flags().Bool("move", false, "ディレクトリを minecraft/servers/<name> に移動する")
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+
+	"mcctl/internal/monitoring"
+
+	"github.com/spf13/cobra"
+)
+
+var alertsCmd = &cobra.Command{
+	Use:   "alerts",
+	Short: "Prometheus のアラートルールを管理します",
+}
+
+var alertsGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "servers.json から Prometheus のアラートルールを生成します",
+	Long: `servers.json に登録されているサーバーごとに、次のアラートルールを
+prometheus/rules/mcctl.rules.yml に生成し、prometheus/config.yml の rule_files に登録します:
+  MinecraftServerDown       一定時間 (既定 2m) 応答しない
+  MinecraftLowTPS           TPS が既定 15 を下回る (Paper のみ)
+  MinecraftMemoryNearLimit  コンテナのメモリ上限の既定 90% を超える
+  MinecraftCrashLoop        15分間に既定 3 回を超えて再起動している
+
+しきい値は servers.json の各サーバーの alerts で上書きできます:
+  "alerts": { "downFor": "5m", "minTps": 18, "memoryRatio": 0.85, "maxRestarts": 2 }`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := monitoring.GenerateAlerts(monitoring.AlertOptions{
+			ServersPath:          cfg.Paths.Servers,
+			ComposeFiles:         composeFiles(),
+			RulesPath:            cfg.Paths.AlertRules,
+			RulesGlob:            "/etc/prometheus/rules/*.yml",
+			PrometheusConfigPath: cfg.Paths.Prometheus,
+		})
+		if err != nil {
+			fmt.Printf("アラートルールの生成に失敗しました: %v\n", err)
+			return
+		}
+		fmt.Printf("%s を書き出しました\n", cfg.Paths.AlertRules)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(alertsCmd)
+	alertsCmd.AddCommand(alertsGenerateCmd)
+}
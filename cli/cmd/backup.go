@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"mcctl/internal/backup"
+	"mcctl/internal/server"
+
+	"github.com/spf13/cobra"
+)
+
+// backupRoot はバックアップを置くディレクトリです。
+func backupRoot() string {
+	return cfg.Paths.Backups
+}
+
+// retentionConfigPath は保持ポリシーの設定ファイルです。
+func retentionConfigPath() string {
+	return filepath.Join(cfg.Paths.Backups, "retention.json")
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "サーバーのバックアップを管理します",
+}
+
+var backupCreateCmd = &cobra.Command{
+	Use:   "create NAME",
+	Short: "サーバーディレクトリのバックアップを作成します",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		serverDir := server.ServerDirectory(name)
+		if _, err := os.Stat(serverDir); err != nil {
+			fmt.Printf("サーバーディレクトリが見つかりません: %s\n", serverDir)
+			return
+		}
+
+		b, err := backup.Create(backupRoot(), name, serverDir, time.Now())
+		if err != nil {
+			fmt.Printf("バックアップに失敗しました: %v\n", err)
+			return
+		}
+		fmt.Printf("バックアップを作成しました: %s (%s)\n", b.Path, formatBytes(b.Size))
+	},
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list NAME",
+	Short: "サーバーのバックアップ一覧を表示します",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		backups, err := backup.List(backupRoot(), args[0])
+		if err != nil {
+			fmt.Printf("バックアップ一覧の取得に失敗しました: %v\n", err)
+			return
+		}
+		if len(backups) == 0 {
+			fmt.Println("バックアップはありません")
+			return
+		}
+		for _, b := range backups {
+			fmt.Printf("%s  %10s  %s\n", b.CreatedAt.Format("2006-01-02 15:04:05"), formatBytes(b.Size), b.Path)
+		}
+	},
+}
+
+var backupPruneCmd = &cobra.Command{
+	Use:   "prune [NAME]",
+	Short: "保持ルールに従って古いバックアップを削除します",
+	Long: `grandfather-father-son 方式の保持ルールに従って古いバックアップを削除します。
+
+保持ルールは次の優先順で決まります:
+  1. コマンドラインで指定した --keep-* フラグ
+  2. backups/retention.json のサーバー別設定 (servers.<NAME>)
+  3. backups/retention.json の全体設定 (default)
+  4. 組み込みの既定値 (hourly 24, daily 7, weekly 4, monthly 6)`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+
+		var names []string
+		switch {
+		case len(args) == 1:
+			names = args
+		case all:
+			var err error
+			names, err = backup.ListServers(backupRoot())
+			if err != nil {
+				fmt.Printf("バックアップ一覧の取得に失敗しました: %v\n", err)
+				return
+			}
+		default:
+			fmt.Println("サーバー名か --all を指定してください")
+			return
+		}
+
+		config, err := backup.LoadRetentionConfig(retentionConfigPath())
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+
+		var total int64
+		var count int
+		failed := false
+		for _, name := range names {
+			policy := policyFromFlags(cmd, config.PolicyFor(name))
+			removed, err := backup.Prune(backupRoot(), name, policy, dryRun)
+			if err != nil {
+				fmt.Printf("%s: %v\n", name, err)
+				failed = true
+			}
+			for _, b := range removed {
+				if dryRun {
+					fmt.Printf("削除予定: %s (%s)\n", b.Path, formatBytes(b.Size))
+				} else {
+					fmt.Printf("削除しました: %s (%s)\n", b.Path, formatBytes(b.Size))
+				}
+				total += b.Size
+				count++
+			}
+		}
+
+		if dryRun {
+			fmt.Printf("%d 件のバックアップを削除すると %s 解放されます (dry-run)\n", count, formatBytes(total))
+		} else {
+			fmt.Printf("%d 件のバックアップを削除し、%s 解放しました\n", count, formatBytes(total))
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// policyFromFlags overrides base with any --keep-* flag given on the command line.
+func policyFromFlags(cmd *cobra.Command, base backup.Policy) backup.Policy {
+	policy := base
+	if cmd.Flags().Changed("keep-hourly") {
+		policy.KeepHourly, _ = cmd.Flags().GetInt("keep-hourly")
+	}
+	if cmd.Flags().Changed("keep-daily") {
+		policy.KeepDaily, _ = cmd.Flags().GetInt("keep-daily")
+	}
+	if cmd.Flags().Changed("keep-weekly") {
+		policy.KeepWeekly, _ = cmd.Flags().GetInt("keep-weekly")
+	}
+	if cmd.Flags().Changed("keep-monthly") {
+		policy.KeepMonthly, _ = cmd.Flags().GetInt("keep-monthly")
+	}
+	return policy
+}
+
+// formatBytes formats a byte count for humans (e.g. "1.5 GiB").
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupListCmd)
+	backupCmd.AddCommand(backupPruneCmd)
+
+	backupPruneCmd.Flags().Int("keep-hourly", backup.DefaultPolicy.KeepHourly, "残す時間単位のバックアップ数")
+	backupPruneCmd.Flags().Int("keep-daily", backup.DefaultPolicy.KeepDaily, "残す日単位のバックアップ数")
+	backupPruneCmd.Flags().Int("keep-weekly", backup.DefaultPolicy.KeepWeekly, "残す週単位のバックアップ数")
+	backupPruneCmd.Flags().Int("keep-monthly", backup.DefaultPolicy.KeepMonthly, "残す月単位のバックアップ数")
+	backupPruneCmd.Flags().Bool("all", false, "すべてのサーバーのバックアップを対象にします")
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mcctl/internal/backup"
+	"mcctl/internal/monitoring"
+	"mcctl/internal/server"
+
+	"github.com/spf13/cobra"
+)
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone SRC DST",
+	Short: "サーバーを複製します",
+	Long: `servers.json に登録されているサーバー SRC を複製し、DST として登録します。
+
+サーバーディレクトリを minecraft/servers/<DST> にコピーし、servers.json、各プロキシの velocity.toml、
+minecraft/docker-compose.yml に DST を追加します。サービスは SRC の定義をもとに、パスを DST のディレクトリに書き換えます。
+server.properties の motd、level-name と、環境変数 MOTD、LEVEL の中のサーバー名は DST に置き換えます。
+
+--without-world を指定すると、ワールド (level-name とその _nether、_the_end) をコピーせず、空のワールドで作成します。
+--from-backup を指定すると、現在のディレクトリの代わりに SRC のバックアップから作成します (latest で最新のバックアップ)。`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		srcName, dstName := args[0], args[1]
+		withoutWorld, _ := cmd.Flags().GetBool("without-world")
+		fromBackup, _ := cmd.Flags().GetString("from-backup")
+		address, _ := cmd.Flags().GetString("address")
+		if address == "" {
+			address = defaultAddress(dstName)
+		}
+
+		src, found, err := server.FindServer(cfg.Paths.Servers, srcName)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if !found {
+			fmt.Printf("サーバー %s は %s に登録されていません (手作業で作ったサーバーは mcctl adopt で登録できます)\n", srcName, cfg.Paths.Servers)
+			return
+		}
+		if err := checkNewServerName(dstName); err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		srcCompose, _, err := server.FindDockerComposeService(composeFiles(), srcName)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+
+		srcDir := server.ServerDirectory(srcName)
+		dstDir := filepath.Join(server.MinecraftDir, "servers", dstName)
+		if err := copyServerData(srcName, srcDir, dstDir, fromBackup, withoutWorld); err != nil {
+			os.RemoveAll(dstDir)
+			fmt.Printf("サーバーディレクトリのコピーに失敗しました: %v\n", err)
+			return
+		}
+		fmt.Printf("%s を %s にコピーしました\n", srcDir, dstDir)
+
+		renamed, err := server.RenameInServerDirectory(dstDir, srcName, dstName)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		for _, r := range renamed {
+			fmt.Printf("server.properties の %s\n", r)
+		}
+
+		// 管理用JSONファイルに保存
+		dst := src
+		dst.Name = dstName
+		dst.Address = address
+		now := time.Now().UTC().Truncate(time.Second)
+		dst.CreatedAt = &now
+		dst.Proxies = nil
+		for _, proxy := range cfg.Proxies {
+			dst.Proxies = append(dst.Proxies, proxy.Name)
+		}
+		dst.Labels = map[string]string{"clonedFrom": srcName}
+		for k, v := range src.Labels {
+			if k != "clonedFrom" {
+				dst.Labels[k] = v
+			}
+		}
+		if err := server.SaveServerConfig(cfg.Paths.Servers, dst); err != nil {
+			fmt.Printf("サーバーの保存に失敗しました: %v\n", err)
+			return
+		}
+
+		// velocity.tomlに追加
+		for _, proxy := range cfg.Proxies {
+			if err := server.AddVelocityServerConfig(proxy.Config, dstName, address, cfg.Domain); err != nil {
+				fmt.Printf("Velocity設定更新失敗 (%s): %v\n", proxy.Name, err)
+				return
+			}
+		}
+
+		// minecraft/docker-compose.ymlに追加
+		if err := server.CloneDockerComposeService(srcCompose, srcName, srcDir, dstName, dstDir); err != nil {
+			fmt.Printf("Docker Compose設定更新失敗: %v\n", err)
+			return
+		}
+
+		if err := monitoring.Sync(monitoringOptions("")); err != nil {
+			fmt.Printf("監視設定更新失敗: %v\n", err)
+			return
+		}
+		fmt.Printf("サーバー %s を %s として複製しました (アドレス: %s)\n", srcName, dstName, address)
+	},
+}
+
+// checkNewServerName は、name が servers.json、docker-compose.yml、サーバーディレクトリのどれとも重ならないことを確認します。
+func checkNewServerName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\ `) || name == "." || name == ".." {
+		return fmt.Errorf("不正なサーバー名です: %q", name)
+	}
+	if _, found, err := server.FindServer(cfg.Paths.Servers, name); err != nil {
+		return err
+	} else if found {
+		return fmt.Errorf("サーバー %s はすでに %s に登録されています", name, cfg.Paths.Servers)
+	}
+	if _, _, err := server.FindDockerComposeService(composeFiles(), name); err == nil {
+		return fmt.Errorf("サービス %s はすでに docker-compose.yml にあります", name)
+	}
+	if dir := server.ServerDirectory(name); dirExists(dir) {
+		return fmt.Errorf("サーバーディレクトリ %s はすでに存在します", dir)
+	}
+	return nil
+}
+
+// copyServerData は、サーバーディレクトリかバックアップから dstDir を作ります。
+// withoutWorld のときは、ワールドのディレクトリを空で作ります。
+func copyServerData(srcName, srcDir, dstDir, fromBackup string, withoutWorld bool) error {
+	levelName := "world"
+	if props, err := server.ReadServerProperties(srcDir); err == nil && props["level-name"] != "" {
+		levelName = props["level-name"]
+	}
+	worlds := server.WorldDirectories(levelName)
+	isWorld := func(rel string) bool {
+		for _, w := range worlds {
+			if rel == w {
+				return true
+			}
+		}
+		return false
+	}
+
+	if fromBackup == "" {
+		var skip func(string) bool
+		if withoutWorld {
+			skip = isWorld
+		}
+		if err := server.CopyDirectory(srcDir, dstDir, skip); err != nil {
+			return err
+		}
+	} else {
+		archive := userPath(fromBackup)
+		if fromBackup == "latest" {
+			backups, err := backup.List(backupRoot(), srcName)
+			if err != nil {
+				return err
+			}
+			if len(backups) == 0 {
+				return fmt.Errorf("サーバー %s のバックアップがありません", srcName)
+			}
+			archive = backups[0].Path
+		}
+		if dirExists(dstDir) {
+			return fmt.Errorf("%s はすでに存在します", dstDir)
+		}
+		if err := backup.Extract(archive, dstDir); err != nil {
+			return err
+		}
+		fmt.Printf("バックアップ %s から作成します\n", archive)
+		if withoutWorld {
+			for _, w := range worlds {
+				if err := os.RemoveAll(filepath.Join(dstDir, w)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if withoutWorld {
+		// ボリュームのマウント元がなくならないよう、空のワールドを用意する
+		return os.MkdirAll(filepath.Join(dstDir, levelName), 0755)
+	}
+	return nil
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func init() {
+	rootCmd.AddCommand(cloneCmd)
+	cloneCmd.Flags().Bool("without-world", false, "ワールドをコピーせずに作成する")
+	cloneCmd.Flags().String("from-backup", "", "バックアップのアーカイブ (latest で最新のバックアップ) から作成する")
+	cloneCmd.Flags().String("address", "", "複製したサーバーのアドレス (既定は DST:<mcctl.yaml の defaults.port>)")
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"mcctl/internal/docker"
+	"mcctl/internal/schedule"
+	"mcctl/internal/server"
+	"mcctl/internal/wake"
+
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "スケジュールファイルに従って定期ジョブを実行し続けます",
+	Long: `minecraft/schedule.yaml に定義されたジョブをcron式に従って実行します。
+
+例:
+  jobs:
+    - name: large-nightly-restart
+      server: large
+      cron: "0 4 * * *"
+      action: restart
+      countdown: 5m
+    - name: large-backup
+      server: large
+      cron: "0 */6 * * *"
+      action: backup
+      snapshot: true
+    - name: prune-backups
+      cron: "30 4 * * *"
+      action: prune
+    - name: lobby-announce
+      server: lobby
+      cron: "*/30 * * * *"
+      action: rcon
+      command: "say 定期メンテナンスは毎日4時です"
+
+デーモンが停止していた間に実行されなかったジョブは、起動時に1回だけ実行されます
+(catchUp: false で無効にできます)。
+
+sleep セクションに書いたサーバーは、プレイヤーがいない状態が idleMinutes 分続くと停止します。
+停止中は listen のアドレスで代わりに接続を受け付け、サーバーリストには「スリープ中」と表示し、
+ログインしようとしたプレイヤーがいるとコンテナを起動します。
+プレイヤー数は RCON の list で確認するため、サーバーの RCON が有効である必要があります。
+sleep セクションの変更はデーモンの再起動後に反映されます。
+
+Velocity は velocity.toml の [servers] のアドレス (例: large-paper:25565) に接続するため、
+停止中のコンテナの代わりに listen で待ち受けていても、そのままではプレイヤーは届きません。
+proxyAddress に Velocity のコンテナから listen に届くアドレスを書くと、デーモンの起動時に
+velocity.toml へ <server>-sleeping として登録し、forced-hosts と try でサーバーの次に並べます。
+Velocity はサーバーに接続できないと次のサーバーに接続するため、停止中のログインがデーモンに届きます。
+velocity.toml を変更した場合は Velocity の再起動が必要です。
+
+  sleep:
+    - server: large
+      listen: ":25600"
+      proxyAddress: "host.docker.internal:25600"
+      idleMinutes: 15`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		file, err := schedule.LoadFile(schedulePath())
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+
+		var wg sync.WaitGroup
+		for _, config := range file.Sleep {
+			compose, err := docker.ForService(composeFiles(), config.Server)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				continue
+			}
+			registerSleepingServer(config)
+			sleeper := &wake.Sleeper{
+				Config:      config,
+				Compose:     compose,
+				ServersPath: cfg.Paths.Servers,
+				Log:         os.Stdout,
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sleeper.Run(ctx)
+			}()
+		}
+		defer wg.Wait()
+
+		d := &schedule.Daemon{Path: schedulePath(), Runner: newScheduleRunner()}
+		if err := d.Run(ctx); err != nil {
+			fmt.Printf("デーモンの実行に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("デーモンを停止しました")
+	},
+}
+
+// registerSleepingServer は、Velocity がスリープ中のサーバーの代わりにデーモンへ接続するよう、
+// 各プロキシの velocity.toml に config.ProxyServerName() を登録します。
+func registerSleepingServer(config wake.Config) {
+	if config.ProxyAddress == "" {
+		fmt.Printf("[warning] sleep の %s に proxyAddress がありません。停止中は Velocity から %s に接続できないため、プレイヤーはサーバーを起こせません\n", config.Server, config.Listen)
+		return
+	}
+	for _, proxy := range cfg.Proxies {
+		changed, err := server.AddVelocityFallback(proxy.Config, config.Server, config.ProxyServerName(), config.ProxyAddress)
+		if err != nil {
+			fmt.Printf("%s の更新に失敗しました: %v\n", proxy.Config, err)
+			continue
+		}
+		if changed {
+			fmt.Printf("%s に %s (%s) を登録しました。Velocity を再起動すると反映されます\n", proxy.Config, config.ProxyServerName(), config.ProxyAddress)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"mcctl/internal/dashboards"
+
+	"github.com/spf13/cobra"
+)
+
+var dashboardsCmd = &cobra.Command{
+	Use:   "dashboards",
+	Short: "Grafana ダッシュボードを管理します",
+}
+
+var dashboardsGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "管理しているサーバーの Grafana ダッシュボードを生成します",
+	Long: `servers.json に登録されているサーバーごとに、プレイヤー数・TPS・レイテンシと
+コンテナの CPU・メモリ (cAdvisor) をまとめたダッシュボードを grafana/dashboards/mcctl に生成します。
+--single を付けると、server 変数で切り替える1枚のダッシュボードを生成します。
+
+Grafana が起動時に読み込めるよう、grafana/provisioning/dashboards/mcctl.yaml も書き出します。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		single, _ := cmd.Flags().GetBool("single")
+
+		written, err := dashboards.Generate(dashboards.Options{
+			ServersPath:      cfg.Paths.Servers,
+			OutputDir:        cfg.Paths.Dashboards,
+			ProvisioningPath: cfg.Paths.DashboardProvisioning,
+			ContainerDir:     "/etc/grafana/dashboards/mcctl",
+			Single:           single,
+		})
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		for _, path := range written {
+			fmt.Printf("書き出しました: %s\n", path)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dashboardsCmd)
+	dashboardsCmd.AddCommand(dashboardsGenerateCmd)
+	dashboardsGenerateCmd.Flags().Bool("single", false, "server 変数付きの1枚のダッシュボードを生成します")
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"mcctl/internal/doctor"
+	"mcctl/internal/server"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "servers.json、docker-compose.yml、velocity.toml、テンプレートの整合性を確認します",
+	Long: `mcctl が管理する設定ファイルを突き合わせ、食い違いを修正方法とともに表示します。
+
+  - servers.json のサーバーのディレクトリ、サービス、プロキシへの登録がない
+  - ビルドコンテキストや Dockerfile、ボリュームのマウント元がない
+  - Velocity のアドレスがどの docker-compose.yml のサービスにも一致しない
+  - ホストのポートやサーバーのアドレスが重複している
+  - テンプレートの継承が壊れている、テンプレートファイルがない
+
+--fix を付けると、ディレクトリやサービスの追加など安全に直せる問題を修正します。
+エラーが残っている場合は終了コード 1 で終了します。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fix, _ := cmd.Flags().GetBool("fix")
+
+		problems, err := doctor.Check(doctorOptions())
+		if err != nil {
+			fmt.Printf("検査に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+
+		if fix {
+			fixed := 0
+			for _, p := range problems {
+				if !p.Fixable() {
+					continue
+				}
+				if err := p.Fix(); err != nil {
+					fmt.Printf("修正に失敗しました: %s: %v\n", p.Subject, err)
+					continue
+				}
+				fmt.Printf("修正しました: %s: %s\n", p.Subject, p.Message)
+				fixed++
+			}
+			if fixed > 0 {
+				fmt.Println()
+				problems, err = doctor.Check(doctorOptions())
+				if err != nil {
+					fmt.Printf("検査に失敗しました: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		}
+
+		if len(problems) == 0 {
+			fmt.Println("問題は見つかりませんでした")
+			return
+		}
+
+		errors, fixable := 0, 0
+		for _, p := range problems {
+			fmt.Printf("[%s] %s: %s\n", p.Severity, p.Subject, p.Message)
+			if p.Suggestion != "" {
+				fmt.Printf("    → %s\n", p.Suggestion)
+			}
+			if p.Severity == doctor.SeverityError {
+				errors++
+			}
+			if p.Fixable() {
+				fixable++
+			}
+		}
+		fmt.Printf("\n%d 件の問題 (エラー %d 件)\n", len(problems), errors)
+		if fixable > 0 && !fix {
+			fmt.Printf("%d 件は mcctl doctor --fix で修正できます\n", fixable)
+		}
+		if errors > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func doctorOptions() doctor.Options {
+	opts := doctor.Options{
+		ServersPath:     cfg.Paths.Servers,
+		ComposePath:     server.ComposePath(),
+		RootComposePath: cfg.Paths.RootCompose,
+		Domain:          cfg.Domain,
+	}
+	for _, p := range cfg.Proxies {
+		opts.Proxies = append(opts.Proxies, doctor.Proxy{Name: p.Name, Config: p.Config})
+	}
+	return opts
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().Bool("fix", false, "安全に直せる問題を修正する")
+}
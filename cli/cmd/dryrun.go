@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	"mcctl/internal/diff"
+	"mcctl/internal/vfs"
+
+	"github.com/spf13/cobra"
+)
+
+// dryRun は --dry-run が指定されたかどうかです。
+var dryRun bool
+
+// dryRunCommands は --dry-run に対応しているコマンドです。
+// 書き込みがすべて vfs を通るコマンドと、ファイルもコンテナも変更しないコマンドだけを並べます。
+// ここにないコマンドは、--dry-run を指定すると何もせずに終了します。
+// clone、mods cache、plugins、backup create、snapshot create/restore/forget、schedule run-now は、
+// ダウンロードやアーカイブなど vfs を通らない書き込みがあるため対応していません。
+var dryRunCommands = map[string]bool{
+	"mcctl add":                 true,
+	"mcctl adopt":               true,
+	"mcctl rename":              true,
+	"mcctl upgrade":             true,
+	"mcctl apply":               true,
+	"mcctl plan":                true,
+	"mcctl doctor":              true,
+	"mcctl monitoring sync":     true,
+	"mcctl alerts generate":     true,
+	"mcctl dashboards generate": true,
+	"mcctl list":                true,
+	"mcctl status":              true,
+	"mcctl query":               true,
+	"mcctl types list":          true,
+	"mcctl mods add":            true,
+	"mcctl mods remove":         true,
+	"mcctl mods sync":           true,
+	"mcctl mods list":           true,
+	"mcctl plugins list":        true,
+	"mcctl backup list":         true,
+	"mcctl snapshot list":       true,
+	"mcctl schedule list":       true,
+	"mcctl schedule history":    true,
+}
+
+// reportingDryRunCommands は、--dry-run のときに削除するものを自分で表示し、何も削除しないコマンドです。
+// バックアップのアーカイブやスナップショットのブロックは差分で表示できないため、vfs で記録しません。
+var reportingDryRunCommands = map[string]bool{
+	"mcctl backup prune": true,
+	"mcctl snapshot gc":  true,
+}
+
+// beginDryRun は --dry-run のとき、ファイルへの書き込みの記録を始めます。
+func beginDryRun(cmd *cobra.Command) {
+	if !dryRun || reportingDryRunCommands[cmd.CommandPath()] {
+		return
+	}
+	if !dryRunCommands[cmd.CommandPath()] {
+		fmt.Printf("%s は --dry-run に対応していません\n", cmd.CommandPath())
+		os.Exit(1)
+	}
+	vfs.Record()
+}
+
+// endDryRun は --dry-run のとき、記録した変更を差分で表示して捨てます。
+func endDryRun(cmd *cobra.Command) {
+	if !dryRun || reportingDryRunCommands[cmd.CommandPath()] {
+		return
+	}
+	changes := vfs.Changes()
+	vfs.Discard()
+
+	fmt.Println()
+	if len(changes) == 0 {
+		fmt.Println("--dry-run: 変更はありません")
+		return
+	}
+	printChanges(changes)
+	fmt.Printf("\n--dry-run: %d 件の変更は書き込んでいません\n", len(changes))
+}
+
+// printChanges は記録した変更を unified diff で表示します。
+// 作成するディレクトリ、空のファイル、jar などのバイナリファイルは、パスだけを表示します。
+func printChanges(changes []vfs.Change) {
+	for _, c := range changes {
+		var d string
+		switch {
+		case c.Kind == vfs.Mkdir:
+			fmt.Printf("+ %s/\n", c.Path)
+			continue
+		case binary(c.Old) || binary(c.New):
+			fmt.Println(c)
+			continue
+		}
+		switch c.Kind {
+		case vfs.Create:
+			d = diff.Unified("/dev/null", "b/"+c.Path, nil, c.New)
+		case vfs.Modify:
+			d = diff.Unified("a/"+c.Path, "b/"+c.Path, c.Old, c.New)
+		case vfs.Delete:
+			d = diff.Unified("a/"+c.Path, "/dev/null", c.Old, nil)
+		}
+		if d == "" {
+			fmt.Println(c)
+			continue
+		}
+		fmt.Print(d)
+	}
+}
+
+// binary は、data が diff で表示できないバイナリかどうかを返します。
+func binary(data []byte) bool {
+	return bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"mcctl/internal/exporter"
+
+	"github.com/spf13/cobra"
+)
+
+var exporterCmd = &cobra.Command{
+	Use:   "exporter",
+	Short: "サーバーのメトリクスを Prometheus 形式で公開します",
+	Long: `minecraft/servers.json に登録されているサーバーのメトリクスを /metrics で公開します。
+
+各メトリクスには name, type, version のラベルが付きます:
+  mcctl_server_up                  Server List Ping に応答したか (1/0)
+  mcctl_server_ping_seconds        ピングの往復時間
+  mcctl_server_players_online      オンラインのプレイヤー数
+  mcctl_server_players_max         最大プレイヤー数
+  mcctl_server_tps                 TPS (Paper のみ, window=1m/5m/15m)
+  mcctl_server_mspt_milliseconds   1tickあたりの平均処理時間 (Paper のみ)
+
+servers.json はスクレイプのたびに読み直すため、サーバーを追加しても再起動は不要です。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listen, _ := cmd.Flags().GetString("listen")
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", &exporter.Exporter{ServersPath: cfg.Paths.Servers})
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, `<html><body><a href="/metrics">metrics</a></body></html>`)
+		})
+
+		fmt.Printf("%s でメトリクスを公開します\n", listen)
+		if err := http.ListenAndServe(listen, mux); err != nil {
+			fmt.Printf("エクスポーターの起動に失敗しました: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exporterCmd)
+	exporterCmd.Flags().String("listen", ":9225", "待ち受けるアドレス")
+}
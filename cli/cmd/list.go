@@ -7,34 +7,51 @@ package cmd
 import (
 	"fmt"
 
+	"mcctl/internal/server"
+
 	"github.com/spf13/cobra"
 )
 
 // listCmd represents the list command
 var listCmd = &cobra.Command{
 	Use:   "list",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+	Short: "管理しているサーバーの一覧を表示します",
+	Long: `minecraft/servers.json に登録されているサーバーの一覧を表示します。
+--players を付けると、Query プロトコルで各サーバーに接続しているプレイヤーも表示します。`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("list called")
+		withPlayers, _ := cmd.Flags().GetBool("players")
+
+		servers, err := server.LoadServers(cfg.Paths.Servers)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if len(servers) == 0 {
+			fmt.Println("登録されているサーバーはありません")
+			return
+		}
+
+		if withPlayers {
+			fmt.Printf("%-16s %-10s %-24s %s\n", "NAME", "TYPE", "ADDRESS", "PLAYERS")
+		} else {
+			fmt.Printf("%-16s %-10s %s\n", "NAME", "TYPE", "ADDRESS")
+		}
+		for _, s := range servers {
+			if !withPlayers {
+				fmt.Printf("%-16s %-10s %s\n", s.Name, s.Type, s.Address)
+				continue
+			}
+			players, err := queryPlayers(s.Name)
+			if err != nil {
+				players = fmt.Sprintf("(取得できません: %v)", err)
+			}
+			fmt.Printf("%-16s %-10s %-24s %s\n", s.Name, s.Type, s.Address, players)
+		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// listCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// listCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	listCmd.Flags().Bool("players", false, "Query プロトコルでオンラインのプレイヤーを表示します")
 }
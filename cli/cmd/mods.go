@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mcctl/internal/mods"
+	"mcctl/internal/server"
+	"mcctl/internal/vfs"
+
+	"github.com/spf13/cobra"
+)
+
+// modCacheDir は、MOD の jar を置くローカルキャッシュのディレクトリです。
+// MCCTL_MOD_CACHE で上書きできます。
+func modCacheDir() string {
+	return cacheDir("MCCTL_MOD_CACHE", "mods")
+}
+
+// cacheDir は、環境変数 env が設定されていればその値を、なければユーザーキャッシュ配下の sub を返します。
+func cacheDir(env, sub string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return dir
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "mcctl", sub)
+	}
+	return filepath.Join(".cache", sub)
+}
+
+// modSource returns the source registered under name.
+func modSource(name string) (mods.Source, error) {
+	switch name {
+	case "local", "":
+		return &mods.LocalSource{Dir: modCacheDir()}, nil
+	}
+	return nil, fmt.Errorf("未対応のソースです: %s", name)
+}
+
+var modsCmd = &cobra.Command{
+	Use:   "mods",
+	Short: "Forge/Fabric サーバーの MOD を mods.lock で管理します",
+}
+
+var modsAddCmd = &cobra.Command{
+	Use:   "add NAME MOD[@VERSION]",
+	Short: "MOD をインストールして mods.lock に記録します",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		id, version, _ := strings.Cut(args[1], "@")
+		sourceName, _ := cmd.Flags().GetString("source")
+
+		src, err := modSource(sourceName)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		loader, mcVersion, err := server.RuntimeInfo(cfg.Paths.Servers, name)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		release, err := mods.Resolve(src, id, version, mcVersion, loader)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if !release.Compatible(mcVersion, loader) {
+			fmt.Printf("警告: %s@%s は Minecraft %s (%s) に対応していない可能性があります\n", id, release.Version, mcVersion, loader)
+		}
+
+		serverDir := server.ServerDirectory(name)
+		lock, err := mods.LoadLock(serverDir)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		modsDir := filepath.Join(serverDir, "mods")
+		if err := mods.Install(src, release, modsDir); err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		// 新しい jar を配置できてから古い jar を削除する (失敗しても MOD がなくならないように)
+		if old, ok := lock.Find(id); ok && old.Filename != release.Filename {
+			if err := vfs.Remove(filepath.Join(modsDir, old.Filename)); err != nil && !os.IsNotExist(err) {
+				// 新しい jar は配置済みなので mods.lock は更新し、古い jar は手で消してもらう
+				fmt.Printf("古い jar %s の削除に失敗しました (手動で削除してください): %v\n", old.Filename, err)
+			}
+		}
+
+		lock.Put(mods.Entry{
+			ID:       release.ID,
+			Version:  release.Version,
+			Filename: release.Filename,
+			SHA512:   release.SHA512,
+			Source:   src.Name(),
+			URL:      release.URL,
+		})
+		if err := lock.Save(serverDir); err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		fmt.Printf("%s に %s@%s を追加しました\n", name, release.ID, release.Version)
+	},
+}
+
+var modsRemoveCmd = &cobra.Command{
+	Use:   "remove NAME MOD",
+	Short: "MOD を削除して mods.lock から外します",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, id := args[0], args[1]
+		serverDir := server.ServerDirectory(name)
+		lock, err := mods.LoadLock(serverDir)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		entry, ok := lock.Remove(id)
+		if !ok {
+			fmt.Printf("%s は mods.lock に記録されていません\n", id)
+			return
+		}
+		if err := vfs.Remove(filepath.Join(serverDir, "mods", entry.Filename)); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("jarの削除に失敗しました: %v\n", err)
+			return
+		}
+		if err := lock.Save(serverDir); err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		fmt.Printf("%s から %s を削除しました\n", name, id)
+	},
+}
+
+var modsListCmd = &cobra.Command{
+	Use:   "list NAME",
+	Short: "mods.lock に記録された MOD と状態を表示します",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		serverDir := server.ServerDirectory(args[0])
+		lock, err := mods.LoadLock(serverDir)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		modsDir := filepath.Join(serverDir, "mods")
+
+		fmt.Printf("%-24s %-16s %-10s %-9s %s\n", "ID", "VERSION", "SOURCE", "STATUS", "FILE")
+		for _, e := range lock.Mods {
+			status, err := mods.Check(modsDir, e)
+			if err != nil {
+				status = mods.Status(err.Error())
+			}
+			fmt.Printf("%-24s %-16s %-10s %-9s %s\n", e.ID, e.Version, e.Source, status, e.Filename)
+		}
+
+		unmanaged, err := mods.Unmanaged(modsDir, lock)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		for _, f := range unmanaged {
+			fmt.Printf("%-24s %-16s %-10s %-9s %s\n", "-", "-", "-", "unmanaged", f)
+		}
+	},
+}
+
+var modsSyncCmd = &cobra.Command{
+	Use:   "sync NAME",
+	Short: "mods ディレクトリを mods.lock と一致させます",
+	Long: `mods.lock に記録された MOD のハッシュを検証し、存在しない・内容が異なる jar を
+mods.lock に記録された取得元 (source) から入れ直します。--prune を付けると、mods.lock にない jar を削除します。`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		prune, _ := cmd.Flags().GetBool("prune")
+		serverDir := server.ServerDirectory(args[0])
+		lock, err := mods.LoadLock(serverDir)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		modsDir := filepath.Join(serverDir, "mods")
+
+		failed := 0
+		for _, e := range lock.Mods {
+			status, err := mods.Check(modsDir, e)
+			if err != nil {
+				fmt.Printf("%s: %v\n", e.ID, err)
+				failed++
+				continue
+			}
+			if status == mods.StatusOK {
+				continue
+			}
+			src, err := modSource(e.Source)
+			if err != nil {
+				fmt.Printf("%s (%s): %v\n", e.ID, status, err)
+				failed++
+				continue
+			}
+			release := mods.Release{ID: e.ID, Version: e.Version, Filename: e.Filename, SHA512: e.SHA512, URL: e.URL}
+			if err := mods.Install(src, release, modsDir); err != nil {
+				fmt.Printf("%s (%s): %v\n", e.ID, status, err)
+				failed++
+				continue
+			}
+			fmt.Printf("%s@%s を入れ直しました (%s)\n", e.ID, e.Version, status)
+		}
+
+		unmanaged, err := mods.Unmanaged(modsDir, lock)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		for _, f := range unmanaged {
+			if !prune {
+				fmt.Printf("mods.lock にない jar があります: %s\n", f)
+				continue
+			}
+			if err := vfs.Remove(filepath.Join(modsDir, f)); err != nil {
+				fmt.Printf("%s の削除に失敗しました: %v\n", f, err)
+				failed++
+				continue
+			}
+			fmt.Printf("mods.lock にない jar を削除しました: %s\n", f)
+		}
+
+		if failed > 0 {
+			fmt.Printf("%d 個の MOD を同期できませんでした\n", failed)
+			os.Exit(1)
+		}
+		fmt.Println("mods ディレクトリは mods.lock と一致しています")
+	},
+}
+
+var modsCacheCmd = &cobra.Command{
+	Use:   "cache JAR",
+	Short: "jar をローカルキャッシュに取り込みます",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := cmd.Flags().GetString("id")
+		version, _ := cmd.Flags().GetString("version")
+		gameVersions, _ := cmd.Flags().GetStringSlice("mc")
+		loaders, _ := cmd.Flags().GetStringSlice("loader")
+		if id == "" || version == "" {
+			fmt.Println("--id と --version を指定してください")
+			return
+		}
+
+		cache := &mods.LocalSource{Dir: modCacheDir()}
+		release, err := cache.Add(userPath(args[0]), mods.Release{
+			ID:           id,
+			Version:      version,
+			GameVersions: gameVersions,
+			Loaders:      loaders,
+		})
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		fmt.Printf("%s@%s をキャッシュに追加しました (%s)\n", release.ID, release.Version, cache.Dir)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(modsCmd)
+	modsCmd.AddCommand(modsAddCmd)
+	modsCmd.AddCommand(modsRemoveCmd)
+	modsCmd.AddCommand(modsListCmd)
+	modsCmd.AddCommand(modsSyncCmd)
+	modsCmd.AddCommand(modsCacheCmd)
+
+	modsAddCmd.Flags().String("source", "local", "MOD の取得元")
+	modsSyncCmd.Flags().Bool("prune", false, "mods.lock にない jar を削除します")
+	modsCacheCmd.Flags().String("id", "", "MOD の ID")
+	modsCacheCmd.Flags().String("version", "", "MOD のバージョン")
+	modsCacheCmd.Flags().StringSlice("mc", nil, "対応する Minecraft バージョン")
+	modsCacheCmd.Flags().StringSlice("loader", nil, "対応するローダー (forge, fabric)")
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+
+	"mcctl/internal/monitoring"
+
+	"github.com/spf13/cobra"
+)
+
+// monitoringOptions returns the paths used to keep Prometheus and mc-monitor in sync.
+// exporterAddress が空の場合は mcctl.yaml の defaults.exporter を使います。
+func monitoringOptions(exporterAddress string) monitoring.Options {
+	if exporterAddress == "" {
+		exporterAddress = cfg.Defaults.Exporter
+	}
+	return monitoring.Options{
+		ServersPath:          cfg.Paths.Servers,
+		TargetsPath:          cfg.Paths.PrometheusTargets,
+		TargetsGlob:          "/etc/prometheus/targets/*.json",
+		PrometheusConfigPath: cfg.Paths.Prometheus,
+		ComposePath:          cfg.Paths.RootCompose,
+		ExporterAddress:      exporterAddress,
+	}
+}
+
+var monitoringCmd = &cobra.Command{
+	Use:   "monitoring",
+	Short: "監視設定を管理します",
+}
+
+var monitoringSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "servers.json に合わせて Prometheus と mc-monitor の監視対象を更新します",
+	Long: `servers.json に登録されているサーバーから次のファイルを再生成します:
+  prometheus/targets/minecraft.json   サーバーごとの file_sd ターゲット (job="minecraft-<NAME>")
+  prometheus/config.yml               file_sd を読み込む mcctl ジョブ (なければ追加)
+  docker-compose.yml                  mc-monitor の EXPORT_SERVERS
+
+add などサーバー構成を変えるコマンドは自動的に同じ処理を行います。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		exporterAddress, _ := cmd.Flags().GetString("exporter")
+		if err := monitoring.Sync(monitoringOptions(exporterAddress)); err != nil {
+			fmt.Printf("監視設定の更新に失敗しました: %v\n", err)
+			return
+		}
+		fmt.Println("監視設定を更新しました")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(monitoringCmd)
+	monitoringCmd.AddCommand(monitoringSyncCmd)
+	monitoringSyncCmd.Flags().String("exporter", "", "Prometheus から見た mcctl exporter のアドレス (既定は mcctl.yaml の defaults.exporter)")
+}
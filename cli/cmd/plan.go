@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"mcctl/internal/cluster"
+	"mcctl/internal/monitoring"
+	"mcctl/internal/vfs"
+
+	"github.com/spf13/cobra"
+)
+
+const clusterExample = `
+cluster.yaml の例:
+
+  servers:
+    - name: survival
+      type: paper
+      overlays: [hardcore]
+      environment:
+        MEMORY: 6G
+    - name: modded
+      type: forge
+      address: modded:25565
+      hostnames: [modded.example.com, mods.example.com]
+  proxies:            # 省略した場合は mcctl.yaml の proxies
+    - name: velocity
+      config: velocity/velocity.toml
+      try: [lobby]`
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "cluster.yaml を反映したときの変更を表示します",
+	Long: `cluster.yaml (mcctl.yaml の paths.cluster) に書いたサーバー構成と現在のファイルを比べ、
+servers.json、docker-compose.yml、velocity.toml、サーバーディレクトリ、監視設定への変更を差分で表示します。
+ファイルは変更しません。変更がある場合は終了コード 2 で終了します。
+` + clusterExample,
+	Run: func(cmd *cobra.Command, args []string) {
+		file, _ := cmd.Flags().GetString("file")
+
+		result, err := recordCluster(file)
+		defer vfs.Discard()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+
+		changes := vfs.Changes()
+		printNotes(result)
+		if len(changes) == 0 {
+			fmt.Println("変更はありません")
+			return
+		}
+		printChanges(changes)
+		fmt.Printf("\n%d 件の変更があります。mcctl apply で反映します\n", len(changes))
+		os.Exit(2)
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "cluster.yaml のサーバー構成を反映します",
+	Long: `cluster.yaml に書いたサーバー構成になるように、servers.json、docker-compose.yml、
+velocity.toml、サーバーディレクトリ、監視設定を更新します。何度実行しても同じ結果になります。
+
+cluster.yaml から消えたサーバーは servers.json、サービス、プロキシから外しますが、
+サーバーディレクトリは削除しません。変更内容は mcctl plan で事前に確認できます。
+` + clusterExample,
+	Run: func(cmd *cobra.Command, args []string) {
+		file, _ := cmd.Flags().GetString("file")
+
+		// 途中で失敗してもファイルが中途半端にならないよう、すべての変更を記録してからまとめて書き込む
+		result, err := recordCluster(file)
+		if err != nil {
+			vfs.Discard()
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		if dryRun {
+			// 変更の表示と破棄は --dry-run の共通の処理で行う
+			printNotes(result)
+			return
+		}
+		changes := vfs.Changes()
+		if err := vfs.Commit(); err != nil {
+			fmt.Printf("変更の書き込みに失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+
+		printNotes(result)
+		if len(changes) == 0 {
+			fmt.Println("変更はありません")
+			return
+		}
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+		fmt.Printf("%d 件の変更を反映しました\n", len(changes))
+	},
+}
+
+// recordCluster は cluster.yaml の反映を記録だけ行います。呼び出し元で vfs.Commit か vfs.Discard を呼んでください。
+func recordCluster(file string) (cluster.Result, error) {
+	if file == "" {
+		file = cfg.Paths.Cluster
+	} else {
+		file = userPath(file)
+	}
+
+	vfs.Record()
+	spec, err := cluster.Load(file)
+	if err != nil {
+		return cluster.Result{}, err
+	}
+
+	opts := cluster.Options{
+		ServersPath: cfg.Paths.Servers,
+		ComposePath: cfg.Paths.Compose(),
+		Domain:      cfg.Domain,
+		Port:        cfg.Defaults.Port,
+	}
+	for _, p := range cfg.Proxies {
+		opts.Proxies = append(opts.Proxies, cluster.ProxySpec{Name: p.Name, Config: p.Config})
+	}
+
+	result, err := cluster.Apply(spec, opts)
+	if err != nil {
+		return result, err
+	}
+	if err := monitoring.Sync(monitoringOptions("")); err != nil {
+		return result, fmt.Errorf("監視設定の更新に失敗しました: %w", err)
+	}
+	return result, nil
+}
+
+func printNotes(result cluster.Result) {
+	for _, n := range result.Notes {
+		fmt.Printf("注意: %s\n", n)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(applyCmd)
+	for _, c := range []*cobra.Command{planCmd, applyCmd} {
+		c.Flags().StringP("file", "f", "", "サーバー構成のファイル (既定は mcctl.yaml の paths.cluster)")
+	}
+}
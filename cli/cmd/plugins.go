@@ -0,0 +1,452 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mcctl/internal/mods"
+	"mcctl/internal/plugins"
+	"mcctl/internal/server"
+
+	"github.com/spf13/cobra"
+)
+
+// pluginTemplateDir は、プラグインの設定テンプレートを置くディレクトリです。
+// <pluginTemplateDir>/<Plugin>/config.yml.tmpl が plugins/<Plugin>/config.yml に展開されます。
+func pluginTemplateDir() string {
+	return filepath.Join(server.TemplateRoot(), "paper", "plugins")
+}
+
+// pluginCacheDir は、プラグインの jar を置くローカルキャッシュのディレクトリです。
+// MCCTL_PLUGIN_CACHE で上書きできます。
+func pluginCacheDir() string {
+	return cacheDir("MCCTL_PLUGIN_CACHE", "plugins")
+}
+
+// pluginSource は、plugins.lock に記録されたソース名のソースを返します。
+func pluginSource(name string) (mods.Source, error) {
+	switch name {
+	case "local", "":
+		return &mods.LocalSource{Dir: pluginCacheDir()}, nil
+	}
+	return nil, fmt.Errorf("未対応のソースです: %s", name)
+}
+
+// pluginServer は、プラグインを管理するサーバーのディレクトリと Minecraft バージョンを返します。
+// plugins ディレクトリを持たないタイプ (forge, vanilla など) のサーバーはエラーになります。
+func pluginServer(name string) (string, string, error) {
+	serverType, mcVersion, err := server.RuntimeInfo(cfg.Paths.Servers, name)
+	if err != nil {
+		return "", "", err
+	}
+	impl, err := server.GetServerType(serverType)
+	if err != nil {
+		return "", "", err
+	}
+	for _, dir := range impl.GetSubdirectories() {
+		if dir == "plugins" {
+			return server.ServerDirectory(name), mcVersion, nil
+		}
+	}
+	return "", "", fmt.Errorf("%s はプラグインに対応していないサーバーです (%s)", name, serverType)
+}
+
+// installPlugin は、リリースを一時ディレクトリに取得して plugin.yml を確認してから plugins ディレクトリに配置します。
+// 依存関係にエラーがある場合、force が false なら何も変更しません。
+func installPlugin(name, serverDir, mcVersion string, src mods.Source, release mods.Release, lock *plugins.Lock, force bool) error {
+	pluginsDir := filepath.Join(serverDir, "plugins")
+	if err := os.MkdirAll(pluginsDir, 0755); err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(pluginsDir, ".install-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := mods.Install(src, release, tmpDir); err != nil {
+		return err
+	}
+	desc, err := plugins.ReadDescriptor(filepath.Join(tmpDir, release.Filename))
+	if err != nil {
+		return err
+	}
+
+	old, replacing := lock.Find(release.ID)
+	installed, _, err := plugins.ReadDir(pluginsDir)
+	if err != nil {
+		return err
+	}
+	descriptors := []plugins.Descriptor{desc}
+	for file, d := range installed {
+		if replacing && file == old.Filename {
+			continue
+		}
+		descriptors = append(descriptors, d)
+	}
+	// 既に入っている他のプラグインの問題では、今回のインストールを止めない。
+	var problems []plugins.Problem
+	for _, p := range plugins.Check(descriptors, mcVersion) {
+		if p.Plugin == desc.Name {
+			problems = append(problems, p)
+			fmt.Printf("[%s] %s\n", p.Severity, p)
+		}
+	}
+	if plugins.HasErrors(problems) && !force {
+		return fmt.Errorf("依存関係にエラーがあるため %s を配置しませんでした (--force で無視できます)", release.ID)
+	}
+
+	if err := os.Rename(filepath.Join(tmpDir, release.Filename), filepath.Join(pluginsDir, release.Filename)); err != nil {
+		return err
+	}
+	if replacing && old.Filename != release.Filename {
+		os.Remove(filepath.Join(pluginsDir, old.Filename))
+	}
+
+	lock.Put(plugins.Entry{
+		Entry: mods.Entry{
+			ID:       release.ID,
+			Version:  release.Version,
+			Filename: release.Filename,
+			SHA512:   release.SHA512,
+			Source:   src.Name(),
+			URL:      release.URL,
+		},
+		Plugin: desc.Name,
+	})
+
+	address, _ := server.GameAddress(cfg.Paths.Servers, name)
+	rendered, err := plugins.RenderConfig(pluginTemplateDir(), pluginsDir, plugins.ConfigData{
+		Server:           name,
+		Address:          address,
+		MinecraftVersion: mcVersion,
+		Plugin:           desc.Name,
+		PluginVersion:    release.Version,
+	}, false)
+	if err != nil {
+		return err
+	}
+	if rendered {
+		fmt.Printf("plugins/%s/config.yml をテンプレートから作成しました\n", desc.Name)
+	}
+	return nil
+}
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "Paper サーバーのプラグインを plugins.lock で管理します",
+}
+
+var pluginsAddCmd = &cobra.Command{
+	Use:   "add NAME PLUGIN[@VERSION]",
+	Short: "プラグインをインストールして plugins.lock に記録します",
+	Long: `プラグインをローカルキャッシュからインストールし、plugins.lock に記録します。
+配置する前に jar の plugin.yml を読み、depend と api-version を確認します。
+minecraft/template/paper/plugins/<Plugin>/config.yml.tmpl があれば、plugins/<Plugin>/config.yml に展開します。`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		id, version, _ := strings.Cut(args[1], "@")
+		force, _ := cmd.Flags().GetBool("force")
+
+		serverDir, mcVersion, err := pluginServer(name)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		src := &mods.LocalSource{Dir: pluginCacheDir()}
+		release, err := mods.Resolve(src, id, version, mcVersion, "paper")
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		lock, err := plugins.LoadLock(serverDir)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if err := installPlugin(name, serverDir, mcVersion, src, release, lock, force); err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if err := lock.Save(serverDir); err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		fmt.Printf("%s に %s@%s を追加しました\n", name, release.ID, release.Version)
+	},
+}
+
+var pluginsRemoveCmd = &cobra.Command{
+	Use:   "remove NAME PLUGIN",
+	Short: "プラグインを削除して plugins.lock から外します",
+	Long: `プラグインの jar を削除し、plugins.lock から外します。
+plugins/<Plugin> の設定ディレクトリは残します。`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, id := args[0], args[1]
+		serverDir := server.ServerDirectory(name)
+		lock, err := plugins.LoadLock(serverDir)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		entry, ok := lock.Remove(id)
+		if !ok {
+			fmt.Printf("%s は plugins.lock に記録されていません\n", id)
+			return
+		}
+
+		pluginsDir := filepath.Join(serverDir, "plugins")
+		installed, _, err := plugins.ReadDir(pluginsDir)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		for file, d := range installed {
+			if file == entry.Filename {
+				continue
+			}
+			for _, dep := range d.Depend {
+				if strings.EqualFold(dep, entry.Plugin) {
+					fmt.Printf("警告: %s は %s に依存しています\n", d.Name, entry.Plugin)
+				}
+			}
+		}
+
+		if err := os.Remove(filepath.Join(pluginsDir, entry.Filename)); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("jarの削除に失敗しました: %v\n", err)
+			return
+		}
+		if err := lock.Save(serverDir); err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		fmt.Printf("%s から %s を削除しました\n", name, id)
+	},
+}
+
+var pluginsListCmd = &cobra.Command{
+	Use:   "list NAME",
+	Short: "plugins.lock に記録されたプラグインと依存関係の問題を表示します",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		serverDir, mcVersion, err := pluginServer(args[0])
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		lock, err := plugins.LoadLock(serverDir)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		pluginsDir := filepath.Join(serverDir, "plugins")
+
+		fmt.Printf("%-20s %-20s %-12s %-8s %-9s %s\n", "ID", "PLUGIN", "VERSION", "SOURCE", "STATUS", "FILE")
+		for _, e := range lock.Plugins {
+			status, err := mods.Check(pluginsDir, e.Entry)
+			if err != nil {
+				status = mods.Status(err.Error())
+			}
+			fmt.Printf("%-20s %-20s %-12s %-8s %-9s %s\n", e.ID, e.Plugin, e.Version, e.Source, status, e.Filename)
+		}
+		unmanaged, err := plugins.Unmanaged(pluginsDir, lock)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		for _, f := range unmanaged {
+			fmt.Printf("%-20s %-20s %-12s %-8s %-9s %s\n", "-", "-", "-", "-", "unmanaged", f)
+		}
+
+		installed, failed, err := plugins.ReadDir(pluginsDir)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		descriptors := make([]plugins.Descriptor, 0, len(installed))
+		for _, d := range installed {
+			descriptors = append(descriptors, d)
+		}
+		problems := plugins.Check(descriptors, mcVersion)
+		if len(problems) == 0 && len(failed) == 0 {
+			return
+		}
+		fmt.Println()
+		for file, err := range failed {
+			fmt.Printf("[%s] %s: %v\n", plugins.SeverityError, file, err)
+		}
+		for _, p := range problems {
+			fmt.Printf("[%s] %s\n", p.Severity, p)
+		}
+	},
+}
+
+var pluginsUpdateCmd = &cobra.Command{
+	Use:   "update NAME [PLUGIN...]",
+	Short: "プラグインを互換性のある最新バージョンに更新します",
+	Long: `plugins.lock に記録されたプラグイン (指定した場合はそのプラグインのみ) を、
+サーバーの Minecraft バージョンに対応する最新のリリースに更新します。`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		force, _ := cmd.Flags().GetBool("force")
+
+		serverDir, mcVersion, err := pluginServer(name)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		lock, err := plugins.LoadLock(serverDir)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+
+		targets := args[1:]
+		if len(targets) == 0 {
+			for _, e := range lock.Plugins {
+				targets = append(targets, e.ID)
+			}
+		}
+
+		src := &mods.LocalSource{Dir: pluginCacheDir()}
+		updated, failed := 0, 0
+		for _, id := range targets {
+			current, ok := lock.Find(id)
+			if !ok {
+				fmt.Printf("%s は plugins.lock に記録されていません\n", id)
+				continue
+			}
+			release, err := mods.Resolve(src, id, "", mcVersion, "paper")
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				failed++
+				continue
+			}
+			if mods.CompareVersions(release.Version, current.Version) <= 0 {
+				continue
+			}
+			if err := installPlugin(name, serverDir, mcVersion, src, release, lock, force); err != nil {
+				fmt.Printf("%v\n", err)
+				failed++
+				continue
+			}
+			fmt.Printf("%s: %s -> %s\n", id, current.Version, release.Version)
+			updated++
+		}
+
+		if updated == 0 {
+			if failed == 0 {
+				fmt.Println("更新できるプラグインはありません")
+			}
+			return
+		}
+		if err := lock.Save(serverDir); err != nil {
+			fmt.Printf("%v\n", err)
+		}
+	},
+}
+
+var pluginsConfigCmd = &cobra.Command{
+	Use:   "config NAME",
+	Short: "プラグインの設定テンプレートを展開します",
+	Long: `plugins.lock に記録されたプラグインのうち、minecraft/template/paper/plugins/<Plugin>/config.yml.tmpl が
+あるものを plugins/<Plugin>/config.yml に展開します。既存の config.yml は --overwrite を付けた場合のみ上書きします。`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		overwrite, _ := cmd.Flags().GetBool("overwrite")
+
+		serverDir, mcVersion, err := pluginServer(name)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		lock, err := plugins.LoadLock(serverDir)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		address, _ := server.GameAddress(cfg.Paths.Servers, name)
+		for _, e := range lock.Plugins {
+			rendered, err := plugins.RenderConfig(pluginTemplateDir(), filepath.Join(serverDir, "plugins"), plugins.ConfigData{
+				Server:           name,
+				Address:          address,
+				MinecraftVersion: mcVersion,
+				Plugin:           e.Plugin,
+				PluginVersion:    e.Version,
+			}, overwrite)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				continue
+			}
+			if rendered {
+				fmt.Printf("plugins/%s/config.yml を書き出しました\n", e.Plugin)
+			}
+		}
+	},
+}
+
+var pluginsCacheCmd = &cobra.Command{
+	Use:   "cache JAR",
+	Short: "jar をローカルキャッシュに取り込みます",
+	Long: `jar をプラグインのローカルキャッシュに取り込みます。
+--id を省略した場合は plugin.yml の name を小文字にしたもの、--version を省略した場合は plugin.yml の version を使います。`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := cmd.Flags().GetString("id")
+		version, _ := cmd.Flags().GetString("version")
+		gameVersions, _ := cmd.Flags().GetStringSlice("mc")
+
+		jarPath := userPath(args[0])
+		desc, err := plugins.ReadDescriptor(jarPath)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if id == "" {
+			id = strings.ToLower(desc.Name)
+		}
+		if version == "" {
+			version = desc.Version
+		}
+		if version == "" {
+			fmt.Println("plugin.yml に version がないため、--version を指定してください")
+			return
+		}
+
+		cache := &mods.LocalSource{Dir: pluginCacheDir()}
+		release, err := cache.Add(jarPath, mods.Release{
+			ID:           id,
+			Version:      version,
+			GameVersions: gameVersions,
+			Loaders:      []string{"paper"},
+		})
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		fmt.Printf("%s@%s をキャッシュに追加しました (%s)\n", release.ID, release.Version, cache.Dir)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pluginsCmd)
+	pluginsCmd.AddCommand(pluginsAddCmd)
+	pluginsCmd.AddCommand(pluginsRemoveCmd)
+	pluginsCmd.AddCommand(pluginsListCmd)
+	pluginsCmd.AddCommand(pluginsUpdateCmd)
+	pluginsCmd.AddCommand(pluginsConfigCmd)
+	pluginsCmd.AddCommand(pluginsCacheCmd)
+
+	pluginsAddCmd.Flags().Bool("force", false, "依存関係にエラーがあってもインストールします")
+	pluginsUpdateCmd.Flags().Bool("force", false, "依存関係にエラーがあっても更新します")
+	pluginsConfigCmd.Flags().Bool("overwrite", false, "既存の config.yml を上書きします")
+	pluginsCacheCmd.Flags().String("id", "", "プラグインの ID")
+	pluginsCacheCmd.Flags().String("version", "", "プラグインのバージョン")
+	pluginsCacheCmd.Flags().StringSlice("mc", nil, "対応する Minecraft バージョン")
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"mcctl/internal/query"
+	"mcctl/internal/server"
+
+	"github.com/spf13/cobra"
+)
+
+const queryTimeout = 3 * time.Second
+
+var queryCmd = &cobra.Command{
+	Use:   "query NAME",
+	Short: "Query プロトコルでプレイヤー一覧やプラグイン一覧を取得します",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		address, err := server.QueryTarget(cfg.Paths.Servers, name)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		stat, err := query.FullStatOf(address, queryTimeout)
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			return
+		}
+
+		fmt.Printf("サーバー:     %s (%s)\n", name, address)
+		fmt.Printf("MOTD:         %s\n", stat.MOTD)
+		fmt.Printf("バージョン:   %s\n", stat.Version)
+		fmt.Printf("ゲームタイプ: %s\n", stat.GameType)
+		fmt.Printf("マップ:       %s\n", stat.Map)
+		if stat.ServerMod != "" {
+			fmt.Printf("サーバー実装: %s\n", stat.ServerMod)
+		}
+		fmt.Printf("プレイヤー:   %d/%d\n", stat.NumPlayers, stat.MaxPlayers)
+		for _, p := range stat.Players {
+			fmt.Printf("  - %s\n", p)
+		}
+		if len(stat.Plugins) > 0 {
+			fmt.Printf("プラグイン:   %d 個\n", len(stat.Plugins))
+			for _, p := range stat.Plugins {
+				fmt.Printf("  - %s\n", p)
+			}
+		}
+	},
+}
+
+// queryPlayers returns the online player names of a server, for `list --players`.
+func queryPlayers(name string) (string, error) {
+	address, err := server.QueryTarget(cfg.Paths.Servers, name)
+	if err != nil {
+		return "", err
+	}
+	stat, err := query.FullStatOf(address, queryTimeout)
+	if err != nil {
+		return "", err
+	}
+	if len(stat.Players) == 0 {
+		return fmt.Sprintf("0/%d", stat.MaxPlayers), nil
+	}
+	return fmt.Sprintf("%d/%d %s", stat.NumPlayers, stat.MaxPlayers, strings.Join(stat.Players, ", ")), nil
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+}
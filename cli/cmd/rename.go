@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"mcctl/internal/backup"
+	"mcctl/internal/docker"
+	"mcctl/internal/monitoring"
+	"mcctl/internal/schedule"
+	"mcctl/internal/server"
+	"mcctl/internal/slp"
+	"mcctl/internal/vfs"
+
+	"github.com/spf13/cobra"
+)
+
+var renameCmd = &cobra.Command{
+	Use:   "rename OLD NEW",
+	Short: "サーバーの名前を変更します",
+	Long: `servers.json に登録されているサーバー OLD の名前を NEW に変更します。
+
+servers.json のエントリー、各プロキシの velocity.toml ([servers] のキー、forced-hosts の OLD.<domain> と転送先、try)、
+docker-compose.yml のサービス名、container_name、ボリュームのパス、監視設定をまとめて書き換え、
+サーバーディレクトリを NEW に移動します。アドレスのホストが OLD の場合は NEW にします。
+どれかの更新に失敗した場合は、どのファイルも書き換えません。
+
+コンテナは削除してから NEW のサービスとして作り直します (データはサーバーディレクトリに残ります)。
+起動中のサーバーにプレイヤーがいる場合や、人数を確認できない場合は、--force を指定しない限り何もしません。`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		oldName, newName := args[0], args[1]
+		force, _ := cmd.Flags().GetBool("force")
+
+		s, found, err := server.FindServer(cfg.Paths.Servers, oldName)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if !found {
+			fmt.Printf("サーバー %s は %s に登録されていません (手作業で作ったサーバーは mcctl adopt で登録できます)\n", oldName, cfg.Paths.Servers)
+			return
+		}
+		if err := checkNewServerName(newName); err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		composePath, _, err := server.FindDockerComposeService(composeFiles(), oldName)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		compose := docker.Compose{File: composePath}
+
+		running, err := compose.IsRunning(oldName)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if running && !force {
+			online, err := onlinePlayers(oldName)
+			if err != nil {
+				fmt.Printf("サーバー %s のプレイヤー数を確認できませんでした: %v\n", oldName, err)
+				fmt.Println("--force を指定すると、確認せずに名前を変更します")
+				os.Exit(1)
+			}
+			if online > 0 {
+				fmt.Printf("サーバー %s には %d 人のプレイヤーがいます。--force を指定すると、切断して名前を変更します\n", oldName, online)
+				os.Exit(1)
+			}
+		}
+
+		oldDir := server.ServerDirectory(oldName)
+		newDir := filepath.Join(filepath.Dir(oldDir), newName)
+
+		// コンテナを削除する前に、すべての設定を書き換えられることを確かめる
+		vfs.Record()
+		if err := recordRename(s, newName, composePath, oldDir, newDir); err != nil {
+			vfs.Discard()
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		printRenameNotes(oldName)
+		if dryRun {
+			return
+		}
+		vfs.Discard()
+
+		// サービス名が変わると古いコンテナは compose から扱えなくなるため、先に削除する
+		if err := compose.Remove(oldName); err != nil {
+			fmt.Printf("コンテナの削除に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		restore := func() {
+			if running {
+				if err := compose.Up(oldName); err != nil {
+					fmt.Printf("サーバー %s の再起動に失敗しました: %v\n", oldName, err)
+				}
+			}
+		}
+
+		// 記録している間はコンテナを変更しないため、削除した後に同じ変更を記録し直して、まとめて書き込む
+		vfs.Record()
+		if err := recordRename(s, newName, composePath, oldDir, newDir); err != nil {
+			vfs.Discard()
+			fmt.Printf("%v\n", err)
+			restore()
+			os.Exit(1)
+		}
+		if err := vfs.Commit(); err != nil {
+			vfs.Discard()
+			fmt.Printf("設定の書き込みに失敗しました: %v\n", err)
+			restore()
+			os.Exit(1)
+		}
+		if dirExists(newDir) {
+			fmt.Printf("%s を %s に移動しました\n", oldDir, newDir)
+		}
+
+		if running {
+			if err := compose.Up(newName); err != nil {
+				fmt.Printf("サーバー %s の起動に失敗しました: %v\n", newName, err)
+				os.Exit(1)
+			}
+			fmt.Printf("コンテナ %s を作り直しました\n", server.ContainerName(newName))
+		}
+		fmt.Printf("サーバー %s の名前を %s に変更しました\n", oldName, newName)
+	},
+}
+
+// recordRename は、servers.json、velocity.toml、docker-compose.yml、監視設定の名前を書き換え、
+// サーバーディレクトリを oldDir から newDir に移動します。
+// どれも vfs を通すため、記録中に呼ぶと Commit までディスクは変わりません。
+func recordRename(s server.Server, newName, composePath, oldDir, newDir string) error {
+	oldName := s.Name
+
+	servers, err := server.LoadServers(cfg.Paths.Servers)
+	if err != nil {
+		return err
+	}
+	for i := range servers {
+		if servers[i].Name == oldName {
+			servers[i].Name = newName
+			servers[i].Address = server.RenameAddressHost(servers[i].Address, oldName, newName)
+		}
+	}
+	if err := server.SaveServers(cfg.Paths.Servers, servers); err != nil {
+		return fmt.Errorf("サーバーの保存に失敗しました: %w", err)
+	}
+
+	for _, proxy := range cfg.Proxies {
+		if _, err := server.RenameVelocityServer(proxy.Config, oldName, newName, cfg.Domain); err != nil {
+			return fmt.Errorf("Velocity設定更新失敗 (%s): %w", proxy.Name, err)
+		}
+	}
+
+	if err := server.RenameDockerComposeService(composePath, oldName, newName, oldDir, newDir); err != nil {
+		return fmt.Errorf("Docker Compose設定更新失敗: %w", err)
+	}
+
+	if err := monitoring.Sync(monitoringOptions("")); err != nil {
+		return fmt.Errorf("監視設定更新失敗: %w", err)
+	}
+
+	if dirExists(oldDir) {
+		if err := vfs.Rename(oldDir, newDir); err != nil {
+			return fmt.Errorf("%s を %s に移動できません: %w", oldDir, newDir, err)
+		}
+	}
+	return nil
+}
+
+// printRenameNotes は、名前の変更で書き換えないもの (バックアップ、スケジュールのジョブ) を表示します。
+func printRenameNotes(oldName string) {
+	if backups, err := backup.List(backupRoot(), oldName); err == nil && len(backups) > 0 {
+		fmt.Printf("メモ: %s のバックアップ (%s) は %s の名前のまま残ります\n", oldName, backup.ServerBackupDir(backupRoot(), oldName), oldName)
+	}
+	if f, err := schedule.LoadFile(schedulePath()); err == nil {
+		for _, job := range f.Jobs {
+			if job.Server == oldName {
+				fmt.Printf("メモ: %s のジョブ %s は %s を対象にしたままです\n", schedulePath(), job.Name, oldName)
+			}
+		}
+	}
+}
+
+// onlinePlayers は、Server List Ping で取得したオンラインのプレイヤー数を返します。
+func onlinePlayers(name string) (int, error) {
+	address, err := server.GameAddress(cfg.Paths.Servers, name)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := slp.Ping(address, statusTimeout)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Players.Online, nil
+}
+
+func init() {
+	rootCmd.AddCommand(renameCmd)
+	renameCmd.Flags().Bool("force", false, "プレイヤーがいても名前を変更する")
+}
@@ -22,26 +22,79 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+
+	"mcctl/internal/config"
+	"mcctl/internal/server"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	cfgFile string
+
+	// cfg は mcctl.yaml から読み込んだ設定です。コマンドの実行前に loadConfig で設定されます。
+	cfg = config.Default()
 
+	// workingDir は mcctl を起動したディレクトリです。
+	// loadConfig はプロジェクトルートへ移動するため、引数のパスはこのディレクトリから解決します。
+	workingDir string
+)
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "mcctl",
-	Short: "A brief description of your application",
-	Long: `A longer description that spans multiple lines and likely contains
-examples and usage of using your application. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	// Uncomment the following line if your bare application
-	// has an action associated with it:
-	// Run: func(cmd *cobra.Command, args []string) { },
+	Short: "Docker Compose で動かす Minecraft サーバー群を管理します",
+	Long: `mcctl は、Docker Compose で動かす Minecraft サーバーと Velocity プロキシ、監視、バックアップをまとめて管理します。
+
+サーバーの作成 (add, clone, adopt)、名前の変更 (rename)、バージョンの変更 (upgrade) では、
+servers.json、docker-compose.yml、velocity.toml、Prometheus と Grafana の設定を一緒に書き換えます。
+cluster.yaml に書いた構成との差分は plan で確認し、apply で反映できます。
+
+設定は --config、環境変数 MCCTL_CONFIG、カレントディレクトリから親へたどって見つかった mcctl.yaml の順に探し、
+見つからない場合はカレントディレクトリをプロジェクトルートとして既定の設定を使います。
+--dry-run に対応しているコマンドは、ファイルを変更せずに変更内容を差分で表示します。`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := loadConfig(); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		beginDryRun(cmd)
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		endDryRun(cmd)
+	},
+}
+
+// loadConfig は設定ファイルを探して読み込み、プロジェクトルートへ移動します。
+// 設定ファイル内のパスはルートからの相対パスなので、以降のコマンドはどのサブディレクトリからでも同じように動きます。
+func loadConfig() error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	workingDir = wd
+
+	c, err := config.Discover(cfgFile, wd)
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(c.Root); err != nil {
+		return fmt.Errorf("プロジェクトルート %s に移動できませんでした: %w", c.Root, err)
+	}
+	cfg = c
+	server.MinecraftDir = c.Paths.Minecraft
+	return nil
+}
+
+// userPath は、コマンドライン引数で受け取ったパスを起動したディレクトリからの絶対パスにします。
+func userPath(p string) string {
+	if p == "" || filepath.IsAbs(p) || workingDir == "" {
+		return p
+	}
+	return filepath.Join(workingDir, p)
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -54,15 +107,6 @@ func Execute() {
 }
 
 func init() {
-	// Here you will define your flags and configuration settings.
-	// Cobra supports persistent flags, which, if defined here,
-	// will be global for your application.
-
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.mcctl.yaml)")
-
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "ファイルを変更せず、変更内容を差分で表示する (対応しているコマンドのみ)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "設定ファイル (既定はカレントディレクトリから親へたどって見つかった mcctl.yaml、環境変数 MCCTL_CONFIG でも指定できます)")
 }
-
-
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"mcctl/internal/schedule"
+	"mcctl/internal/server"
+
+	"github.com/spf13/cobra"
+)
+
+// schedulePath はジョブを定義するスケジュールファイルです。
+func schedulePath() string {
+	return cfg.Paths.Schedule
+}
+
+// scheduleHistoryPath はジョブの実行履歴を記録するファイルです。
+func scheduleHistoryPath() string {
+	return cfg.Paths.ScheduleHistory
+}
+
+// composeFiles は、サーバーのサービスを探す docker-compose.yml の一覧です。
+func composeFiles() []string {
+	return []string{server.ComposePath(), cfg.Paths.RootCompose}
+}
+
+// newScheduleRunner returns a Runner wired to the repository's standard paths.
+func newScheduleRunner() *schedule.Runner {
+	return &schedule.Runner{
+		ServersPath:   cfg.Paths.Servers,
+		BackupRoot:    backupRoot(),
+		SnapshotRoot:  snapshotStoreRoot(),
+		RetentionPath: retentionConfigPath(),
+		ComposeFiles:  composeFiles(),
+		History:       &schedule.History{Path: scheduleHistoryPath()},
+		Log:           os.Stdout,
+	}
+}
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "定期実行ジョブを確認・実行します",
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "ジョブの一覧と次回・前回の実行を表示します",
+	Run: func(cmd *cobra.Command, args []string) {
+		file, err := schedule.LoadFile(schedulePath())
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if len(file.Jobs) == 0 {
+			fmt.Printf("%s にジョブが定義されていません\n", schedulePath())
+			return
+		}
+
+		history := &schedule.History{Path: scheduleHistoryPath()}
+		now := time.Now()
+		fmt.Printf("%-24s %-12s %-10s %-16s %-17s %s\n", "JOB", "SERVER", "ACTION", "CRON", "NEXT", "LAST")
+		for _, job := range file.Jobs {
+			last := "-"
+			if run, found, err := history.Last(job.Name); err == nil && found {
+				status := "成功"
+				if !run.Success {
+					status = "失敗"
+				}
+				last = fmt.Sprintf("%s (%s)", run.StartedAt.Format("2006-01-02 15:04"), status)
+			}
+			serverName := job.Server
+			if serverName == "" {
+				serverName = "*"
+			}
+			// 2月30日のように一致する日がない式は、次の実行時刻がない
+			next := "never"
+			if t := job.Next(now); !t.IsZero() {
+				next = t.Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%-24s %-12s %-10s %-16s %-17s %s\n",
+				job.Name, serverName, job.Action, job.Cron, next, last)
+		}
+	},
+}
+
+var scheduleRunNowCmd = &cobra.Command{
+	Use:   "run-now JOB",
+	Short: "ジョブを今すぐ実行します",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		file, err := schedule.LoadFile(schedulePath())
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		job, ok := file.Find(args[0])
+		if !ok {
+			fmt.Printf("ジョブ %s が見つかりません\n", args[0])
+			return
+		}
+
+		// Ctrl+C で再起動のカウントダウンを中断できるようにする
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		run := newScheduleRunner().Execute(ctx, job, schedule.TriggerManual)
+		if run.Output != "" {
+			fmt.Println(run.Output)
+		}
+		if !run.Success {
+			os.Exit(1)
+		}
+	},
+}
+
+var scheduleHistoryCmd = &cobra.Command{
+	Use:   "history JOB",
+	Short: "ジョブの実行履歴を表示します",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		history := &schedule.History{Path: scheduleHistoryPath()}
+		runs, err := history.Runs(args[0])
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if len(runs) == 0 {
+			fmt.Println("実行履歴はありません")
+			return
+		}
+		for _, run := range runs {
+			status := "成功"
+			if !run.Success {
+				status = "失敗: " + run.Error
+			}
+			fmt.Printf("%s  %-9s %8s  %s\n", run.StartedAt.Format("2006-01-02 15:04:05"), run.Trigger,
+				run.FinishedAt.Sub(run.StartedAt).Round(time.Second), status)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.AddCommand(scheduleListCmd)
+	scheduleCmd.AddCommand(scheduleRunNowCmd)
+	scheduleCmd.AddCommand(scheduleHistoryCmd)
+}
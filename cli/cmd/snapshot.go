@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"mcctl/internal/docker"
+	"mcctl/internal/server"
+	"mcctl/internal/snapshot"
+
+	"github.com/spf13/cobra"
+)
+
+// snapshotStoreRoot は重複排除スナップショットのストアです。
+func snapshotStoreRoot() string {
+	return filepath.Join(cfg.Paths.Backups, "store")
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "重複排除された増分バックアップを管理します",
+	Long: `サーバーディレクトリをブロック単位に分割し、ハッシュをキーにして保存します。
+同じ内容のブロックは一度しか保存されないため、変更のあったリージョンファイルの分だけ容量が増えます。`,
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create NAME",
+	Short: "サーバーのスナップショットを作成します",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		serverDir := server.ServerDirectory(name)
+		if _, err := os.Stat(serverDir); err != nil {
+			fmt.Printf("サーバーディレクトリが見つかりません: %s\n", serverDir)
+			return
+		}
+
+		store, err := snapshot.Open(snapshotStoreRoot())
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		m, stats, err := store.Create(name, serverDir, time.Now())
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		fmt.Printf("スナップショット %s/%s を作成しました\n", m.Server, m.ID)
+		fmt.Printf("  ファイル: %d, ブロック: %d (新規 %d, 再利用 %d)\n", stats.Files, stats.Blocks, stats.NewBlocks, stats.ReusedBlocks)
+		fmt.Printf("  データ量: %s, 追加保存量: %s\n", formatBytes(stats.TotalBytes), formatBytes(stats.StoredBytes))
+	},
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list [NAME]",
+	Short: "スナップショットの一覧を表示します",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		store, err := snapshot.Open(snapshotStoreRoot())
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		manifests, err := store.List(name)
+		if err != nil {
+			fmt.Printf("スナップショット一覧の取得に失敗しました: %v\n", err)
+			return
+		}
+		if len(manifests) == 0 {
+			fmt.Println("スナップショットはありません")
+			return
+		}
+		for _, m := range manifests {
+			var size int64
+			for _, f := range m.Files {
+				size += f.Size
+			}
+			fmt.Printf("%-20s %-16s %6d files %10s\n", m.Server, m.ID, len(m.Files), formatBytes(size))
+		}
+	},
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore NAME ID",
+	Short: "スナップショットをサーバーディレクトリに復元します",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, id := args[0], args[1]
+		target, _ := cmd.Flags().GetString("target")
+		if target != "" {
+			target = userPath(target)
+		} else {
+			target = server.ServerDirectory(name)
+		}
+
+		// 起動中のサーバーはワールドを書き込み続けるため、復元した内容が上書きされたり壊れたりする
+		if sameDir(target, server.ServerDirectory(name)) {
+			if compose, err := docker.ForService(composeFiles(), name); err == nil {
+				running, err := compose.IsRunning(name)
+				if err != nil {
+					fmt.Printf("%v\n", err)
+					return
+				}
+				if running {
+					fmt.Printf("サーバー %s は起動中です。mcctl stop %s で停止してから復元してください\n", name, name)
+					os.Exit(1)
+				}
+			}
+		}
+
+		store, err := snapshot.Open(snapshotStoreRoot())
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		m, err := store.Load(name, id)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if err := store.Restore(m, target); err != nil {
+			fmt.Printf("復元に失敗しました: %v\n", err)
+			return
+		}
+		fmt.Printf("スナップショット %s/%s を %s に復元しました\n", name, id, target)
+	},
+}
+
+var snapshotVerifyCmd = &cobra.Command{
+	Use:   "verify [NAME]",
+	Short: "スナップショットが参照するブロックの存在とハッシュを検証します",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		store, err := snapshot.Open(snapshotStoreRoot())
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		manifests, err := store.List(name)
+		if err != nil {
+			fmt.Printf("スナップショット一覧の取得に失敗しました: %v\n", err)
+			return
+		}
+
+		problems := store.Verify(manifests)
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) > 0 {
+			fmt.Printf("%d 件の問題が見つかりました\n", len(problems))
+			os.Exit(1)
+		}
+		fmt.Printf("%d 件のスナップショットを検証しました。問題はありません\n", len(manifests))
+	},
+}
+
+var snapshotForgetCmd = &cobra.Command{
+	Use:   "forget NAME ID",
+	Short: "スナップショットを削除します (ブロックは gc で回収されます)",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := snapshot.Open(snapshotStoreRoot())
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if err := store.Forget(args[0], args[1]); err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		fmt.Printf("スナップショット %s/%s を削除しました\n", args[0], args[1])
+	},
+}
+
+var snapshotGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "どのスナップショットからも参照されていないブロックを削除します",
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := snapshot.Open(snapshotStoreRoot())
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		removed, freed, err := store.GC(dryRun)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if dryRun {
+			fmt.Printf("%d 個のブロックを削除すると %s 解放されます (dry-run)\n", removed, formatBytes(freed))
+			return
+		}
+		fmt.Printf("%d 個のブロックを削除し、%s 解放しました\n", removed, formatBytes(freed))
+	},
+}
+
+// sameDir reports whether a and b refer to the same directory path.
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotRestoreCmd)
+	snapshotCmd.AddCommand(snapshotVerifyCmd)
+	snapshotCmd.AddCommand(snapshotForgetCmd)
+	snapshotCmd.AddCommand(snapshotGCCmd)
+
+	snapshotRestoreCmd.Flags().String("target", "", "復元先ディレクトリ (既定はサーバーディレクトリ)")
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"mcctl/internal/server"
+	"mcctl/internal/slp"
+
+	"github.com/spf13/cobra"
+)
+
+const statusTimeout = 5 * time.Second
+
+var statusCmd = &cobra.Command{
+	Use:   "status [NAME]",
+	Short: "Server List Ping でサーバーが実際に応答しているかを確認します",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+
+		var names []string
+		switch {
+		case len(args) == 1:
+			names = args
+		case all:
+			servers, err := server.LoadServers(cfg.Paths.Servers)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				return
+			}
+			for _, s := range servers {
+				names = append(names, s.Name)
+			}
+			if len(names) == 0 {
+				fmt.Println("登録されているサーバーはありません")
+				return
+			}
+		default:
+			fmt.Println("サーバー名か --all を指定してください")
+			return
+		}
+
+		type result struct {
+			address string
+			resp    *slp.Response
+			err     error
+		}
+		results := make([]result, len(names))
+		var wg sync.WaitGroup
+		for i, name := range names {
+			wg.Add(1)
+			go func(i int, name string) {
+				defer wg.Done()
+				address, err := server.GameAddress(cfg.Paths.Servers, name)
+				if err != nil {
+					results[i] = result{err: err}
+					return
+				}
+				resp, err := slp.Ping(address, statusTimeout)
+				results[i] = result{address: address, resp: resp, err: err}
+			}(i, name)
+		}
+		wg.Wait()
+
+		if len(names) == 1 && results[0].err == nil {
+			printStatusDetail(names[0], results[0].address, results[0].resp)
+			return
+		}
+
+		fmt.Printf("%-16s %-8s %-16s %-9s %-8s %s\n", "NAME", "STATUS", "VERSION", "PLAYERS", "LATENCY", "MOTD")
+		for i, name := range names {
+			r := results[i]
+			if r.err != nil {
+				fmt.Printf("%-16s %-8s %s\n", name, "DOWN", r.err)
+				continue
+			}
+			fmt.Printf("%-16s %-8s %-16s %-9s %-8s %s\n", name, "UP", r.resp.Version.Name,
+				fmt.Sprintf("%d/%d", r.resp.Players.Online, r.resp.Players.Max),
+				r.resp.Latency.Round(time.Millisecond), oneLine(r.resp.MOTD()))
+		}
+	},
+}
+
+func printStatusDetail(name, address string, resp *slp.Response) {
+	fmt.Printf("サーバー:   %s (%s)\n", name, address)
+	fmt.Printf("状態:       UP\n")
+	fmt.Printf("バージョン: %s (protocol %d)\n", resp.Version.Name, resp.Version.Protocol)
+	fmt.Printf("プレイヤー: %d/%d\n", resp.Players.Online, resp.Players.Max)
+	for _, p := range resp.Players.Sample {
+		fmt.Printf("  - %s (%s)\n", p.Name, p.ID)
+	}
+	fmt.Printf("MOTD:       %s\n", oneLine(resp.MOTD()))
+	fmt.Printf("レイテンシ: %s\n", resp.Latency.Round(time.Millisecond))
+	if icon, err := resp.FaviconPNG(); err == nil && icon != nil {
+		fmt.Printf("アイコン:   PNG %d bytes\n", len(icon))
+	}
+	if resp.Legacy {
+		fmt.Println("(レガシーピングで取得しました)")
+	}
+}
+
+// oneLine collapses a multi-line MOTD for table output.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().Bool("all", false, "登録されているすべてのサーバーの状態を表示します")
+}
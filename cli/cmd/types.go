@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"mcctl/internal/server"
+
+	"github.com/spf13/cobra"
+)
+
+var typesCmd = &cobra.Command{
+	Use:   "types",
+	Short: "サーバータイプを管理します",
+}
+
+var typesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "利用できるサーバータイプと定義元を表示します",
+	Long: `組み込みのサーバータイプと、minecraft/template/<type>/` + server.TypeFileName + ` で定義されたタイプを表示します。
+type.yaml は同じ名前の組み込みタイプより優先されます。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Printf("%-12s %-40s %s\n", "TYPE", "SOURCE", "DESCRIPTION")
+		for _, info := range server.ListServerTypes() {
+			desc := info.Description
+			if info.Shadows {
+				desc = "(組み込みタイプを上書き) " + desc
+			}
+			if info.Err != nil {
+				desc = fmt.Sprintf("エラー: %v", info.Err)
+			}
+			fmt.Printf("%-12s %-40s %s\n", info.Name, info.Source, desc)
+		}
+
+		if overlays := server.ListOverlays(); len(overlays) > 0 {
+			fmt.Printf("\nオーバーレイ (--overlay): %s\n", strings.Join(overlays, ", "))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(typesCmd)
+	typesCmd.AddCommand(typesListCmd)
+}
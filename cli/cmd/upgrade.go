@@ -0,0 +1,382 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"mcctl/internal/backup"
+	"mcctl/internal/docker"
+	"mcctl/internal/mods"
+	"mcctl/internal/plugins"
+	"mcctl/internal/server"
+	"mcctl/internal/upgrade"
+
+	"github.com/spf13/cobra"
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade NAME (--to VERSION | --rollback)",
+	Short: "サーバーの Minecraft バージョンを変更します",
+	Long: `サーバーの Minecraft バージョンを --to のバージョンに変更します。
+
+変更する前に次のことを確認し、計画を表示します。
+  - ダウングレード (新しいバージョンで保存したワールドが失われることがある)
+  - メジャーバージョンの飛ばし (例: 1.18 から 1.20)
+  - mods.lock と plugins.lock の MOD・プラグインが新しいバージョンに対応しているか
+    (対応していなければローカルキャッシュから対応する最新のバージョンを探します)
+
+ダウングレードと、対応するバージョンが見つからない MOD・プラグインがある場合は、--force を指定しない限り何もしません。
+計画だけを確認するには mcctl --dry-run upgrade を使います。
+
+問題がなければ、サーバーを停止してバックアップを作成し、MOD・プラグインを入れ替え、
+minecraft/docker-compose.yml のサービスの VERSION を書き換えてからビルドして起動します。
+ローダーのバージョン (FORGE_VERSION など) は新しいバージョンに合うものが選ばれるよう削除します。
+
+--rollback を指定すると、最後のアップグレードの前に作ったバックアップからサーバーディレクトリを復元し、
+サービスの環境変数を元に戻して起動します。`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		to, _ := cmd.Flags().GetString("to")
+		rollback, _ := cmd.Flags().GetBool("rollback")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if (to == "") == !rollback {
+			fmt.Println("--to と --rollback のどちらか一方を指定してください")
+			return
+		}
+		s, found, err := server.FindServer(cfg.Paths.Servers, name)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if !found {
+			fmt.Printf("サーバー %s は %s に登録されていません\n", name, cfg.Paths.Servers)
+			return
+		}
+		// VERSION を書き換えられるのは mcctl が管理する docker-compose.yml のサービスだけ
+		if ok, err := server.HasDockerComposeService(server.ComposePath(), name); err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		} else if !ok {
+			fmt.Printf("サービス %s が %s にないため、バージョンを変更できません\n", name, server.ComposePath())
+			return
+		}
+
+		if rollback {
+			rollbackUpgrade(s)
+			return
+		}
+		runUpgrade(s, to, force)
+	},
+}
+
+// runUpgrade は、計画を確認してからサーバーを --to のバージョンにします。
+func runUpgrade(s server.Server, to string, force bool) {
+	serverType, from, err := server.RuntimeInfo(cfg.Paths.Servers, s.Name)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	if from == to {
+		fmt.Printf("サーバー %s はすでに Minecraft %s です\n", s.Name, to)
+		return
+	}
+
+	serverDir := server.ServerDirectory(s.Name)
+	plan, modLock, pluginLock, err := upgradePlan(s.Name, serverType, serverDir, from, to)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	printUpgradePlan(plan)
+
+	if blockers := plan.Blockers(); len(blockers) > 0 {
+		fmt.Println()
+		for _, b := range blockers {
+			fmt.Printf("[error] %s\n", b)
+		}
+		if !force {
+			fmt.Println("--force を指定すると、このままアップグレードします")
+			os.Exit(1)
+		}
+	}
+	if dryRun {
+		return
+	}
+	fmt.Println()
+
+	compose := docker.Compose{File: server.ComposePath()}
+	if err := compose.Stop(s.Name); err != nil {
+		fmt.Printf("サーバーの停止に失敗しました: %v\n", err)
+		os.Exit(1)
+	}
+
+	// 何かを変更する前にバックアップを作り、--rollback で戻せるように記録する
+	b, err := backup.Create(backupRoot(), s.Name, serverDir, time.Now())
+	if err != nil {
+		fmt.Printf("バックアップに失敗しました: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("バックアップを作成しました: %s\n", b.Path)
+	env, err := server.DockerComposeEnvironment(server.ComposePath(), s.Name)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	previous := map[string]string{"VERSION": env["VERSION"]}
+	for _, key := range upgrade.LoaderVersionKeys {
+		previous[key] = env[key]
+	}
+	backupDir := backup.ServerBackupDir(backupRoot(), s.Name)
+	err = upgrade.SaveRecord(backupDir, upgrade.Record{
+		Server:      s.Name,
+		From:        from,
+		To:          to,
+		Backup:      b.Path,
+		Environment: previous,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+	})
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	if err := installUpgradeJars(s.Name, serverDir, to, plan, modLock, pluginLock, force); err != nil {
+		fmt.Printf("%v\n", err)
+		fmt.Printf("mcctl upgrade %s --rollback で元に戻せます\n", s.Name)
+		os.Exit(1)
+	}
+
+	if _, err := server.SetDockerComposeEnvironment(server.ComposePath(), s.Name, map[string]string{"VERSION": to}, upgrade.LoaderVersionKeys); err != nil {
+		fmt.Printf("Docker Compose設定更新失敗: %v\n", err)
+		fmt.Printf("mcctl upgrade %s --rollback で元に戻せます\n", s.Name)
+		os.Exit(1)
+	}
+	if err := saveRuntime(s.Name); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s のサービスの VERSION を %s にしました\n", server.ComposePath(), to)
+
+	if err := compose.Up(s.Name); err != nil {
+		fmt.Printf("サーバーの起動に失敗しました: %v\n", err)
+		fmt.Printf("mcctl upgrade %s --rollback で元に戻せます\n", s.Name)
+		os.Exit(1)
+	}
+	fmt.Printf("サーバー %s を Minecraft %s から %s にして起動しました (mcctl upgrade %s --rollback で元に戻せます)\n", s.Name, from, to, s.Name)
+}
+
+// upgradePlan は、mods.lock と plugins.lock の jar を確認した計画と、読み込んだロックファイルを返します。
+// サーバータイプが MOD やプラグインに対応していない場合、そのロックファイルは nil です。
+func upgradePlan(name, serverType, serverDir, from, to string) (*upgrade.Plan, *mods.Lock, *plugins.Lock, error) {
+	plan := &upgrade.Plan{Server: name, Type: serverType, From: from, To: to}
+	impl, err := server.GetServerType(serverType)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var modLock *mods.Lock
+	if slices.Contains(impl.GetSubdirectories(), "mods") {
+		if modLock, err = mods.LoadLock(serverDir); err != nil {
+			return nil, nil, nil, err
+		}
+		plan.Jars = append(plan.Jars, upgrade.CheckJars("mod", modSource, modLock.Mods, to, serverType)...)
+	}
+
+	var pluginLock *plugins.Lock
+	if slices.Contains(impl.GetSubdirectories(), "plugins") {
+		if pluginLock, err = plugins.LoadLock(serverDir); err != nil {
+			return nil, nil, nil, err
+		}
+		entries := make([]mods.Entry, 0, len(pluginLock.Plugins))
+		for _, e := range pluginLock.Plugins {
+			entries = append(entries, e.Entry)
+		}
+		jars := upgrade.CheckJars("plugin", pluginSource, entries, to, "paper")
+
+		// ソースに対応バージョンの情報がなくても、plugin.yml の api-version が新しすぎるものは使えない
+		installed, _, err := plugins.ReadDir(filepath.Join(serverDir, "plugins"))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for i, jar := range jars {
+			if jar.Action != upgrade.ActionKeep {
+				continue
+			}
+			if d, ok := installed[pluginLock.Plugins[i].Filename]; ok && !plugins.CheckAPIVersion(d.APIVersion, to) {
+				jars[i].Action = upgrade.ActionIncompatible
+				jars[i].Reason = fmt.Sprintf("api-version %s は Minecraft %s より新しいです", d.APIVersion, to)
+			}
+		}
+		plan.Jars = append(plan.Jars, jars...)
+	}
+	return plan, modLock, pluginLock, nil
+}
+
+func printUpgradePlan(plan *upgrade.Plan) {
+	fmt.Printf("サーバー %s (%s): Minecraft %s -> %s\n", plan.Server, plan.Type, plan.From, plan.To)
+	if skipped := plan.SkippedMajors(); len(skipped) > 0 {
+		fmt.Printf("[warning] %s を飛ばしてアップグレードします。途中のバージョンを経由せずにワールドが変換されるため、MOD やプラグインのデータが正しく移行されないことがあります\n", strings.Join(skipped, ", "))
+	}
+	if len(plan.Jars) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Printf("%-7s %-24s %-12s %-12s %s\n", "KIND", "ID", "CURRENT", "NEW", "ACTION")
+	for _, j := range plan.Jars {
+		next := "-"
+		switch j.Action {
+		case upgrade.ActionKeep:
+			next = j.Current
+		case upgrade.ActionUpdate:
+			next = j.Release.Version
+		}
+		fmt.Printf("%-7s %-24s %-12s %-12s %s\n", j.Kind, j.ID, j.Current, next, j.Action)
+	}
+	for _, j := range plan.Skipped() {
+		fmt.Printf("[warning] %s %s: %s。新しいバージョンで動くかは手動で確認してください\n", j.Kind, j.ID, j.Reason)
+	}
+}
+
+// installUpgradeJars は、計画で update になった MOD とプラグインを入れ替えてロックファイルを保存します。
+func installUpgradeJars(name, serverDir, mcVersion string, plan *upgrade.Plan, modLock *mods.Lock, pluginLock *plugins.Lock, force bool) error {
+	modSrc := &mods.LocalSource{Dir: modCacheDir()}
+	pluginSrc := &mods.LocalSource{Dir: pluginCacheDir()}
+	for _, j := range plan.Jars {
+		if j.Action != upgrade.ActionUpdate {
+			continue
+		}
+		switch j.Kind {
+		case "mod":
+			modsDir := filepath.Join(serverDir, "mods")
+			if err := mods.Install(modSrc, j.Release, modsDir); err != nil {
+				return err
+			}
+			if old, ok := modLock.Find(j.ID); ok && old.Filename != j.Release.Filename {
+				os.Remove(filepath.Join(modsDir, old.Filename))
+			}
+			modLock.Put(mods.Entry{
+				ID:       j.Release.ID,
+				Version:  j.Release.Version,
+				Filename: j.Release.Filename,
+				SHA512:   j.Release.SHA512,
+				Source:   modSrc.Name(),
+				URL:      j.Release.URL,
+			})
+		case "plugin":
+			if err := installPlugin(name, serverDir, mcVersion, pluginSrc, j.Release, pluginLock, force); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("%s: %s -> %s\n", j.ID, j.Current, j.Release.Version)
+	}
+
+	if modLock != nil {
+		if err := modLock.Save(serverDir); err != nil {
+			return err
+		}
+	}
+	if pluginLock != nil {
+		if err := pluginLock.Save(serverDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollbackUpgrade は、最後のアップグレードの前のバックアップと環境変数に戻して起動します。
+func rollbackUpgrade(s server.Server) {
+	backupDir := backup.ServerBackupDir(backupRoot(), s.Name)
+	record, found, err := upgrade.LoadRecord(backupDir)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	if !found {
+		fmt.Printf("サーバー %s のアップグレードの記録がありません (%s)\n", s.Name, upgrade.RecordPath(backupDir))
+		return
+	}
+	if _, err := os.Stat(record.Backup); err != nil {
+		fmt.Printf("アップグレードの前のバックアップが見つかりません: %v\n", err)
+		return
+	}
+
+	fmt.Printf("サーバー %s を Minecraft %s から %s に戻します (バックアップ: %s)\n", s.Name, record.To, record.From, record.Backup)
+	if dryRun {
+		return
+	}
+
+	compose := docker.Compose{File: server.ComposePath()}
+	if err := compose.Stop(s.Name); err != nil {
+		fmt.Printf("サーバーの停止に失敗しました: %v\n", err)
+		os.Exit(1)
+	}
+	serverDir := server.ServerDirectory(s.Name)
+	if err := backup.Restore(record.Backup, serverDir); err != nil {
+		fmt.Printf("復元に失敗しました: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s を %s から復元しました\n", serverDir, record.Backup)
+
+	set := make(map[string]string)
+	var unset []string
+	for key, value := range record.Environment {
+		if value == "" {
+			unset = append(unset, key)
+		} else {
+			set[key] = value
+		}
+	}
+	if _, err := server.SetDockerComposeEnvironment(server.ComposePath(), s.Name, set, unset); err != nil {
+		fmt.Printf("Docker Compose設定更新失敗: %v\n", err)
+		os.Exit(1)
+	}
+	if err := saveRuntime(s.Name); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	if err := upgrade.RemoveRecord(backupDir); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	if err := compose.Up(s.Name); err != nil {
+		fmt.Printf("サーバーの起動に失敗しました: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("サーバー %s を Minecraft %s に戻して起動しました\n", s.Name, record.From)
+}
+
+// saveRuntime は、サービスの環境変数から servers.json の mcVersion と loaderVersion を更新します。
+func saveRuntime(name string) error {
+	env, err := server.DockerComposeEnvironment(server.ComposePath(), name)
+	if err != nil {
+		return err
+	}
+	servers, err := server.LoadServers(cfg.Paths.Servers)
+	if err != nil {
+		return err
+	}
+	for i := range servers {
+		if servers[i].Name == name {
+			servers[i].LoaderVersion = ""
+			servers[i].SetRuntime(env)
+		}
+	}
+	if err := server.SaveServers(cfg.Paths.Servers, servers); err != nil {
+		return fmt.Errorf("サーバーの保存に失敗しました: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeCmd)
+	upgradeCmd.Flags().String("to", "", "変更先の Minecraft バージョン (例: 1.20.1)")
+	upgradeCmd.Flags().Bool("rollback", false, "最後のアップグレードの前の状態に戻す")
+	upgradeCmd.Flags().Bool("force", false, "ダウングレードや対応していない MOD・プラグインがあってもアップグレードする")
+}
@@ -0,0 +1,313 @@
+// Package adopt は、手作業で作られたサーバーディレクトリを調べて mcctl の管理下に置きます。
+//
+// Dockerfile の ENV からサーバータイプとバージョンを推定し、ディレクトリをビルドコンテキストにしている
+// docker-compose.yml のサービスと、そのサービスを指している Velocity のエントリーを探します。
+package adopt
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"mcctl/internal/server"
+	"mcctl/internal/vfs"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Proxy は、エントリーを探す Velocity の設定ファイルです。
+type Proxy struct {
+	Name   string
+	Config string
+}
+
+// Options は Inspect が探すファイルです。
+type Options struct {
+	// ComposePaths はサービスを探す docker-compose.yml です。先に見つかったものを使います。
+	ComposePaths []string
+	Proxies      []Proxy
+	// Type を指定すると、Dockerfile の TYPE の代わりに使います。
+	Type string
+}
+
+// ProxyEntry は、サーバーを指している velocity.toml の [servers] のエントリーです。
+type ProxyEntry struct {
+	Proxy   string
+	Config  string
+	Key     string
+	Address string
+}
+
+// Candidate は、管理下に置くサーバーについて調べた結果です。
+type Candidate struct {
+	// Dir はサーバーディレクトリです (カレントディレクトリからの相対パス)。
+	Dir  string
+	Name string
+	Type string
+	// MinecraftVersion と Memory は Dockerfile の ENV の値です。
+	MinecraftVersion string
+	Memory           string
+	Address          string
+	// Env は Dockerfile の ENV です。
+	Env map[string]string
+
+	ComposePath string
+	Service     string
+	// Context は docker-compose.yml に書かれている build.context です。
+	Context string
+
+	Proxies []ProxyEntry
+	// Notes は、自動では直さない食い違いの説明です。
+	Notes []string
+}
+
+// Server returns the servers.json entry for the candidate.
+func (c *Candidate) Server() server.Server {
+	s := server.Server{Name: c.Name, Type: c.Type, Address: c.Address}
+	s.SetRuntime(c.Env)
+	now := time.Now().UTC().Truncate(time.Second)
+	s.CreatedAt = &now
+	for _, e := range c.Proxies {
+		s.Proxies = append(s.Proxies, e.Proxy)
+	}
+	return s
+}
+
+// Inspect は dir を調べ、servers.json に登録する内容を推定します。
+func Inspect(dir string, opts Options) (*Candidate, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := vfs.Stat(abs); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("ディレクトリ %s が見つかりません", dir)
+	}
+	c := &Candidate{Dir: relative(abs)}
+
+	env, err := server.ReadDockerfileEnv(abs)
+	if err != nil {
+		return nil, err
+	}
+	c.Type = strings.ToLower(opts.Type)
+	if c.Type == "" {
+		// itzg/minecraft-server は TYPE を省略すると VANILLA になる
+		c.Type = strings.ToLower(env["TYPE"])
+		if c.Type == "" {
+			c.Type = "vanilla"
+		}
+	}
+	if _, err := server.GetServerType(c.Type); err != nil {
+		return nil, fmt.Errorf("%w (--type で指定できます)", err)
+	}
+	c.Env = env
+	c.MinecraftVersion = env["VERSION"]
+	c.Memory = env["MEMORY"]
+	if c.Memory == "" {
+		c.Memory = env["MAX_MEMORY"]
+	}
+
+	for _, path := range opts.ComposePaths {
+		service, context, err := findService(path, abs)
+		if err != nil {
+			return nil, err
+		}
+		if service != "" {
+			c.ComposePath, c.Service, c.Context = path, service, context
+			break
+		}
+	}
+	if c.Service == "" {
+		return nil, fmt.Errorf("%s をビルドコンテキストにしているサービスが %s に見つかりません", c.Dir, strings.Join(opts.ComposePaths, ", "))
+	}
+	// mcctl はサーバー名をサービス名として start や stop を行うため、名前はサービス名にそろえる
+	c.Name = c.Service
+	c.Address = c.Service + ":25565"
+
+	for _, p := range opts.Proxies {
+		entry, found, err := findProxyEntry(p, c.Service)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			c.Notes = append(c.Notes, fmt.Sprintf("%s (%s) にサービス %s を指すエントリーがありません。mcctl doctor --fix で追加できます", p.Name, p.Config, c.Service))
+			continue
+		}
+		c.Proxies = append(c.Proxies, entry)
+		if entry.Key != c.Name {
+			c.Notes = append(c.Notes, fmt.Sprintf("%s では %s という名前で登録されています (%s = '%s')。プレイヤーが使う名前を変えないよう、velocity.toml は変更しません", p.Name, entry.Key, entry.Key, entry.Address))
+		}
+	}
+	if len(c.Proxies) > 0 {
+		c.Address = c.Proxies[0].Address
+	}
+	return c, nil
+}
+
+// ManagedDir は、サーバーを移動する先の <MinecraftDir>/servers/<name> です。
+func (c *Candidate) ManagedDir() string {
+	return filepath.Join(server.MinecraftDir, "servers", c.Name)
+}
+
+// InManagedLayout reports whether mcctl finds the directory by the server name,
+// either in <MinecraftDir>/servers/<name> or the legacy <MinecraftDir>/<name>.
+func (c *Candidate) InManagedLayout() bool {
+	return filepath.Clean(server.ServerDirectory(c.Name)) == filepath.Clean(c.Dir)
+}
+
+// Move は、ディレクトリを ManagedDir に移動し、サービスの build.context と volumes のパスを書き換えます。
+// docker-compose.yml はサービスの行だけを書き換えるため、コメントや他のサービスの書式は変わりません。
+func (c *Candidate) Move() error {
+	dst := c.ManagedDir()
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("移動先 %s がすでに存在します", dst)
+	}
+	data, err := vfs.ReadFile(c.ComposePath)
+	if err != nil {
+		return fmt.Errorf("%s の読み込みに失敗しました: %w", c.ComposePath, err)
+	}
+	rel, err := filepath.Rel(filepath.Dir(c.ComposePath), dst)
+	if err != nil {
+		return err
+	}
+	updated, err := rewriteServicePaths(data, c.Service, c.Context, "./"+filepath.ToSlash(rel))
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.ComposePath, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(c.Dir, dst); err != nil {
+		return fmt.Errorf("%s の移動に失敗しました: %w", c.Dir, err)
+	}
+	if err := vfs.WriteFile(c.ComposePath, updated, 0644); err != nil {
+		// docker-compose.yml と食い違わないよう、ディレクトリを元に戻す
+		if rerr := os.Rename(dst, c.Dir); rerr != nil {
+			return fmt.Errorf("%s の書き込みに失敗し、%s を元に戻せませんでした: %v: %w", c.ComposePath, c.Dir, rerr, err)
+		}
+		return fmt.Errorf("%s の書き込みに失敗しました: %w", c.ComposePath, err)
+	}
+	c.Dir = dst
+	return nil
+}
+
+// findService は、ビルドコンテキストが dir のサービスの名前と、書かれている build.context を返します。
+func findService(composePath, dir string) (string, string, error) {
+	data, err := vfs.ReadFile(composePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("%s の読み込みに失敗しました: %w", composePath, err)
+	}
+	var compose struct {
+		Services map[string]struct {
+			Build interface{} `yaml:"build"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return "", "", fmt.Errorf("%s のパースに失敗しました: %w", composePath, err)
+	}
+	base, err := filepath.Abs(filepath.Dir(composePath))
+	if err != nil {
+		return "", "", err
+	}
+
+	names := make([]string, 0, len(compose.Services))
+	for name := range compose.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var context string
+		switch b := compose.Services[name].Build.(type) {
+		case string:
+			context = b
+		case map[string]interface{}:
+			context, _ = b["context"].(string)
+		}
+		if context != "" && filepath.Join(base, context) == dir {
+			return name, context, nil
+		}
+	}
+	return "", "", nil
+}
+
+// findProxyEntry は、アドレスのホストが service の [servers] のエントリーを探します。
+// サービスと同じ名前のエントリーがあればそれを優先します。
+func findProxyEntry(p Proxy, service string) (ProxyEntry, bool, error) {
+	data, err := vfs.ReadFile(p.Config)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ProxyEntry{}, false, nil
+		}
+		return ProxyEntry{}, false, fmt.Errorf("%s の読み込みに失敗しました: %w", p.Config, err)
+	}
+	var config struct {
+		Servers map[string]interface{} `toml:"servers"`
+	}
+	if err := toml.Unmarshal(data, &config); err != nil {
+		return ProxyEntry{}, false, fmt.Errorf("%s のパースに失敗しました: %w", p.Config, err)
+	}
+
+	var keys []string
+	for key, v := range config.Servers {
+		addr, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if host, _, _ := strings.Cut(addr, ":"); host == service {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return ProxyEntry{}, false, nil
+	}
+	sort.Strings(keys)
+	key := keys[0]
+	for _, k := range keys {
+		if k == service {
+			key = k
+		}
+	}
+	return ProxyEntry{Proxy: p.Name, Config: p.Config, Key: key, Address: config.Servers[key].(string)}, true, nil
+}
+
+// rewriteServicePaths は、service の定義の行にある oldPath を newPath に置き換えます。
+func rewriteServicePaths(data []byte, service, oldPath, newPath string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("パースに失敗しました: %w", err)
+	}
+	first, last := server.ComposeServiceLines(&doc, service)
+	if first == 0 {
+		return nil, fmt.Errorf("サービス %s が見つかりません", service)
+	}
+
+	oldPath = strings.TrimSuffix(oldPath, "/")
+	// パスの区切りまで一致するものだけを置き換える (./large が ./large-paper に一致しないように)
+	pattern := regexp.MustCompile(`(^|[\s'"])` + regexp.QuoteMeta(oldPath) + `([/:'"\s]|$)`)
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for i := first - 1; i < last && i < len(lines); i++ {
+		lines[i] = pattern.ReplaceAll(lines[i], []byte("${1}"+newPath+"${2}"))
+	}
+	return bytes.Join(lines, nil), nil
+}
+
+func relative(abs string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return abs
+	}
+	if rel, err := filepath.Rel(wd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return abs
+}
@@ -0,0 +1,313 @@
+package adopt
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mcctl/internal/server"
+)
+
+const testCompose = `services:
+  # 手作業で作ったサーバー
+  large:
+    build: ./large
+    volumes:
+      - ./large/world:/data/world
+      - ./large/plugins:/data/plugins
+  large-paper:
+    build:
+      context: ./large-paper
+      dockerfile: Dockerfile
+    volumes:
+      - "./large-paper/world:/data/world"
+  lobby:
+    build:
+      context: ./servers/lobby/
+networks:
+  home-network:
+    external: true
+`
+
+const testVelocity = `[servers]
+large-world = 'large:25565'
+large-paper = 'large-paper:25565'
+lobby = 'lobby:25565'
+old-lobby = 'lobby:25565'
+try = ['lobby']
+`
+
+// setupAdopt は MinecraftDir をテスト用のディレクトリに切り替え、files (MinecraftDir からの相対パス) を書き込みます。
+func setupAdopt(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	old := server.MinecraftDir
+	server.MinecraftDir = dir
+	t.Cleanup(func() { server.MinecraftDir = old })
+	for rel, content := range files {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestInspect(t *testing.T) {
+	dir := setupAdopt(t, map[string]string{
+		"docker-compose.yml":         testCompose,
+		"velocity/velocity.toml":     testVelocity,
+		"lobby/velocity.toml":        "[servers]\nlobby = 'lobby:25565'\n",
+		"large/Dockerfile":           "FROM itzg/minecraft-server\nENV TYPE=PAPER\nENV VERSION 1.20.4\nENV MAX_MEMORY=\"8G\"\n",
+		"large-paper/Dockerfile":     "FROM itzg/minecraft-server\nENV TYPE=SPIGOT\n",
+		"servers/lobby/Dockerfile":   "FROM itzg/minecraft-server\nENV MEMORY=2G\nENV MAX_MEMORY=4G\n",
+		"unused/Dockerfile":          "FROM itzg/minecraft-server\n",
+		"nodockerfile/server.jar":    "",
+		"other/docker-compose.yml":   "services:\n  unused:\n    build: ../unused\n",
+		"broken/docker-compose.yml":  "services: [\n",
+		"broken-proxy/velocity.toml": "[servers\n",
+	})
+	compose := filepath.Join(dir, "docker-compose.yml")
+	velocity := Proxy{Name: "velocity", Config: filepath.Join(dir, "velocity/velocity.toml")}
+	lobbyProxy := Proxy{Name: "lobby-proxy", Config: filepath.Join(dir, "lobby/velocity.toml")}
+
+	tests := []struct {
+		name      string
+		dir       string
+		opts      Options
+		want      Candidate
+		wantNotes []string
+		wantErr   string
+	}{
+		{
+			// サーバー名はサービス名、アドレスは Velocity のエントリーのもの
+			name: "Dockerfile の ENV からタイプとバージョンを推定する",
+			dir:  "large",
+			opts: Options{ComposePaths: []string{compose}, Proxies: []Proxy{velocity}},
+			want: Candidate{
+				Name: "large", Type: "paper", MinecraftVersion: "1.20.4", Memory: "8G", Address: "large:25565",
+				ComposePath: compose, Service: "large", Context: "./large",
+				Proxies: []ProxyEntry{{Proxy: "velocity", Config: velocity.Config, Key: "large-world", Address: "large:25565"}},
+			},
+			wantNotes: []string{"large-world という名前で登録されています"},
+		},
+		{
+			name: "TYPE がなければ vanilla",
+			dir:  "servers/lobby",
+			opts: Options{ComposePaths: []string{compose}, Proxies: []Proxy{velocity, lobbyProxy}},
+			want: Candidate{
+				Name: "lobby", Type: "vanilla", Memory: "2G", Address: "lobby:25565",
+				ComposePath: compose, Service: "lobby", Context: "./servers/lobby/",
+				// サービスと同じ名前のエントリーを優先する
+				Proxies: []ProxyEntry{
+					{Proxy: "velocity", Config: velocity.Config, Key: "lobby", Address: "lobby:25565"},
+					{Proxy: "lobby-proxy", Config: lobbyProxy.Config, Key: "lobby", Address: "lobby:25565"},
+				},
+			},
+		},
+		{
+			name: "--type が Dockerfile より優先される",
+			dir:  "large-paper",
+			opts: Options{ComposePaths: []string{compose}, Type: "Paper"},
+			want: Candidate{
+				Name: "large-paper", Type: "paper", Address: "large-paper:25565",
+				ComposePath: compose, Service: "large-paper", Context: "./large-paper",
+			},
+		},
+		{
+			name: "最初に見つかった docker-compose.yml を使う",
+			dir:  "unused",
+			opts: Options{
+				ComposePaths: []string{filepath.Join(dir, "missing.yml"), compose, filepath.Join(dir, "other/docker-compose.yml")},
+				Proxies:      []Proxy{velocity, {Name: "none", Config: filepath.Join(dir, "missing.toml")}},
+			},
+			want: Candidate{
+				Name: "unused", Type: "vanilla", Address: "unused:25565",
+				ComposePath: filepath.Join(dir, "other/docker-compose.yml"), Service: "unused", Context: "../unused",
+			},
+			wantNotes: []string{"velocity (", "none ("},
+		},
+		{name: "未知のタイプ", dir: "large-paper", opts: Options{ComposePaths: []string{compose}}, wantErr: "--type"},
+		{name: "ディレクトリがない", dir: "missing", opts: Options{ComposePaths: []string{compose}}, wantErr: "見つかりません"},
+		{name: "Dockerfile がない", dir: "nodockerfile", opts: Options{ComposePaths: []string{compose}}, wantErr: "Dockerfile"},
+		{name: "サービスがない", dir: "unused", opts: Options{ComposePaths: []string{compose}}, wantErr: "サービスが"},
+		{
+			name:    "パースできない docker-compose.yml",
+			dir:     "unused",
+			opts:    Options{ComposePaths: []string{filepath.Join(dir, "broken/docker-compose.yml")}},
+			wantErr: "パース",
+		},
+		{
+			name:    "パースできない velocity.toml",
+			dir:     "large",
+			opts:    Options{ComposePaths: []string{compose}, Proxies: []Proxy{{Name: "broken", Config: filepath.Join(dir, "broken-proxy/velocity.toml")}}},
+			wantErr: "パース",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Inspect(filepath.Join(dir, tt.dir), tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Inspect() = %+v, %v, want %q を含むエラー", c, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Dir != filepath.Join(dir, tt.dir) {
+				t.Errorf("Dir = %q, want %q", c.Dir, filepath.Join(dir, tt.dir))
+			}
+			notes := c.Notes
+			got := *c
+			got.Dir, got.Env, got.Notes = "", nil, nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inspect() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+			if len(notes) != len(tt.wantNotes) {
+				t.Fatalf("Notes = %q, want %q", notes, tt.wantNotes)
+			}
+			for i, want := range tt.wantNotes {
+				if !strings.Contains(notes[i], want) {
+					t.Errorf("Notes[%d] = %q, want %q を含む", i, notes[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCandidateServer(t *testing.T) {
+	c := &Candidate{
+		Name:    "large",
+		Type:    "forge",
+		Address: "large:25565",
+		Env:     map[string]string{"VERSION": "1.20.1", "FORGE_VERSION": "47.2.0", "MAX_MEMORY": "8G"},
+		Proxies: []ProxyEntry{{Proxy: "velocity"}, {Proxy: "lobby-proxy"}},
+	}
+	s := c.Server()
+	if s.CreatedAt == nil {
+		t.Fatal("createdAt がありません")
+	}
+	s.CreatedAt = nil
+	want := server.Server{
+		Name: "large", Type: "forge", Address: "large:25565",
+		MCVersion: "1.20.1", LoaderVersion: "47.2.0", Memory: "8G",
+		Proxies: []string{"velocity", "lobby-proxy"},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("Server() = %+v, want %+v", s, want)
+	}
+}
+
+func TestRewriteServicePaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		service string
+		oldPath string
+		want    string
+		wantErr bool
+	}{
+		{
+			// 同じ接頭辞の large-paper や、他のサービス、コメントは変えない
+			name:    "文字列の build",
+			service: "large",
+			oldPath: "./large",
+			want:    strings.NewReplacer("build: ./large\n", "build: ./servers/large\n", "- ./large/", "- ./servers/large/").Replace(testCompose),
+		},
+		{
+			name:    "引用符で囲んだボリューム",
+			service: "large-paper",
+			oldPath: "./large-paper",
+			want:    strings.NewReplacer("context: ./large-paper", "context: ./servers/large", `"./large-paper/`, `"./servers/large/`).Replace(testCompose),
+		},
+		{
+			name:    "末尾の / は無視する",
+			service: "lobby",
+			oldPath: "./servers/lobby/",
+			want:    strings.Replace(testCompose, "context: ./servers/lobby/", "context: ./servers/large/", 1),
+		},
+		{name: "サービスがない", service: "creative", oldPath: "./creative", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rewriteServicePaths([]byte(testCompose), tt.service, tt.oldPath, "./servers/large")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("rewriteServicePaths() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("rewriteServicePaths() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMove(t *testing.T) {
+	dir := setupAdopt(t, map[string]string{
+		"docker-compose.yml": testCompose,
+		"large/Dockerfile":   "FROM itzg/minecraft-server\nENV TYPE=PAPER\n",
+		"large/world/level":  "level",
+	})
+	compose := filepath.Join(dir, "docker-compose.yml")
+	c, err := Inspect(filepath.Join(dir, "large"), Options{ComposePaths: []string{compose}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// <MinecraftDir>/<name> は mcctl が見つけられる古い配置
+	if !c.InManagedLayout() {
+		t.Error("InManagedLayout() = false, want true")
+	}
+	if c.ManagedDir() != filepath.Join(dir, "servers", "large") {
+		t.Errorf("ManagedDir() = %q", c.ManagedDir())
+	}
+
+	if err := c.Move(); err != nil {
+		t.Fatal(err)
+	}
+	managed := filepath.Join(dir, "servers", "large")
+	if c.Dir != managed || !c.InManagedLayout() {
+		t.Errorf("Dir = %q, InManagedLayout() = %v, want %q, true", c.Dir, c.InManagedLayout(), managed)
+	}
+	if data, _ := os.ReadFile(filepath.Join(managed, "world", "level")); string(data) != "level" {
+		t.Errorf("world が移動していません: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "large")); !os.IsNotExist(err) {
+		t.Error("移動元のディレクトリが残っています")
+	}
+	data, _ := os.ReadFile(compose)
+	if want := strings.NewReplacer("build: ./large\n", "build: ./servers/large\n", "- ./large/", "- ./servers/large/").Replace(testCompose); string(data) != want {
+		t.Errorf("docker-compose.yml =\n%s\nwant\n%s", data, want)
+	}
+
+	// 移動先がすでにある場合は何も変えない
+	os.MkdirAll(filepath.Join(dir, "large-paper"), 0755)
+	os.WriteFile(filepath.Join(dir, "large-paper", "Dockerfile"), []byte("ENV TYPE=PAPER\n"), 0644)
+	other, err := Inspect(filepath.Join(dir, "large-paper"), Options{ComposePaths: []string{compose}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Name = "large"
+	if other.InManagedLayout() {
+		t.Error("サービス名と違うディレクトリが管理下の配置になっています")
+	}
+	if err := other.Move(); err == nil || !strings.Contains(err.Error(), "すでに存在します") {
+		t.Errorf("Move() = %v, want 移動先が存在するエラー", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "large-paper", "Dockerfile")); err != nil {
+		t.Errorf("移動元が変わりました: %v", err)
+	}
+	if after, _ := os.ReadFile(compose); string(after) != string(data) {
+		t.Error("移動に失敗したのに docker-compose.yml が変わりました")
+	}
+}
@@ -0,0 +1,298 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayout はバックアップファイル名に埋め込むタイムスタンプの形式です。
+const timeLayout = "20060102-150405"
+
+// archiveExt はバックアップファイルの拡張子です。
+const archiveExt = ".tar.gz"
+
+// Backup は、バックアップディレクトリ内の1つのアーカイブを表します。
+type Backup struct {
+	Server    string
+	Path      string
+	CreatedAt time.Time
+	Size      int64
+
+	// seq は同じ秒に作られたバックアップの順番です。
+	seq int
+}
+
+// ServerBackupDir returns the directory that holds the archives of a server.
+func ServerBackupDir(backupRoot, serverName string) string {
+	return filepath.Join(backupRoot, serverName)
+}
+
+// Create は、サーバーディレクトリを tar.gz にまとめてバックアップディレクトリに保存します。
+func Create(backupRoot, serverName, serverDir string, now time.Time) (Backup, error) {
+	dir := ServerBackupDir(backupRoot, serverName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Backup{}, fmt.Errorf("バックアップディレクトリの作成に失敗しました: %w", err)
+	}
+
+	// 同じ秒に作ったバックアップを上書きしないよう、既にある場合は -2, -3, ... を付ける
+	var path string
+	var f *os.File
+	var seq int
+	for seq = 1; ; seq++ {
+		path = filepath.Join(dir, archiveName(serverName, now, seq))
+		var err error
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return Backup{}, fmt.Errorf("バックアップファイルの作成に失敗しました: %w", err)
+		}
+	}
+
+	if err := writeArchive(f, serverDir); err != nil {
+		f.Close()
+		os.Remove(path)
+		return Backup{}, fmt.Errorf("アーカイブの書き込みに失敗しました: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return Backup{}, fmt.Errorf("バックアップファイルのクローズに失敗しました: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Backup{}, err
+	}
+	return Backup{Server: serverName, Path: path, CreatedAt: now, Size: info.Size(), seq: seq}, nil
+}
+
+// archiveName は、seq 番目 (1 から) のバックアップファイル名を返します。
+// 1 番目は従来どおり <server>-<timestamp>.tar.gz で、2 番目以降は <server>-<timestamp>-<seq>.tar.gz です。
+func archiveName(serverName string, t time.Time, seq int) string {
+	if seq <= 1 {
+		return fmt.Sprintf("%s-%s%s", serverName, t.Format(timeLayout), archiveExt)
+	}
+	return fmt.Sprintf("%s-%s-%d%s", serverName, t.Format(timeLayout), seq, archiveExt)
+}
+
+// parseStamp は、ファイル名のタイムスタンプ部分から作成日時と番号を返します。
+func parseStamp(stamp string) (time.Time, int, bool) {
+	seq := 1
+	if len(stamp) > len(timeLayout) {
+		n, err := strconv.Atoi(strings.TrimPrefix(stamp[len(timeLayout):], "-"))
+		if stamp[len(timeLayout)] != '-' || err != nil || n < 2 {
+			return time.Time{}, 0, false
+		}
+		stamp, seq = stamp[:len(timeLayout)], n
+	}
+	t, err := time.ParseInLocation(timeLayout, stamp, time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return t, seq, true
+}
+
+// writeArchive writes srcDir as a gzip-compressed tarball to w.
+func writeArchive(w io.Writer, srcDir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Extract は、バックアップアーカイブを dstDir に展開します。
+func Extract(archivePath, dstDir string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("バックアップファイルを開けませんでした: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("アーカイブの展開に失敗しました: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("アーカイブの読み込みに失敗しました: %w", err)
+		}
+
+		target := filepath.Join(dstDir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dstDir)+string(os.PathSeparator)) {
+			return fmt.Errorf("不正なパスを含むアーカイブです: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Restore は、dstDir をバックアップアーカイブの内容で置き換えます。
+// 展開に失敗した場合、dstDir は変更しません。
+func Restore(archivePath, dstDir string) error {
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dstDir), "."+filepath.Base(dstDir)+"-restore-")
+	if err != nil {
+		return fmt.Errorf("一時ディレクトリの作成に失敗しました: %w", err)
+	}
+	// MkdirTemp は 0700 で作成するため、サーバーディレクトリと同じ権限に戻す
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	if err := Extract(archivePath, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+
+	oldDir := tmpDir + "-old"
+	if err := os.Rename(dstDir, oldDir); err != nil && !os.IsNotExist(err) {
+		os.RemoveAll(tmpDir)
+		return fmt.Errorf("%s の退避に失敗しました: %w", dstDir, err)
+	}
+	if err := os.Rename(tmpDir, dstDir); err != nil {
+		os.Rename(oldDir, dstDir)
+		os.RemoveAll(tmpDir)
+		return fmt.Errorf("%s の置き換えに失敗しました: %w", dstDir, err)
+	}
+	return os.RemoveAll(oldDir)
+}
+
+// List は、サーバーのバックアップを新しい順に返します。
+// 命名規則に合わないファイルは無視します。
+func List(backupRoot, serverName string) ([]Backup, error) {
+	dir := ServerBackupDir(backupRoot, serverName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("バックアップディレクトリの読み込みに失敗しました: %w", err)
+	}
+
+	prefix := serverName + "-"
+	var backups []Backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, archiveExt) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), archiveExt)
+		createdAt, seq, ok := parseStamp(stamp)
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{
+			Server:    serverName,
+			Path:      filepath.Join(dir, name),
+			CreatedAt: createdAt,
+			Size:      info.Size(),
+			seq:       seq,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[j].CreatedAt)
+		}
+		return backups[i].seq > backups[j].seq
+	})
+	return backups, nil
+}
+
+// ListServers returns the names of all servers that have a backup directory.
+func ListServers(backupRoot string) ([]string, error) {
+	entries, err := os.ReadDir(backupRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("バックアップディレクトリの読み込みに失敗しました: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
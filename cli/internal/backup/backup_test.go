@@ -0,0 +1,59 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCreateSameSecond(t *testing.T) {
+	root := t.TempDir()
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "level.dat"), []byte("level"), 0644)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+
+	var paths []string
+	for i := 0; i < 3; i++ {
+		b, err := Create(root, "survival", src, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, b.Path)
+	}
+	want := []string{
+		"survival-20240501-120000.tar.gz",
+		"survival-20240501-120000-2.tar.gz",
+		"survival-20240501-120000-3.tar.gz",
+	}
+	for i, path := range paths {
+		if filepath.Base(path) != want[i] {
+			t.Errorf("Create() #%d = %s, want %s", i+1, filepath.Base(path), want[i])
+		}
+	}
+
+	// 命名規則に合わないファイルは無視する
+	dir := ServerBackupDir(root, "survival")
+	os.WriteFile(filepath.Join(dir, "survival-20240501-120000-x.tar.gz"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "survival-20240501-120000-1.tar.gz"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "survival-20240430-090000.tar.gz"), nil, 0644)
+
+	backups, err := List(root, "survival")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		"survival-20240501-120000-3.tar.gz",
+		"survival-20240501-120000-2.tar.gz",
+		"survival-20240501-120000.tar.gz",
+		"survival-20240430-090000.tar.gz",
+	}
+	if len(backups) != len(want) {
+		t.Fatalf("List() = %d backups, want %d", len(backups), len(want))
+	}
+	for i, b := range backups {
+		if filepath.Base(b.Path) != want[i] {
+			t.Errorf("List()[%d] = %s, want %s", i, filepath.Base(b.Path), want[i])
+		}
+	}
+}
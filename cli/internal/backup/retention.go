@@ -0,0 +1,138 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Policy は、grandfather-father-son 方式の保持ルールです。
+// 各値は、その粒度で何期間分のバックアップを残すかを表します。
+type Policy struct {
+	KeepHourly  int `json:"keepHourly"`
+	KeepDaily   int `json:"keepDaily"`
+	KeepWeekly  int `json:"keepWeekly"`
+	KeepMonthly int `json:"keepMonthly"`
+}
+
+// DefaultPolicy is used when neither flags nor the retention file specify a policy.
+var DefaultPolicy = Policy{
+	KeepHourly:  24,
+	KeepDaily:   7,
+	KeepWeekly:  4,
+	KeepMonthly: 6,
+}
+
+// RetentionConfig は、保持ルールの設定ファイルの構造体です。
+// Servers に定義があるサーバーはそちらが優先され、それ以外は Default が使われます。
+type RetentionConfig struct {
+	Default *Policy           `json:"default,omitempty"`
+	Servers map[string]Policy `json:"servers,omitempty"`
+}
+
+// LoadRetentionConfig は保持ルールの設定ファイルを読み込みます。
+// ファイルが存在しない場合は空の設定を返します。
+func LoadRetentionConfig(path string) (RetentionConfig, error) {
+	var config RetentionConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return config, fmt.Errorf("保持ルール設定の読み込みに失敗しました: %w", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("保持ルール設定のパースに失敗しました: %w", err)
+	}
+	return config, nil
+}
+
+// PolicyFor returns the policy that applies to serverName.
+func (c RetentionConfig) PolicyFor(serverName string) Policy {
+	if p, ok := c.Servers[serverName]; ok {
+		return p
+	}
+	if c.Default != nil {
+		return *c.Default
+	}
+	return DefaultPolicy
+}
+
+// Select は backups を残すものと削除するものに振り分けます。
+// backups は新しい順に並んでいる必要があります。
+// 誤ってすべてを消さないよう、最新のバックアップは常に残します。
+func (p Policy) Select(backups []Backup) (keep, remove []Backup) {
+	kept := make([]bool, len(backups))
+	if len(backups) > 0 {
+		kept[0] = true
+	}
+
+	rules := []struct {
+		count  int
+		period func(time.Time) string
+	}{
+		{p.KeepHourly, func(t time.Time) string { return t.Format("2006-01-02 15") }},
+		{p.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{p.KeepWeekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{p.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+
+	for _, rule := range rules {
+		seen := 0
+		last := ""
+		for i, b := range backups {
+			if seen >= rule.count {
+				break
+			}
+			key := rule.period(b.CreatedAt)
+			if key == last {
+				continue
+			}
+			last = key
+			kept[i] = true
+			seen++
+		}
+	}
+
+	for i, b := range backups {
+		if kept[i] {
+			keep = append(keep, b)
+		} else {
+			remove = append(remove, b)
+		}
+	}
+	return keep, remove
+}
+
+// Prune は、ポリシーに従って不要になったバックアップを削除します。
+// dryRun が true の場合は削除対象を返すだけでファイルは削除しません。
+// 削除に失敗したものがあった場合は、削除できたバックアップとエラーを返します。
+func Prune(backupRoot, serverName string, policy Policy, dryRun bool) ([]Backup, error) {
+	backups, err := List(backupRoot, serverName)
+	if err != nil {
+		return nil, err
+	}
+
+	_, remove := policy.Select(backups)
+	if dryRun {
+		return remove, nil
+	}
+
+	// 1つ削除できなくても残りは削除し、削除できたものとエラーの両方を返す
+	var removed []Backup
+	var errs []error
+	for _, b := range remove {
+		if err := os.Remove(b.Path); err != nil {
+			errs = append(errs, fmt.Errorf("バックアップ %s の削除に失敗しました: %w", b.Path, err))
+			continue
+		}
+		removed = append(removed, b)
+	}
+	return removed, errors.Join(errs...)
+}
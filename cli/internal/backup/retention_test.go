@@ -0,0 +1,121 @@
+package backup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPolicySelect(t *testing.T) {
+	const layout = "2006-01-02 15:04"
+	// days は from から n 日分、毎日 hour 時のバックアップの時刻を返します
+	days := func(from string, n int, hour int) []string {
+		start, _ := time.Parse("2006-01-02", from)
+		var times []string
+		for i := 0; i < n; i++ {
+			times = append(times, start.AddDate(0, 0, i).Add(time.Duration(hour)*time.Hour).Format(layout))
+		}
+		return times
+	}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		times  []string // 古い順
+		keep   []string // 新しい順
+	}{
+		{
+			name:   "zero policy keeps only the newest",
+			policy: Policy{},
+			times:  []string{"2024-01-01 00:00", "2024-01-02 00:00", "2024-01-03 00:00"},
+			keep:   []string{"2024-01-03 00:00"},
+		},
+		{
+			name:   "no backups",
+			policy: DefaultPolicy,
+			times:  nil,
+			keep:   nil,
+		},
+		{
+			name:   "hourly keeps the newest of each hour",
+			policy: Policy{KeepHourly: 2},
+			times:  []string{"2024-01-01 08:30", "2024-01-01 09:00", "2024-01-01 09:30", "2024-01-01 10:00", "2024-01-01 10:30"},
+			keep:   []string{"2024-01-01 10:30", "2024-01-01 09:30"},
+		},
+		{
+			name:   "daily",
+			policy: Policy{KeepDaily: 3},
+			times: []string{
+				"2024-01-01 06:00", "2024-01-01 18:00",
+				"2024-01-02 06:00", "2024-01-02 18:00",
+				"2024-01-03 06:00", "2024-01-03 18:00",
+				"2024-01-04 06:00", "2024-01-04 18:00",
+			},
+			keep: []string{"2024-01-04 18:00", "2024-01-03 18:00", "2024-01-02 18:00"},
+		},
+		{
+			name:   "weekly uses ISO weeks",
+			policy: Policy{KeepWeekly: 2},
+			// 2024-01-01 は月曜日なので、1日から21日は W01, W02, W03
+			times: days("2024-01-01", 21, 3),
+			keep:  []string{"2024-01-21 03:00", "2024-01-14 03:00"},
+		},
+		{
+			name:   "weekly across the year boundary",
+			policy: Policy{KeepWeekly: 2},
+			// 2024-12-30 (月) は 2025-W01、2024-12-29 (日) は 2024-W52
+			times: []string{"2024-12-28 00:00", "2024-12-29 00:00", "2024-12-30 00:00", "2025-01-02 00:00"},
+			keep:  []string{"2025-01-02 00:00", "2024-12-29 00:00"},
+		},
+		{
+			name:   "monthly",
+			policy: Policy{KeepMonthly: 2},
+			times:  []string{"2024-01-01 00:00", "2024-01-15 00:00", "2024-02-01 00:00", "2024-02-15 00:00", "2024-03-01 00:00", "2024-03-15 00:00"},
+			keep:   []string{"2024-03-15 00:00", "2024-02-15 00:00"},
+		},
+		{
+			name:   "overlapping buckets keep the union",
+			policy: Policy{KeepHourly: 2, KeepDaily: 2, KeepWeekly: 2, KeepMonthly: 3},
+			times: []string{
+				"2024-01-10 12:00", // 1月の最後、W02
+				"2024-02-05 12:00", // 2月の最後、W06
+				"2024-02-26 12:00", // W09
+				"2024-03-03 12:00", // W09 の最後
+				"2024-03-04 09:00", // W10
+				"2024-03-04 10:00",
+				"2024-03-04 10:30", // 3月4日 10時台の最後
+			},
+			keep: []string{
+				"2024-03-04 10:30", // 最新、hourly、daily、weekly、monthly
+				"2024-03-04 09:00", // hourly
+				"2024-03-03 12:00", // daily、weekly
+				"2024-02-26 12:00", // monthly (2月の最新)
+				"2024-01-10 12:00", // monthly
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var backups []Backup
+			for i := len(tt.times) - 1; i >= 0; i-- {
+				created, err := time.Parse(layout, tt.times[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				backups = append(backups, Backup{Path: tt.times[i], CreatedAt: created})
+			}
+
+			keep, remove := tt.policy.Select(backups)
+			var got []string
+			for _, b := range keep {
+				got = append(got, b.Path)
+			}
+			if !reflect.DeepEqual(got, tt.keep) {
+				t.Errorf("keep = %v, want %v", got, tt.keep)
+			}
+			if len(keep)+len(remove) != len(backups) {
+				t.Errorf("keep %d + remove %d != %d", len(keep), len(remove), len(backups))
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+// Package cluster は cluster.yaml に書いたサーバー構成を、servers.json、docker-compose.yml、
+// velocity.toml、サーバーディレクトリに反映します。
+//
+// Apply は何度実行しても同じ結果になるように、ファイルを cluster.yaml から組み立て直します。
+// cluster.yaml から消えたサーバーは servers.json、サービス、プロキシから外しますが、
+// ワールドなどのデータを失わないよう、サーバーディレクトリは削除しません。
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"mcctl/internal/server"
+	"mcctl/internal/vfs"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec は cluster.yaml の内容です。
+type Spec struct {
+	Servers []ServerSpec `yaml:"servers"`
+	// Proxies を省略した場合は mcctl.yaml の proxies を使います。
+	Proxies []ProxySpec `yaml:"proxies"`
+}
+
+// ServerSpec は1台のサーバーの望ましい状態です。
+type ServerSpec struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	// Address を省略した場合は <name>:<defaults.port> です。
+	Address string `yaml:"address"`
+	// Hostnames は forced-hosts に登録するホスト名です。省略した場合は <name>.<domain> です。
+	Hostnames []string `yaml:"hostnames"`
+	// Overlays はサーバーディレクトリを作成するときに重ねるオーバーレイです。
+	Overlays []string `yaml:"overlays"`
+	// Environment はサーバータイプの既定の環境変数を上書きします。
+	Environment map[string]string `yaml:"environment"`
+	// Volumes はサーバータイプの既定のボリュームに追加されます。
+	Volumes []string `yaml:"volumes"`
+}
+
+// ProxySpec は、サーバーを登録する Velocity です。
+type ProxySpec struct {
+	Name   string `yaml:"name"`
+	Config string `yaml:"config"`
+	// Try を指定すると velocity.toml の try をこの順に置き換えます。
+	Try []string `yaml:"try"`
+}
+
+// Options は cluster.yaml にない、反映先のファイルと既定値です。
+type Options struct {
+	ServersPath string
+	ComposePath string
+	Domain      string
+	Port        int
+	Proxies     []ProxySpec
+}
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Load は cluster.yaml を読み込んで検証します。
+func Load(path string) (*Spec, error) {
+	data, err := vfs.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s の読み込みに失敗しました: %w", path, err)
+	}
+	var spec Spec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s のパースに失敗しました: %w", path, err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &spec, nil
+}
+
+// Validate は、名前の重複や存在しないサーバータイプ・オーバーレイを検出します。
+func (s *Spec) Validate() error {
+	seen := make(map[string]bool)
+	hosts := make(map[string]string)
+	for _, srv := range s.Servers {
+		if !namePattern.MatchString(srv.Name) {
+			return fmt.Errorf("不正なサーバー名です: %q (英小文字、数字、-、_ が使えます)", srv.Name)
+		}
+		if seen[srv.Name] {
+			return fmt.Errorf("サーバー %s が複数回定義されています", srv.Name)
+		}
+		seen[srv.Name] = true
+
+		if srv.Type == "" {
+			return fmt.Errorf("サーバー %s の type を指定してください", srv.Name)
+		}
+		if _, err := server.GetServerType(srv.Type); err != nil {
+			return fmt.Errorf("サーバー %s: %w", srv.Name, err)
+		}
+		for _, o := range srv.Overlays {
+			if _, err := vfs.Stat(fmt.Sprintf("%s/%s", server.OverlayRoot(), o)); err != nil {
+				return fmt.Errorf("サーバー %s: オーバーレイ %s が見つかりません", srv.Name, o)
+			}
+		}
+		for _, h := range srv.Hostnames {
+			if other, ok := hosts[h]; ok {
+				return fmt.Errorf("ホスト名 %s が %s と %s で重複しています", h, other, srv.Name)
+			}
+			hosts[h] = srv.Name
+		}
+	}
+	for _, p := range s.Proxies {
+		if p.Config == "" {
+			return fmt.Errorf("プロキシ %s の config を指定してください", p.Name)
+		}
+	}
+	return nil
+}
+
+// Result は Apply の結果のうち、ファイルの差分には表れないものです。
+type Result struct {
+	// Notes は、Apply が自動では行わなかった作業の説明です。
+	Notes []string
+}
+
+// Apply は spec の状態になるようにファイルを更新します。
+// 書き込みはすべて vfs を通るため、vfs.Record 中に呼べば変更内容だけを確認できます。
+func Apply(spec *Spec, opts Options) (Result, error) {
+	var result Result
+	now := time.Now().UTC().Truncate(time.Second)
+	proxies := spec.Proxies
+	if len(proxies) == 0 {
+		proxies = opts.Proxies
+	}
+
+	current, err := server.LoadServers(opts.ServersPath)
+	if err != nil {
+		return result, err
+	}
+	currentByName := make(map[string]server.Server, len(current))
+	for _, s := range current {
+		currentByName[s.Name] = s
+	}
+	desired := make(map[string]bool, len(spec.Servers))
+	for _, s := range spec.Servers {
+		desired[s.Name] = true
+	}
+	var removed []string
+	for _, s := range current {
+		if !desired[s.Name] {
+			removed = append(removed, s.Name)
+		}
+	}
+	// managed は mcctl が管理している (いた) サーバーで、プロキシから外してよいものです。
+	managed := make(map[string]bool)
+	for _, s := range current {
+		managed[s.Name] = true
+	}
+	for name := range desired {
+		managed[name] = true
+	}
+
+	// servers.json: cluster.yaml の順に並べ、createdAt、labels、alerts など cluster.yaml にない項目は残す
+	proxyNames := make([]string, 0, len(proxies))
+	for _, p := range proxies {
+		proxyNames = append(proxyNames, p.Name)
+	}
+	servers := make([]server.Server, 0, len(spec.Servers))
+	for _, srv := range spec.Servers {
+		s, exists := currentByName[srv.Name]
+		if exists && s.Type != srv.Type {
+			result.Notes = append(result.Notes, fmt.Sprintf("%s のタイプが %s から %s に変わります。既存のサーバーディレクトリは作り直しません", srv.Name, s.Type, srv.Type))
+		}
+		if !exists {
+			s.CreatedAt = &now
+		}
+		env, err := server.ServiceEnvironment(srv.Type, serviceOptions(srv))
+		if err != nil {
+			return result, err
+		}
+		s.Name = srv.Name
+		s.Type = srv.Type
+		s.SetRuntime(env)
+		s.Address = address(srv, opts)
+		s.Proxies = proxyNames
+		servers = append(servers, s)
+	}
+	if err := server.SaveServers(opts.ServersPath, servers); err != nil {
+		return result, err
+	}
+
+	// サーバーディレクトリ: ないものだけをテンプレートから作成する
+	for _, srv := range spec.Servers {
+		if _, err := vfs.Stat(server.ServerDirectory(srv.Name)); err == nil {
+			continue
+		} else if !os.IsNotExist(err) {
+			return result, err
+		}
+		if err := server.CreateServerDirectory(srv.Name, srv.Type, srv.Overlays...); err != nil {
+			return result, fmt.Errorf("%s: %w", srv.Name, err)
+		}
+	}
+	for _, name := range removed {
+		if _, err := vfs.Stat(server.ServerDirectory(name)); err == nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("%s のサーバーディレクトリ %s は削除しません。不要であれば手作業で削除してください", name, server.ServerDirectory(name)))
+		}
+	}
+
+	// docker-compose.yml
+	err = server.UpdateDockerCompose(opts.ComposePath, func(compose *server.DockerCompose) error {
+		for _, srv := range spec.Servers {
+			service, err := server.NewDockerComposeService(srv.Name, srv.Type, serviceOptions(srv))
+			if err != nil {
+				return err
+			}
+			compose.Services[srv.Name] = service
+		}
+		for _, name := range removed {
+			delete(compose.Services, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	// velocity.toml
+	for _, p := range proxies {
+		err := server.UpdateVelocityConfig(p.Config, func(config map[string]interface{}) error {
+			applyProxy(config, p, spec, opts, removed, managed)
+			return nil
+		})
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", p.Config, err)
+		}
+	}
+
+	return result, nil
+}
+
+// applyProxy は velocity.toml の servers、forced-hosts、try を spec に合わせます。
+// mcctl が管理していないサーバーのエントリーには触れません。
+func applyProxy(config map[string]interface{}, p ProxySpec, spec *Spec, opts Options, removed []string, managed map[string]bool) {
+	servers := server.VelocitySection(config, "servers")
+	for _, name := range removed {
+		delete(servers, name)
+	}
+	for _, srv := range spec.Servers {
+		servers[srv.Name] = address(srv, opts)
+	}
+
+	// 管理しているサーバーだけを指す forced-hosts はいったん外し、cluster.yaml のホスト名で登録し直す
+	forcedHosts := server.VelocitySection(config, "forced-hosts")
+	for host, targets := range forcedHosts {
+		if onlyManaged(targets, managed) {
+			delete(forcedHosts, host)
+		}
+	}
+	for _, srv := range spec.Servers {
+		for _, host := range hostnames(srv, opts) {
+			forcedHosts[host] = []string{srv.Name}
+		}
+	}
+
+	if p.Try != nil {
+		// Velocity の標準の書式では try は [servers] の中にあるが、トップレベルに書かれていればそちらを使う
+		if _, ok := config["try"]; ok {
+			config["try"] = p.Try
+		} else {
+			servers["try"] = p.Try
+		}
+	}
+}
+
+func onlyManaged(targets interface{}, managed map[string]bool) bool {
+	list, ok := targets.([]interface{})
+	if !ok || len(list) == 0 {
+		return false
+	}
+	for _, t := range list {
+		if name, ok := t.(string); !ok || !managed[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func address(srv ServerSpec, opts Options) string {
+	if srv.Address != "" {
+		return srv.Address
+	}
+	return fmt.Sprintf("%s:%d", srv.Name, opts.Port)
+}
+
+func hostnames(srv ServerSpec, opts Options) []string {
+	if srv.Hostnames != nil {
+		return srv.Hostnames
+	}
+	return []string{srv.Name + "." + opts.Domain}
+}
+
+func serviceOptions(srv ServerSpec) server.ServiceOptions {
+	return server.ServiceOptions{Environment: environment(srv.Environment), Volumes: srv.Volumes}
+}
+
+// environment はマップを "KEY=value" のキー順のスライスにします。
+func environment(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := make([]string, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, k+"="+env[k])
+	}
+	return list
+}
@@ -0,0 +1,445 @@
+package cluster
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mcctl/internal/server"
+	"mcctl/internal/vfs"
+)
+
+// setupCluster は MinecraftDir をテスト用のディレクトリに切り替え、paper のテンプレートと
+// hardcore オーバーレイ、files (MinecraftDir からの相対パス) を書き込みます。
+func setupCluster(t *testing.T, files map[string]string) (string, Options) {
+	t.Helper()
+	dir := t.TempDir()
+	old := server.MinecraftDir
+	server.MinecraftDir = dir
+	t.Cleanup(func() { server.MinecraftDir = old })
+
+	all := map[string]string{
+		"template/paper/ops.json":                      "[]\n",
+		"template/paper/whitelist.json":                "[]\n",
+		"template/paper/server.properties":             "motd=paper\n",
+		"template/paper/paper-global.yml":              "proxies: {}\n",
+		"template/overlays/hardcore/server.properties": "hardcore=true\n",
+	}
+	for rel, content := range files {
+		all[rel] = content
+	}
+	for rel, content := range all {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, Options{
+		ServersPath: filepath.Join(dir, "servers.json"),
+		ComposePath: filepath.Join(dir, "docker-compose.yml"),
+		Domain:      "example.com",
+		Port:        25565,
+		Proxies:     []ProxySpec{{Name: "velocity", Config: filepath.Join(dir, "velocity.toml")}},
+	}
+}
+
+// changeList は記録した変更を "modify servers.json" のように dir からの相対パスで返します。
+func changeList(t *testing.T, dir string) []string {
+	t.Helper()
+	var list []string
+	for _, c := range vfs.Changes() {
+		abs, err := filepath.Abs(c.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, string(c.Kind)+" "+filepath.ToSlash(rel))
+	}
+	return list
+}
+
+func TestValidate(t *testing.T) {
+	setupCluster(t, nil)
+	paper := func(name string) ServerSpec { return ServerSpec{Name: name, Type: "paper"} }
+
+	tests := []struct {
+		name    string
+		spec    Spec
+		wantErr string
+	}{
+		{name: "空の構成", spec: Spec{}},
+		{
+			name: "正しい構成",
+			spec: Spec{
+				Servers: []ServerSpec{
+					{Name: "survival", Type: "paper", Overlays: []string{"hardcore"}, Hostnames: []string{"survival.example.com"}},
+					{Name: "modded_1", Type: "Forge"},
+				},
+				Proxies: []ProxySpec{{Name: "velocity", Config: "velocity.toml"}},
+			},
+		},
+		{name: "大文字を含む名前", spec: Spec{Servers: []ServerSpec{paper("Survival")}}, wantErr: "不正なサーバー名"},
+		{name: "空の名前", spec: Spec{Servers: []ServerSpec{paper("")}}, wantErr: "不正なサーバー名"},
+		{name: "パス区切りを含む名前", spec: Spec{Servers: []ServerSpec{paper("../survival")}}, wantErr: "不正なサーバー名"},
+		{name: "重複した名前", spec: Spec{Servers: []ServerSpec{paper("survival"), paper("survival")}}, wantErr: "複数回"},
+		{name: "type がない", spec: Spec{Servers: []ServerSpec{{Name: "survival"}}}, wantErr: "type を指定"},
+		{name: "未知のタイプ", spec: Spec{Servers: []ServerSpec{{Name: "survival", Type: "spigot"}}}, wantErr: "survival"},
+		{
+			name:    "存在しないオーバーレイ",
+			spec:    Spec{Servers: []ServerSpec{{Name: "survival", Type: "paper", Overlays: []string{"creative"}}}},
+			wantErr: "オーバーレイ creative",
+		},
+		{
+			name: "重複したホスト名",
+			spec: Spec{Servers: []ServerSpec{
+				{Name: "survival", Type: "paper", Hostnames: []string{"mc.example.com"}},
+				{Name: "lobby", Type: "paper", Hostnames: []string{"mc.example.com"}},
+			}},
+			wantErr: "mc.example.com が survival と lobby",
+		},
+		{name: "config のないプロキシ", spec: Spec{Proxies: []ProxySpec{{Name: "velocity"}}}, wantErr: "プロキシ velocity の config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.spec.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want %q を含むエラー", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, _ := setupCluster(t, map[string]string{
+		"cluster.yaml": "servers:\n  - name: survival\n    type: paper\n    environment:\n      MEMORY: 6G\n",
+		"empty.yaml":   "",
+		"unknown.yaml": "servers:\n  - name: survival\n    type: paper\n    port: 25566\n",
+		"broken.yaml":  "servers: [\n",
+		"invalid.yaml": "servers:\n  - name: survival\n",
+		"proxies.yaml": "proxies:\n  - name: velocity\n    config: velocity.toml\n    try: [survival]\n",
+	})
+
+	tests := []struct {
+		file    string
+		want    *Spec
+		wantErr string
+	}{
+		{file: "cluster.yaml", want: &Spec{Servers: []ServerSpec{{Name: "survival", Type: "paper", Environment: map[string]string{"MEMORY": "6G"}}}}},
+		{file: "empty.yaml", want: &Spec{}},
+		{file: "proxies.yaml", want: &Spec{Proxies: []ProxySpec{{Name: "velocity", Config: "velocity.toml", Try: []string{"survival"}}}}},
+		// 書き間違えた項目を黙って無視しない
+		{file: "unknown.yaml", wantErr: "port"},
+		{file: "broken.yaml", wantErr: "パース"},
+		{file: "invalid.yaml", wantErr: "type を指定"},
+		{file: "missing.yaml", wantErr: "読み込み"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := Load(filepath.Join(dir, tt.file))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Load() = %+v, %v, want %q を含むエラー", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestApplyChanges は mcctl plan が表示する変更 (Apply を記録したときの vfs.Changes) を確かめます。
+func TestApplyChanges(t *testing.T) {
+	survival := ServerSpec{Name: "survival", Type: "paper"}
+	lobby := ServerSpec{Name: "lobby", Type: "paper"}
+	newPaper := []string{
+		"mkdir servers/%s",
+		"create servers/%s/ops.json",
+		"create servers/%s/paper-global.yml",
+		"mkdir servers/%s/plugins",
+		"create servers/%s/server.properties",
+		"create servers/%s/whitelist.json",
+		"mkdir servers/%s/world",
+	}
+	newServer := func(name string) []string {
+		list := make([]string, len(newPaper))
+		for i, c := range newPaper {
+			list[i] = strings.ReplaceAll(c, "%s", name)
+		}
+		return list
+	}
+	join := func(lists ...[]string) []string {
+		var all []string
+		for _, l := range lists {
+			all = append(all, l...)
+		}
+		return all
+	}
+
+	tests := []struct {
+		name string
+		// before を指定すると、記録を始める前に before を反映しておきます。
+		before    *Spec
+		spec      Spec
+		want      []string
+		wantNotes []string
+	}{
+		{
+			name: "新しいクラスタ",
+			spec: Spec{Servers: []ServerSpec{survival}},
+			want: join(
+				[]string{"create docker-compose.yml", "mkdir servers", "create servers.json"},
+				newServer("survival"),
+				[]string{"create velocity.toml"},
+			),
+		},
+		{
+			name:   "変更なし",
+			before: &Spec{Servers: []ServerSpec{survival, lobby}},
+			spec:   Spec{Servers: []ServerSpec{survival, lobby}},
+		},
+		{
+			name:   "サーバーの追加",
+			before: &Spec{Servers: []ServerSpec{survival}},
+			spec:   Spec{Servers: []ServerSpec{survival, lobby}},
+			want: join(
+				[]string{"modify docker-compose.yml", "modify servers.json"},
+				newServer("lobby"),
+				[]string{"modify velocity.toml"},
+			),
+		},
+		{
+			// サーバーディレクトリは削除しない
+			name:      "サーバーの削除",
+			before:    &Spec{Servers: []ServerSpec{survival, lobby}},
+			spec:      Spec{Servers: []ServerSpec{survival}},
+			want:      []string{"modify docker-compose.yml", "modify servers.json", "modify velocity.toml"},
+			wantNotes: []string{"lobby のサーバーディレクトリ"},
+		},
+		{
+			name:   "環境変数の変更",
+			before: &Spec{Servers: []ServerSpec{survival}},
+			spec:   Spec{Servers: []ServerSpec{{Name: "survival", Type: "paper", Environment: map[string]string{"MEMORY": "6G"}}}},
+			// servers.json の memory も変わる
+			want: []string{"modify docker-compose.yml", "modify servers.json"},
+		},
+		{
+			// 既存のサーバーディレクトリは作り直さない
+			name:      "タイプの変更",
+			before:    &Spec{Servers: []ServerSpec{survival}},
+			spec:      Spec{Servers: []ServerSpec{{Name: "survival", Type: "vanilla"}}},
+			want:      []string{"modify docker-compose.yml", "modify servers.json"},
+			wantNotes: []string{"survival のタイプが paper から vanilla に変わります"},
+		},
+		{
+			name:   "ホスト名の変更",
+			before: &Spec{Servers: []ServerSpec{survival}},
+			spec:   Spec{Servers: []ServerSpec{{Name: "survival", Type: "paper", Hostnames: []string{"mc.example.com"}}}},
+			want:   []string{"modify velocity.toml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, opts := setupCluster(t, nil)
+			if tt.before != nil {
+				if _, err := Apply(tt.before, opts); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			vfs.Record()
+			defer vfs.Discard()
+			result, err := Apply(&tt.spec, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := changeList(t, dir); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes = %q, want %q", got, tt.want)
+			}
+			if len(result.Notes) != len(tt.wantNotes) {
+				t.Fatalf("Notes = %q, want %q", result.Notes, tt.wantNotes)
+			}
+			for i, want := range tt.wantNotes {
+				if !strings.Contains(result.Notes[i], want) {
+					t.Errorf("Notes[%d] = %q, want %q を含む", i, result.Notes[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyKeepsUnmanagedState(t *testing.T) {
+	dir, opts := setupCluster(t, map[string]string{
+		"servers.json": `{"schemaVersion": 2, "servers": [
+  {"name": "survival", "type": "paper", "address": "survival:25565", "createdAt": "2024-01-02T03:04:05Z", "labels": {"team": "a"}}
+]}
+`,
+		"velocity.toml": `try = ['survival']
+
+[forced-hosts]
+'survival.example.com' = ['survival']
+'legacy.example.com' = ['legacy']
+
+[servers]
+legacy = 'legacy:25565'
+survival = 'survival:25565'
+`,
+		"servers/survival/server.properties": "motd=custom\n",
+	})
+	spec := &Spec{Servers: []ServerSpec{{Name: "survival", Type: "paper", Overlays: []string{"hardcore"}}, {Name: "lobby", Type: "paper", Overlays: []string{"hardcore"}}}}
+	if _, err := Apply(spec, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	servers, err := server.LoadServers(opts.ServersPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 2 || servers[0].Name != "survival" || servers[1].Name != "lobby" {
+		t.Fatalf("servers = %+v", servers)
+	}
+	if got := servers[0]; got.CreatedAt == nil || got.CreatedAt.Year() != 2024 || got.Labels["team"] != "a" {
+		t.Errorf("survival の createdAt と labels が残っていません: %+v", got)
+	}
+	if got := servers[1]; got.CreatedAt == nil || !reflect.DeepEqual(got.Proxies, []string{"velocity"}) || got.Memory != "4G" {
+		t.Errorf("lobby = %+v", got)
+	}
+
+	// 既存のサーバーディレクトリにはオーバーレイも重ねない
+	if data, _ := os.ReadFile(filepath.Join(dir, "servers/survival/server.properties")); string(data) != "motd=custom\n" {
+		t.Errorf("survival/server.properties = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "servers/lobby/server.properties")); string(data) != "motd=paper\nhardcore=true\n" {
+		t.Errorf("lobby/server.properties = %q", data)
+	}
+
+	data, _ := os.ReadFile(opts.Proxies[0].Config)
+	for _, want := range []string{"legacy = 'legacy:25565'", "'legacy.example.com' = ['legacy']", "'lobby.example.com' = ['lobby']"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("velocity.toml に %s がありません:\n%s", want, data)
+		}
+	}
+}
+
+func TestApplyProxy(t *testing.T) {
+	spec := &Spec{Servers: []ServerSpec{
+		{Name: "survival", Type: "paper"},
+		{Name: "modded", Type: "forge", Address: "modded-forge:25566", Hostnames: []string{"mods.example.com"}},
+	}}
+	opts := Options{Domain: "example.com", Port: 25565}
+	// lobby は cluster.yaml から消え、legacy は mcctl が管理していない
+	removed := []string{"lobby"}
+	managed := map[string]bool{"survival": true, "modded": true, "lobby": true}
+	list := func(names ...string) []interface{} {
+		l := make([]interface{}, len(names))
+		for i, n := range names {
+			l[i] = n
+		}
+		return l
+	}
+
+	tests := []struct {
+		name   string
+		proxy  ProxySpec
+		config map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name:   "空の設定",
+			config: map[string]interface{}{},
+			want: map[string]interface{}{
+				"servers": map[string]interface{}{"survival": "survival:25565", "modded": "modded-forge:25566"},
+				"forced-hosts": map[string]interface{}{
+					"survival.example.com": []string{"survival"},
+					"mods.example.com":     []string{"modded"},
+				},
+			},
+		},
+		{
+			name: "管理していないエントリーは残す",
+			config: map[string]interface{}{
+				"servers": map[string]interface{}{"lobby": "lobby:25565", "legacy": "legacy:25565", "survival": "old:25565"},
+				"forced-hosts": map[string]interface{}{
+					"lobby.example.com":   list("lobby"),
+					"modded.example.com":  list("modded"),
+					"legacy.example.com":  list("legacy"),
+					"mixed.example.com":   list("survival", "legacy"),
+					"invalid.example.com": "survival",
+				},
+			},
+			want: map[string]interface{}{
+				"servers": map[string]interface{}{"legacy": "legacy:25565", "survival": "survival:25565", "modded": "modded-forge:25566"},
+				"forced-hosts": map[string]interface{}{
+					"legacy.example.com":   list("legacy"),
+					"mixed.example.com":    list("survival", "legacy"),
+					"invalid.example.com":  "survival",
+					"survival.example.com": []string{"survival"},
+					"mods.example.com":     []string{"modded"},
+				},
+			},
+		},
+		{
+			name:   "try は [servers] に置く",
+			proxy:  ProxySpec{Try: []string{"survival"}},
+			config: map[string]interface{}{"servers": map[string]interface{}{"try": list("lobby")}},
+			want: map[string]interface{}{
+				"servers": map[string]interface{}{"survival": "survival:25565", "modded": "modded-forge:25566", "try": []string{"survival"}},
+				"forced-hosts": map[string]interface{}{
+					"survival.example.com": []string{"survival"},
+					"mods.example.com":     []string{"modded"},
+				},
+			},
+		},
+		{
+			name:   "トップレベルの try を置き換える",
+			proxy:  ProxySpec{Try: []string{"survival", "modded"}},
+			config: map[string]interface{}{"try": list("lobby")},
+			want: map[string]interface{}{
+				"try":     []string{"survival", "modded"},
+				"servers": map[string]interface{}{"survival": "survival:25565", "modded": "modded-forge:25566"},
+				"forced-hosts": map[string]interface{}{
+					"survival.example.com": []string{"survival"},
+					"mods.example.com":     []string{"modded"},
+				},
+			},
+		},
+		{
+			name:   "try を指定しなければ変えない",
+			config: map[string]interface{}{"try": list("lobby")},
+			want: map[string]interface{}{
+				"try":     list("lobby"),
+				"servers": map[string]interface{}{"survival": "survival:25565", "modded": "modded-forge:25566"},
+				"forced-hosts": map[string]interface{}{
+					"survival.example.com": []string{"survival"},
+					"mods.example.com":     []string{"modded"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applyProxy(tt.config, tt.proxy, spec, opts, removed, managed)
+			if !reflect.DeepEqual(tt.config, tt.want) {
+				t.Errorf("applyProxy() =\n%v\nwant\n%v", tt.config, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Package config は mcctl.yaml の読み込みとプロジェクトルートの検出を行います。
+//
+// mcctl.yaml はリポジトリのルートに置くマーカーを兼ねた設定ファイルです。
+// mcctl はカレントディレクトリから親へたどって mcctl.yaml を探し、見つかったディレクトリをルートとして動作します。
+// 設定ファイル内のパスはすべてルートからの相対パスです。
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName はプロジェクトルートに置く設定ファイルの名前です。
+const FileName = "mcctl.yaml"
+
+// EnvVar は設定ファイルのパスを指定する環境変数です。
+const EnvVar = "MCCTL_CONFIG"
+
+// Config は mcctl.yaml の内容です。
+type Config struct {
+	// Root はプロジェクトルートの絶対パスです。
+	Root string `yaml:"-"`
+	// File は読み込んだ設定ファイルのパスです。設定ファイルがない場合は空です。
+	File string `yaml:"-"`
+
+	// Domain は Velocity の forced-hosts に登録するホスト名のドメインです (<name>.<domain>)。
+	Domain   string   `yaml:"domain"`
+	Proxies  []Proxy  `yaml:"proxies"`
+	Paths    Paths    `yaml:"paths"`
+	Defaults Defaults `yaml:"defaults"`
+}
+
+// Proxy は、サーバーを追加したときに登録するプロキシです。
+type Proxy struct {
+	Name   string `yaml:"name"`
+	Config string `yaml:"config"` // velocity.toml のパス
+}
+
+// Paths は mcctl が読み書きするファイルの場所です。
+type Paths struct {
+	// Minecraft は docker-compose.yml、servers/、template/ を含むディレクトリです。
+	Minecraft             string `yaml:"minecraft"`
+	Servers               string `yaml:"servers"`
+	RootCompose           string `yaml:"rootCompose"`
+	Prometheus            string `yaml:"prometheus"`
+	PrometheusTargets     string `yaml:"prometheusTargets"`
+	AlertRules            string `yaml:"alertRules"`
+	Dashboards            string `yaml:"dashboards"`
+	DashboardProvisioning string `yaml:"dashboardProvisioning"`
+	Backups               string `yaml:"backups"`
+	Schedule              string `yaml:"schedule"`
+	ScheduleHistory       string `yaml:"scheduleHistory"`
+	Cluster               string `yaml:"cluster"` // mcctl plan / mcctl apply が読むサーバー構成
+}
+
+// Compose returns the docker-compose.yml that holds the Minecraft server services.
+func (p Paths) Compose() string {
+	return filepath.Join(p.Minecraft, "docker-compose.yml")
+}
+
+// Defaults は、コマンドで省略された値の既定値です。
+type Defaults struct {
+	Type     string   `yaml:"type"`
+	Port     int      `yaml:"port"`
+	Exporter string   `yaml:"exporter"` // Prometheus コンテナから見た `mcctl exporter` のアドレス
+	Overlays []string `yaml:"overlays"`
+}
+
+// Default は、mcctl.yaml がない場合の設定です。
+// 値はこのリポジトリの標準の構成に合わせています。
+func Default() *Config {
+	return &Config{
+		Domain:  "example.com",
+		Proxies: []Proxy{{Name: "velocity", Config: "velocity/velocity.toml"}},
+		Paths: Paths{
+			Minecraft:             "minecraft",
+			Servers:               "minecraft/servers.json",
+			RootCompose:           "docker-compose.yml",
+			Prometheus:            "prometheus/config.yml",
+			PrometheusTargets:     "prometheus/targets/minecraft.json",
+			AlertRules:            "prometheus/rules/mcctl.rules.yml",
+			Dashboards:            "grafana/dashboards/mcctl",
+			DashboardProvisioning: "grafana/provisioning/dashboards/mcctl.yaml",
+			Backups:               "backups",
+			Schedule:              "minecraft/schedule.yaml",
+			ScheduleHistory:       "minecraft/schedule-history.json",
+			Cluster:               "cluster.yaml",
+		},
+		Defaults: Defaults{
+			Type:     "vanilla",
+			Port:     25565,
+			Exporter: "mcctl-exporter:9225",
+		},
+	}
+}
+
+// Load は設定ファイルを読み込みます。ファイルにない項目は Default の値になり、
+// Root は設定ファイルのあるディレクトリになります。
+func Load(path string) (*Config, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("設定ファイルの読み込みに失敗しました: %w", err)
+	}
+
+	cfg := Default()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s のパースに失敗しました: %w", abs, err)
+	}
+	cfg.Root = filepath.Dir(abs)
+	cfg.File = abs
+	return cfg, nil
+}
+
+// Find は dir から親ディレクトリへたどって mcctl.yaml を探します。
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", os.ErrNotExist
+		}
+		dir = parent
+	}
+}
+
+// Discover は設定を次の順で決めます。
+//
+//  1. explicit (--config)
+//  2. 環境変数 MCCTL_CONFIG
+//  3. cwd から親へたどって見つかった mcctl.yaml
+//  4. 見つからない場合は cwd をルートとする Default
+func Discover(explicit, cwd string) (*Config, error) {
+	if explicit == "" {
+		explicit = os.Getenv(EnvVar)
+	}
+	if explicit != "" {
+		if !filepath.IsAbs(explicit) {
+			explicit = filepath.Join(cwd, explicit)
+		}
+		return Load(explicit)
+	}
+
+	path, err := Find(cwd)
+	if err == nil {
+		return Load(path)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	cfg := Default()
+	cfg.Root, err = filepath.Abs(cwd)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
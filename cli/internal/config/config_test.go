@@ -0,0 +1,141 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	// root/
+	//   mcctl.yaml            domain: root.example
+	//   sub/deeper/           (cwd)
+	//   other/custom.yaml     domain: custom.example
+	//   env/mcctl.yaml        domain: env.example
+	// bare/                   (mcctl.yaml なし)
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	cwd := filepath.Join(root, "sub", "deeper")
+	bare := filepath.Join(base, "bare")
+	for _, dir := range []string{cwd, filepath.Join(root, "other"), filepath.Join(root, "env"), bare} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(path, domain string) {
+		if err := os.WriteFile(path, []byte("domain: "+domain+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(root, FileName), "root.example")
+	write(filepath.Join(root, "other", "custom.yaml"), "custom.example")
+	write(filepath.Join(root, "env", FileName), "env.example")
+
+	tests := []struct {
+		name     string
+		explicit string
+		env      string
+		cwd      string
+		domain   string
+		root     string
+		file     string
+	}{
+		{
+			name:     "--config が MCCTL_CONFIG より優先される",
+			explicit: filepath.Join(root, "other", "custom.yaml"),
+			env:      filepath.Join(root, "env", FileName),
+			cwd:      cwd,
+			domain:   "custom.example",
+			root:     filepath.Join(root, "other"),
+			file:     filepath.Join(root, "other", "custom.yaml"),
+		},
+		{
+			name:     "--config の相対パスは cwd から解決する",
+			explicit: filepath.Join("..", "..", "other", "custom.yaml"),
+			cwd:      cwd,
+			domain:   "custom.example",
+			root:     filepath.Join(root, "other"),
+			file:     filepath.Join(root, "other", "custom.yaml"),
+		},
+		{
+			name:   "MCCTL_CONFIG が親の mcctl.yaml より優先される",
+			env:    filepath.Join(root, "env", FileName),
+			cwd:    cwd,
+			domain: "env.example",
+			root:   filepath.Join(root, "env"),
+			file:   filepath.Join(root, "env", FileName),
+		},
+		{
+			name:   "親へたどって mcctl.yaml を見つける",
+			cwd:    cwd,
+			domain: "root.example",
+			root:   root,
+			file:   filepath.Join(root, FileName),
+		},
+		{
+			name:   "見つからない場合は cwd をルートとする既定の設定",
+			cwd:    bare,
+			domain: Default().Domain,
+			root:   bare,
+			file:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvVar, tt.env)
+			cfg, err := Discover(tt.explicit, tt.cwd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Domain != tt.domain || cfg.Root != tt.root || cfg.File != tt.file {
+				t.Errorf("Discover() = domain %q, root %q, file %q, want %q, %q, %q",
+					cfg.Domain, cfg.Root, cfg.File, tt.domain, tt.root, tt.file)
+			}
+		})
+	}
+}
+
+func TestDiscoverErrors(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.yaml")
+	os.WriteFile(broken, []byte("domain: [\n"), 0644)
+
+	tests := []struct {
+		name     string
+		explicit string
+		env      string
+	}{
+		{name: "--config のファイルがない", explicit: filepath.Join(dir, "missing.yaml")},
+		{name: "MCCTL_CONFIG のファイルがない", env: filepath.Join(dir, "missing.yaml")},
+		{name: "パースできない", explicit: broken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvVar, tt.env)
+			// 指定されたファイルがない場合は、既定の設定や親の mcctl.yaml にフォールバックしない
+			if cfg, err := Discover(tt.explicit, dir); err == nil {
+				t.Errorf("Discover() = %+v, want error", cfg)
+			}
+		})
+	}
+}
+
+func TestLoadKeepsDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	os.WriteFile(path, []byte("paths:\n  backups: /srv/backups\n"), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Paths.Backups != "/srv/backups" {
+		t.Errorf("Paths.Backups = %q", cfg.Paths.Backups)
+	}
+	// 書かれていない値は既定値のまま
+	if want := Default().Paths.Servers; cfg.Paths.Servers != want {
+		t.Errorf("Paths.Servers = %q, want %q", cfg.Paths.Servers, want)
+	}
+	if want := Default().Defaults.Port; cfg.Defaults.Port != want {
+		t.Errorf("Defaults.Port = %d, want %d", cfg.Defaults.Port, want)
+	}
+}
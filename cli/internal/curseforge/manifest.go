@@ -0,0 +1,200 @@
+// Package curseforge は CurseForge のモッドパック (manifest.json を含む zip) を読み込み、
+// サーバーディレクトリに展開します。
+package curseforge
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mcctl/internal/mods"
+	"mcctl/internal/packfs"
+)
+
+// ManifestFileName は zip 内のマニフェストの名前です。
+const ManifestFileName = "manifest.json"
+
+// Manifest は manifest.json の内容です。
+type Manifest struct {
+	Minecraft struct {
+		Version    string      `json:"version"`
+		ModLoaders []ModLoader `json:"modLoaders"`
+	} `json:"minecraft"`
+	ManifestType    string    `json:"manifestType"`
+	ManifestVersion int       `json:"manifestVersion"`
+	Name            string    `json:"name"`
+	Version         string    `json:"version"`
+	Author          string    `json:"author"`
+	Files           []FileRef `json:"files"`
+	Overrides       string    `json:"overrides"`
+}
+
+// ModLoader は "forge-47.2.0" のような ID のローダーです。
+type ModLoader struct {
+	ID      string `json:"id"`
+	Primary bool   `json:"primary"`
+}
+
+// FileRef は、プロジェクト ID とファイル ID で指定された MOD です。
+type FileRef struct {
+	ProjectID int  `json:"projectID"`
+	FileID    int  `json:"fileID"`
+	Required  bool `json:"required"`
+}
+
+// Pack は開いた CurseForge のモッドパックです。
+type Pack struct {
+	Manifest Manifest
+	zr       *zip.ReadCloser
+}
+
+// Open は zip を開いて manifest.json を読み込みます。
+func Open(packPath string) (*Pack, error) {
+	zr, err := zip.OpenReader(packPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s を開けませんでした: %w", packPath, err)
+	}
+	f, err := zr.Open(ManifestFileName)
+	if err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("%s に %s がありません", packPath, ManifestFileName)
+	}
+	defer f.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("%s のパースに失敗しました: %w", ManifestFileName, err)
+	}
+	if m.ManifestType != "minecraftModpack" {
+		zr.Close()
+		return nil, fmt.Errorf("未対応の manifestType です: %s", m.ManifestType)
+	}
+	if m.Overrides == "" {
+		m.Overrides = "overrides"
+	}
+	return &Pack{Manifest: m, zr: zr}, nil
+}
+
+// Close closes the underlying archive.
+func (p *Pack) Close() error {
+	return p.zr.Close()
+}
+
+// loaders は modLoaders の ID の接頭辞と mcctl のサーバータイプ、
+// itzg/minecraft-server でローダーのバージョンを指定する環境変数の対応です。
+var loaders = []struct {
+	prefix     string
+	serverType string
+	env        string
+}{
+	{"forge-", "forge", "FORGE_VERSION"},
+	{"fabric-", "fabric", "FABRIC_LOADER_VERSION"},
+}
+
+// Runtime は、パックを動かすサーバータイプと docker-compose の環境変数を返します。
+// ローダーが複数ある場合は primary のものを使います。
+func (p *Pack) Runtime() (string, []string, error) {
+	mc := p.Manifest.Minecraft.Version
+	if mc == "" {
+		return "", nil, fmt.Errorf("manifest.json に minecraft.version がありません")
+	}
+	ml := p.Manifest.Minecraft.ModLoaders
+	if len(ml) == 0 {
+		return "vanilla", []string{"VERSION=" + mc}, nil
+	}
+	loader := ml[0]
+	for _, l := range ml {
+		if l.Primary {
+			loader = l
+			break
+		}
+	}
+	for _, l := range loaders {
+		if v, ok := strings.CutPrefix(loader.ID, l.prefix); ok {
+			return l.serverType, []string{"VERSION=" + mc, l.env + "=" + v}, nil
+		}
+	}
+	return "", nil, fmt.Errorf("未対応のローダーです: %s", loader.ID)
+}
+
+// Installed は、展開した MOD です。
+type Installed struct {
+	Ref      FileRef
+	Filename string
+	SHA512   string
+	URL      string
+}
+
+// Result は Install の結果です。
+type Result struct {
+	Mods []Installed
+	// Skipped は required が false のため展開しなかったファイルです。
+	Skipped []FileRef
+	// Overrides は overrides ディレクトリから展開したファイルです。
+	Overrides []string
+}
+
+// Install は、マニフェストの MOD を resolver で解決して dst/mods に書き込み、overrides を dst に展開します。
+func (p *Pack) Install(dst string, resolver Resolver) (Result, error) {
+	var result Result
+	modsDir := filepath.Join(dst, "mods")
+	for _, ref := range p.Manifest.Files {
+		if !ref.Required {
+			result.Skipped = append(result.Skipped, ref)
+			continue
+		}
+		f, err := resolver.Resolve(ref.ProjectID, ref.FileID)
+		if err != nil {
+			return result, fmt.Errorf("%d/%d の解決に失敗しました: %w", ref.ProjectID, ref.FileID, err)
+		}
+		data, err := read(resolver, f)
+		if err != nil {
+			return result, err
+		}
+		target, err := packfs.SafeJoin(modsDir, f.FileName)
+		if err != nil {
+			return result, err
+		}
+		if err := os.MkdirAll(modsDir, 0755); err != nil {
+			return result, err
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return result, fmt.Errorf("%s の書き込みに失敗しました: %w", f.FileName, err)
+		}
+		result.Mods = append(result.Mods, Installed{Ref: ref, Filename: f.FileName, SHA512: mods.SHA512(data), URL: f.DownloadURL})
+	}
+
+	files, err := packfs.ExtractDir(&p.zr.Reader, p.Manifest.Overrides, dst)
+	if err != nil {
+		return result, err
+	}
+	result.Overrides = files
+	return result, nil
+}
+
+// read は resolver からファイルを読み込み、SHA1 が分かっている場合は検証します。
+func read(resolver Resolver, f File) ([]byte, error) {
+	rc, err := resolver.Open(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s の取得に失敗しました: %w", f.FileName, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("%s の取得に失敗しました: %w", f.FileName, err)
+	}
+	if f.SHA1 != "" {
+		sum := sha1.Sum(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), f.SHA1) {
+			return nil, fmt.Errorf("%s の sha1 が一致しません", f.FileName)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,70 @@
+package curseforge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ErrNotFound は、リゾルバーがプロジェクト ID とファイル ID を解決できない場合に返されます。
+var ErrNotFound = errors.New("ファイルが見つかりません")
+
+// File は、解決されたダウンロード対象のファイルです。
+type File struct {
+	ProjectID   int    `json:"projectID"`
+	FileID      int    `json:"fileID"`
+	FileName    string `json:"fileName"`
+	SHA1        string `json:"sha1,omitempty"`
+	DownloadURL string `json:"downloadUrl,omitempty"`
+}
+
+// Resolver は、プロジェクト ID とファイル ID を実際のファイルに解決します。
+// CurseForge API のクライアントはこのインターフェースを実装して登録します。
+type Resolver interface {
+	Resolve(projectID, fileID int) (File, error)
+	Open(f File) (io.ReadCloser, error)
+}
+
+// FixtureResolver は、ローカルディレクトリに置いたファイルから解決するリゾルバーです。
+// API キーやネットワークなしでパックを展開できます。
+//
+// ディレクトリ構成:
+//
+//	<dir>/<projectID>/<fileID>/file.json   (File のメタデータ)
+//	<dir>/<projectID>/<fileID>/<fileName>
+type FixtureResolver struct {
+	Dir string
+}
+
+func (r *FixtureResolver) dir(projectID, fileID int) string {
+	return filepath.Join(r.Dir, strconv.Itoa(projectID), strconv.Itoa(fileID))
+}
+
+// Resolve implements Resolver.
+func (r *FixtureResolver) Resolve(projectID, fileID int) (File, error) {
+	data, err := os.ReadFile(filepath.Join(r.dir(projectID, fileID), "file.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return File{}, fmt.Errorf("%d/%d: %w", projectID, fileID, ErrNotFound)
+		}
+		return File{}, err
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return File{}, fmt.Errorf("%d/%d の file.json のパースに失敗しました: %w", projectID, fileID, err)
+	}
+	if f.FileName == "" {
+		return File{}, fmt.Errorf("%d/%d の file.json に fileName がありません", projectID, fileID)
+	}
+	f.ProjectID, f.FileID = projectID, fileID
+	return f, nil
+}
+
+// Open implements Resolver.
+func (r *FixtureResolver) Open(f File) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(r.dir(f.ProjectID, f.FileID), f.FileName))
+}
@@ -0,0 +1,235 @@
+// Package dashboards は、管理しているサーバーの Grafana ダッシュボードをテンプレートから生成します。
+package dashboards
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"mcctl/internal/server"
+	"mcctl/internal/vfs"
+)
+
+//go:embed templates/server.json.tmpl
+var templates embed.FS
+
+var dashboardTemplate = template.Must(template.New("server.json.tmpl").Funcs(template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"add":   func(a, b int) int { return a + b },
+	"refID": func(i int) string { return string(rune('A' + i)) },
+}).ParseFS(templates, "templates/server.json.tmpl"))
+
+// provisioningTemplate は Grafana のダッシュボードプロビジョニング設定です。
+const provisioningTemplate = `apiVersion: 1
+
+providers:
+  - name: mcctl
+    folder: Minecraft Servers
+    type: file
+    disableDeletion: false
+    allowUiUpdates: false
+    updateIntervalSeconds: 30
+    options:
+      path: %s
+`
+
+// Options は、生成先のパスと生成方法です。
+type Options struct {
+	ServersPath string
+	// OutputDir は生成したダッシュボードJSONを置くディレクトリです。
+	OutputDir string
+	// ProvisioningPath は Grafana のプロビジョニングYAMLのパスです。
+	ProvisioningPath string
+	// ContainerDir は Grafana コンテナ内から見た OutputDir です。
+	ContainerDir string
+	// Single が true の場合、サーバーごとではなく server 変数で切り替える1枚のダッシュボードを生成します。
+	Single bool
+}
+
+type target struct {
+	Expr   string
+	Legend string
+}
+
+type threshold struct {
+	Color string
+	Value float64
+}
+
+type panel struct {
+	Type       string
+	Title      string
+	Unit       string
+	X, Y, W, H int
+	Targets    []target
+	Thresholds []threshold
+	Mappings   bool
+}
+
+type dashboard struct {
+	Title          string
+	UID            string
+	ServerVariable bool
+	Panels         []panel
+}
+
+// selector は、1台のサーバーを指すラベルセレクタとコンテナ名です。
+type selector struct {
+	server    string
+	container string
+}
+
+func panels(sel selector) []panel {
+	s := sel.server
+	c := sel.container
+	return []panel{
+		{Type: "stat", Title: "Status", Unit: "none", X: 0, Y: 0, W: 4, H: 4, Mappings: true,
+			Targets:    []target{{Expr: fmt.Sprintf(`mcctl_server_up{%s}`, s)}},
+			Thresholds: []threshold{{Color: "red"}, {Color: "green", Value: 1}}},
+		{Type: "stat", Title: "Online Players", Unit: "none", X: 4, Y: 0, W: 4, H: 4,
+			Targets: []target{{Expr: fmt.Sprintf(`mcctl_server_players_online{%s}`, s)}}},
+		{Type: "stat", Title: "TPS (1m)", Unit: "none", X: 8, Y: 0, W: 4, H: 4,
+			Targets:    []target{{Expr: fmt.Sprintf(`mcctl_server_tps{%s,window="1m"}`, s)}},
+			Thresholds: []threshold{{Color: "red"}, {Color: "orange", Value: 15}, {Color: "green", Value: 19}}},
+		{Type: "stat", Title: "Ping", Unit: "s", X: 12, Y: 0, W: 4, H: 4,
+			Targets: []target{{Expr: fmt.Sprintf(`mcctl_server_ping_seconds{%s}`, s)}}},
+		{Type: "stat", Title: "CPU", Unit: "percent", X: 16, Y: 0, W: 4, H: 4,
+			Targets: []target{{Expr: fmt.Sprintf(`sum(rate(container_cpu_usage_seconds_total{%s}[5m])) * 100`, c)}}},
+		{Type: "stat", Title: "Memory", Unit: "bytes", X: 20, Y: 0, W: 4, H: 4,
+			Targets: []target{{Expr: fmt.Sprintf(`sum(container_memory_rss{%s})`, c)}}},
+		{Type: "timeseries", Title: "Players", Unit: "none", X: 0, Y: 4, W: 12, H: 8,
+			Targets: []target{
+				{Expr: fmt.Sprintf(`mcctl_server_players_online{%s}`, s), Legend: "online"},
+				{Expr: fmt.Sprintf(`mcctl_server_players_max{%s}`, s), Legend: "max"},
+			}},
+		{Type: "timeseries", Title: "TPS / MSPT", Unit: "none", X: 12, Y: 4, W: 12, H: 8,
+			Targets: []target{
+				{Expr: fmt.Sprintf(`mcctl_server_tps{%s}`, s), Legend: "TPS {{window}}"},
+				{Expr: fmt.Sprintf(`mcctl_server_mspt_milliseconds{%s}`, s), Legend: "MSPT"},
+			}},
+		{Type: "timeseries", Title: "Ping", Unit: "s", X: 0, Y: 12, W: 8, H: 8,
+			Targets: []target{{Expr: fmt.Sprintf(`mcctl_server_ping_seconds{%s}`, s), Legend: "ping"}}},
+		{Type: "timeseries", Title: "Container CPU", Unit: "percent", X: 8, Y: 12, W: 8, H: 8,
+			Targets: []target{{Expr: fmt.Sprintf(`sum(rate(container_cpu_usage_seconds_total{%s}[5m])) * 100`, c), Legend: "cpu"}}},
+		{Type: "timeseries", Title: "Container Memory", Unit: "bytes", X: 16, Y: 12, W: 8, H: 8,
+			Targets: []target{
+				{Expr: fmt.Sprintf(`sum(container_memory_rss{%s})`, c), Legend: "rss"},
+				{Expr: fmt.Sprintf(`sum(container_spec_memory_limit_bytes{%s})`, c), Legend: "limit"},
+			}},
+	}
+}
+
+func render(d dashboard) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := dashboardTemplate.Execute(&buf, d); err != nil {
+		return nil, fmt.Errorf("ダッシュボードの生成に失敗しました: %w", err)
+	}
+	// テンプレートの崩れで壊れたJSONを書き出さないよう、整形を兼ねて検証する
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return nil, fmt.Errorf("生成したダッシュボードが不正なJSONです: %w", err)
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// generated は、path が mcctl の生成したダッシュボード (uid が "mcctl-" で始まる) かどうかを返します。
+// 同じディレクトリに手で置かれたダッシュボードを削除しないために使います。
+func generated(path string) bool {
+	data, err := vfs.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var d struct {
+		UID string `json:"uid"`
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		return false
+	}
+	return strings.HasPrefix(d.UID, "mcctl-")
+}
+
+// Generate はダッシュボードとプロビジョニングYAMLを書き出し、書き出したファイルの一覧を返します。
+// OutputDir に残っている、もう存在しないサーバーのダッシュボードは削除します (mcctl が生成したものに限ります)。
+func Generate(opts Options) ([]string, error) {
+	servers, err := server.LoadServers(opts.ServersPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	if opts.Single {
+		data, err := render(dashboard{
+			Title:          "Minecraft Servers",
+			UID:            "mcctl-servers",
+			ServerVariable: true,
+			Panels: panels(selector{
+				server:    `name="$server"`,
+				container: `name="minecraft-$server-server"`,
+			}),
+		})
+		if err != nil {
+			return nil, err
+		}
+		files["servers.json"] = data
+	} else {
+		for _, s := range servers {
+			data, err := render(dashboard{
+				Title: fmt.Sprintf("Minecraft: %s", s.Name),
+				UID:   "mcctl-" + s.Name,
+				Panels: panels(selector{
+					server:    fmt.Sprintf(`name=%q`, s.Name),
+					container: fmt.Sprintf(`name=%q`, server.ContainerName(s.Name)),
+				}),
+			})
+			if err != nil {
+				return nil, err
+			}
+			files[s.Name+".json"] = data
+		}
+	}
+
+	if err := vfs.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("出力ディレクトリの作成に失敗しました: %w", err)
+	}
+	existing, err := filepath.Glob(filepath.Join(opts.OutputDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range existing {
+		if _, keep := files[filepath.Base(path)]; !keep && generated(path) {
+			if err := vfs.Remove(path); err != nil {
+				return nil, fmt.Errorf("古いダッシュボード %s の削除に失敗しました: %w", path, err)
+			}
+		}
+	}
+
+	var written []string
+	for name, data := range files {
+		path := filepath.Join(opts.OutputDir, name)
+		if err := vfs.WriteFile(path, data, 0644); err != nil {
+			return nil, fmt.Errorf("ダッシュボード %s の書き込みに失敗しました: %w", path, err)
+		}
+		written = append(written, path)
+	}
+
+	if err := vfs.MkdirAll(filepath.Dir(opts.ProvisioningPath), 0755); err != nil {
+		return nil, fmt.Errorf("プロビジョニングディレクトリの作成に失敗しました: %w", err)
+	}
+	provisioning := fmt.Sprintf(provisioningTemplate, opts.ContainerDir)
+	if err := vfs.WriteFile(opts.ProvisioningPath, []byte(provisioning), 0644); err != nil {
+		return nil, fmt.Errorf("プロビジョニングYAMLの書き込みに失敗しました: %w", err)
+	}
+	written = append(written, opts.ProvisioningPath)
+
+	sort.Strings(written)
+	return written, nil
+}
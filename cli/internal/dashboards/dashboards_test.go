@@ -0,0 +1,211 @@
+package dashboards
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mcctl/internal/server"
+)
+
+// grafanaDashboard は、テストで確認するダッシュボードJSONの一部です。
+type grafanaDashboard struct {
+	Title  string `json:"title"`
+	UID    string `json:"uid"`
+	Panels []struct {
+		Title      string `json:"title"`
+		Datasource struct {
+			UID string `json:"uid"`
+		} `json:"datasource"`
+		Targets []struct {
+			Expr  string `json:"expr"`
+			RefID string `json:"refId"`
+		} `json:"targets"`
+	} `json:"panels"`
+	Templating struct {
+		List []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"list"`
+	} `json:"templating"`
+}
+
+func setup(t *testing.T, names ...string) Options {
+	t.Helper()
+	dir := t.TempDir()
+	var servers []server.Server
+	for _, name := range names {
+		servers = append(servers, server.Server{Name: name, Type: "paper", Address: name + ":25565"})
+	}
+	serversPath := filepath.Join(dir, "servers.json")
+	if err := server.SaveServers(serversPath, servers); err != nil {
+		t.Fatal(err)
+	}
+	return Options{
+		ServersPath:      serversPath,
+		OutputDir:        filepath.Join(dir, "dashboards"),
+		ProvisioningPath: filepath.Join(dir, "provisioning", "mcctl.yaml"),
+		ContainerDir:     "/etc/grafana/dashboards/mcctl",
+	}
+}
+
+func readDashboard(t *testing.T, path string) grafanaDashboard {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d grafanaDashboard
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatalf("%s が不正なJSONです: %v", path, err)
+	}
+	return d
+}
+
+// exprs はダッシュボードのすべてのクエリを返します。
+func exprs(d grafanaDashboard) []string {
+	var out []string
+	for _, p := range d.Panels {
+		for _, t := range p.Targets {
+			out = append(out, t.Expr)
+		}
+	}
+	return out
+}
+
+func TestGenerate(t *testing.T) {
+	opts := setup(t, "survival", "creative")
+	written, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(opts.OutputDir, "creative.json"),
+		filepath.Join(opts.OutputDir, "survival.json"),
+		opts.ProvisioningPath,
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("written = %v, want %v", written, want)
+	}
+
+	for _, name := range []string{"survival", "creative"} {
+		d := readDashboard(t, filepath.Join(opts.OutputDir, name+".json"))
+		if d.UID != "mcctl-"+name || d.Title != "Minecraft: "+name {
+			t.Errorf("%s: uid = %q, title = %q", name, d.UID, d.Title)
+		}
+		if len(d.Panels) == 0 {
+			t.Fatalf("%s: パネルがありません", name)
+		}
+		for _, p := range d.Panels {
+			if p.Datasource.UID != "${DS_PROMETHEUS}" {
+				t.Errorf("%s: パネル %s のデータソースが %q です", name, p.Title, p.Datasource.UID)
+			}
+			for j, target := range p.Targets {
+				if want := string(rune('A' + j)); target.RefID != want {
+					t.Errorf("%s: パネル %s の refId = %q, want %q", name, p.Title, target.RefID, want)
+				}
+			}
+		}
+		if len(d.Templating.List) != 1 || d.Templating.List[0].Name != "DS_PROMETHEUS" || d.Templating.List[0].Type != "datasource" {
+			t.Errorf("%s: templating = %+v, want DS_PROMETHEUS だけ", name, d.Templating.List)
+		}
+
+		queries := strings.Join(exprs(d), "\n")
+		for _, q := range []string{
+			`mcctl_server_up{name="` + name + `"}`,
+			`mcctl_server_players_online{name="` + name + `"}`,
+			`mcctl_server_tps{name="` + name + `",window="1m"}`,
+			`mcctl_server_mspt_milliseconds{name="` + name + `"}`,
+			`sum(container_memory_rss{name="minecraft-` + name + `-server"})`,
+			`sum(rate(container_cpu_usage_seconds_total{name="minecraft-` + name + `-server"}[5m])) * 100`,
+		} {
+			if !strings.Contains(queries, q) {
+				t.Errorf("%s: クエリ %s がありません:\n%s", name, q, queries)
+			}
+		}
+		// 他のサーバーのクエリが混ざっていない
+		other := map[string]string{"survival": "creative", "creative": "survival"}[name]
+		if strings.Contains(queries, other) {
+			t.Errorf("%s: %s のクエリが含まれています:\n%s", name, other, queries)
+		}
+	}
+
+	provisioning, err := os.ReadFile(opts.ProvisioningPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(provisioning), "path: /etc/grafana/dashboards/mcctl\n") {
+		t.Errorf("プロビジョニングYAMLに ContainerDir がありません:\n%s", provisioning)
+	}
+}
+
+func TestGenerateSingle(t *testing.T) {
+	opts := setup(t, "survival", "creative")
+	opts.Single = true
+	if _, err := Generate(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	d := readDashboard(t, filepath.Join(opts.OutputDir, "servers.json"))
+	if d.UID != "mcctl-servers" {
+		t.Errorf("uid = %q", d.UID)
+	}
+	var names []string
+	for _, v := range d.Templating.List {
+		names = append(names, v.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"DS_PROMETHEUS", "server"}) {
+		t.Errorf("templating = %v, want [DS_PROMETHEUS server]", names)
+	}
+	queries := strings.Join(exprs(d), "\n")
+	if !strings.Contains(queries, `mcctl_server_up{name="$server"}`) {
+		t.Errorf("server 変数を使ったクエリがありません:\n%s", queries)
+	}
+	if _, err := os.Stat(filepath.Join(opts.OutputDir, "survival.json")); !os.IsNotExist(err) {
+		t.Errorf("1枚にまとめた場合にサーバーごとのダッシュボードがあります: %v", err)
+	}
+}
+
+func TestGenerateRemovesStale(t *testing.T) {
+	opts := setup(t, "survival", "creative")
+	if _, err := Generate(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// 手で置いたダッシュボードと、JSON 以外のファイル
+	unrelated := map[string]string{
+		"custom.json":  `{"title": "My Dashboard", "uid": "my-dashboard"}`,
+		"broken.json":  `{`,
+		"README.md":    "# dashboards\n",
+		"creative.bak": "backup",
+	}
+	for name, content := range unrelated {
+		if err := os.WriteFile(filepath.Join(opts.OutputDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// creative を削除して生成し直す
+	if err := server.SaveServers(opts.ServersPath, []server.Server{{Name: "survival", Type: "paper", Address: "survival:25565"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(opts.OutputDir, "creative.json")); !os.IsNotExist(err) {
+		t.Errorf("削除したサーバーのダッシュボードが残っています: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(opts.OutputDir, "survival.json")); err != nil {
+		t.Errorf("survival.json がありません: %v", err)
+	}
+	for name, content := range unrelated {
+		data, err := os.ReadFile(filepath.Join(opts.OutputDir, name))
+		if err != nil || string(data) != content {
+			t.Errorf("%s が変更されました: %q, %v", name, data, err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package diff は行単位の unified diff を作ります。
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Context は変更の前後に表示する行数です。
+const Context = 3
+
+// maxCells を超える大きさのファイルは、行ごとの比較をせずに全体を置き換えとして表示します。
+const maxCells = 4_000_000
+
+type op struct {
+	kind byte // ' ', '-', '+'
+	line string
+}
+
+// Unified は a から b への unified diff を返します。内容が同じ場合は空文字列を返します。
+func Unified(oldName, newName string, a, b []byte) string {
+	if string(a) == string(b) {
+		return ""
+	}
+	ops := edits(splitLines(string(a)), splitLines(string(b)))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks(ops) {
+		sb.WriteString(h)
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// edits は最長共通部分列から編集手順を求めます。
+func edits(a, b []string) []op {
+	// 先頭と末尾の共通部分は比較から外す。
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	var ops []op
+	for _, l := range a[:pre] {
+		ops = append(ops, op{' ', l})
+	}
+	if len(ma)*len(mb) > maxCells {
+		for _, l := range ma {
+			ops = append(ops, op{'-', l})
+		}
+		for _, l := range mb {
+			ops = append(ops, op{'+', l})
+		}
+	} else {
+		ops = append(ops, lcs(ma, mb)...)
+	}
+	for _, l := range a[len(a)-suf:] {
+		ops = append(ops, op{' ', l})
+	}
+	return ops
+}
+
+func lcs(a, b []string) []op {
+	n, m := len(a), len(b)
+	// dp[i][j] は a[i:] と b[j:] の最長共通部分列の長さ
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] >= dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case dp[i+1][j] >= dp[i][j+1]:
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, op{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, op{'+', b[j]})
+	}
+	return ops
+}
+
+// hunks は変更箇所を前後 Context 行とともに @@ 単位にまとめます。
+func hunks(ops []op) []string {
+	var out []string
+	for start := 0; start < len(ops); {
+		// 次の変更を探す
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		// 変更の間の共通行が 2*Context 以下なら同じハンクにする
+		last := first
+		for k := first; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				last = k
+				continue
+			}
+			if k-last > 2*Context {
+				break
+			}
+		}
+		from := max(first-Context, 0)
+		to := min(last+Context+1, len(ops))
+
+		// ハンクの開始行番号は、それより前の行数から求める
+		aLine, bLine := 1, 1
+		for _, o := range ops[:from] {
+			if o.kind != '+' {
+				aLine++
+			}
+			if o.kind != '-' {
+				bLine++
+			}
+		}
+		aCount, bCount := 0, 0
+		var body strings.Builder
+		for _, o := range ops[from:to] {
+			if o.kind != '+' {
+				aCount++
+			}
+			if o.kind != '-' {
+				bCount++
+			}
+			body.WriteByte(o.kind)
+			body.WriteString(o.line)
+			if !strings.HasSuffix(o.line, "\n") {
+				body.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		out = append(out, fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))+body.String())
+		start = to
+	}
+	return out
+}
+
+func hunkRange(line, count int) string {
+	if count == 0 {
+		// 空の範囲は直前の行番号で表す
+		return fmt.Sprintf("%d,0", line-1)
+	}
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
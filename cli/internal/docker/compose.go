@@ -0,0 +1,94 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"mcctl/internal/server"
+	"mcctl/internal/vfs"
+)
+
+// Compose は `docker compose` コマンドのラッパーです。
+// File はサービスを定義している docker-compose.yml のパスです。
+type Compose struct {
+	File string
+}
+
+func (c Compose) run(args ...string) (string, error) {
+	args = append([]string{"compose", "-f", c.File}, args...)
+	cmd := exec.Command("docker", args...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("docker %s に失敗しました: %w\n%s", strings.Join(args, " "), err, out.String())
+	}
+	return out.String(), nil
+}
+
+// mutate runs a command that changes containers.
+// --dry-run でファイルへの書き込みを記録している間は、コンテナも変更しません。
+func (c Compose) mutate(args ...string) error {
+	if vfs.Recording() {
+		return nil
+	}
+	_, err := c.run(args...)
+	return err
+}
+
+// Up builds if necessary and starts the service in the background.
+func (c Compose) Up(service string) error {
+	return c.mutate("up", "-d", "--build", service)
+}
+
+// Start starts an existing, stopped service container.
+func (c Compose) Start(service string) error {
+	return c.mutate("start", service)
+}
+
+// Stop stops the service container without removing it.
+func (c Compose) Stop(service string) error {
+	return c.mutate("stop", service)
+}
+
+// Restart restarts the service container.
+func (c Compose) Restart(service string) error {
+	return c.mutate("restart", service)
+}
+
+// Remove stops and removes the service container. Volumes and bind mounts are kept.
+func (c Compose) Remove(service string) error {
+	return c.mutate("rm", "--stop", "--force", service)
+}
+
+// IsRunning reports whether the service has a running container.
+func (c Compose) IsRunning(service string) (bool, error) {
+	out, err := c.run("ps", "--status", "running", "--services")
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == service {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ForService は、service を定義している最初の docker-compose.yml の Compose を返します。
+// 手作業で作られたサーバー (例: lobby) はルートの docker-compose.yml に定義されているため、
+// 複数のファイルを順に探します。
+func ForService(files []string, service string) (Compose, error) {
+	for _, file := range files {
+		ok, err := server.HasDockerComposeService(file, service)
+		if err != nil {
+			return Compose{}, err
+		}
+		if ok {
+			return Compose{File: file}, nil
+		}
+	}
+	return Compose{}, fmt.Errorf("サービス %s が docker-compose.yml に見つかりません", service)
+}
@@ -0,0 +1,561 @@
+// Package doctor は servers.json、docker-compose.yml、velocity.toml、テンプレートの間の食い違いを検出します。
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mcctl/internal/server"
+	"mcctl/internal/vfs"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Severity は問題の重大度です。
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Problem は検出した1つの問題です。
+type Problem struct {
+	Severity Severity
+	// Subject は問題のあるファイルやサーバーです。
+	Subject    string
+	Message    string
+	Suggestion string
+	// Fix は安全に自動修正できる場合の修正処理です。nil の場合は手作業での修正が必要です。
+	Fix func() error
+}
+
+// Fixable reports whether the problem can be fixed by --fix.
+func (p Problem) Fixable() bool {
+	return p.Fix != nil
+}
+
+// Proxy は検査する Velocity の設定ファイルです。
+type Proxy struct {
+	Name   string
+	Config string
+}
+
+// Options は検査対象のファイルです。
+type Options struct {
+	ServersPath     string
+	ComposePath     string // サーバーのサービスを定義する docker-compose.yml
+	RootComposePath string // プロキシや監視を定義する docker-compose.yml
+	Proxies         []Proxy
+	Domain          string
+}
+
+// Check はすべての検査を行い、見つかった問題を返します。
+// ファイルを読めないなど検査自体ができない場合はエラーを返します。
+func Check(opts Options) ([]Problem, error) {
+	servers, err := server.LoadServers(opts.ServersPath)
+	if err != nil {
+		return nil, err
+	}
+	composes := make(map[string]*composeFile)
+	for _, path := range []string{opts.ComposePath, opts.RootComposePath} {
+		c, err := loadCompose(path)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			composes[path] = c
+		}
+	}
+	proxies := make(map[string]*velocityConfig)
+	for _, p := range opts.Proxies {
+		v, err := loadVelocity(p.Config)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			proxies[p.Config] = v
+		}
+	}
+
+	c := &checker{opts: opts, servers: servers, composes: composes, proxies: proxies}
+	c.checkServers()
+	c.checkCompose()
+	c.checkPorts()
+	c.checkProxies()
+	c.checkTemplates()
+	return c.problems, nil
+}
+
+type checker struct {
+	opts     Options
+	servers  []server.Server
+	composes map[string]*composeFile
+	proxies  map[string]*velocityConfig
+	problems []Problem
+}
+
+func (c *checker) add(p Problem) {
+	c.problems = append(c.problems, p)
+}
+
+// composePaths returns the loaded compose files in a stable order.
+func (c *checker) composePaths() []string {
+	paths := make([]string, 0, len(c.composes))
+	for path := range c.composes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// hostResolves reports whether host is a service name or container_name in any compose file.
+func (c *checker) hostResolves(host string) bool {
+	for _, cf := range c.composes {
+		for name, svc := range cf.Services {
+			if name == host || svc.ContainerName == host {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasService reports whether name is a service in any loaded compose file.
+func (c *checker) hasService(name string) bool {
+	for _, cf := range c.composes {
+		if cf.Services[name] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkServers は servers.json の各サーバーについて、ディレクトリ、サービス、プロキシへの登録を確認します。
+func (c *checker) checkServers() {
+	for _, s := range c.servers {
+		s := s
+		subject := fmt.Sprintf("%s: %s", c.opts.ServersPath, s.Name)
+
+		if _, err := server.GetServerType(s.Type); err != nil {
+			c.add(Problem{
+				Severity:   SeverityError,
+				Subject:    subject,
+				Message:    fmt.Sprintf("サーバータイプ %q が定義されていません", s.Type),
+				Suggestion: "`mcctl types list` で利用できるタイプを確認し、servers.json の type を修正してください",
+			})
+			continue
+		}
+
+		dir := server.ServerDirectory(s.Name)
+		if _, err := vfs.Stat(dir); err != nil {
+			c.add(Problem{
+				Severity:   SeverityError,
+				Subject:    subject,
+				Message:    fmt.Sprintf("サーバーディレクトリ %s がありません", dir),
+				Suggestion: "テンプレートからサーバーディレクトリを作成します",
+				Fix: func() error {
+					return server.CreateServerDirectory(s.Name, s.Type)
+				},
+			})
+		}
+
+		// adopt したサーバーはルートの docker-compose.yml に定義されていることがある。
+		// どれにもない場合だけ追加し、同じサービスを二重に定義しないようにする
+		if !c.hasService(s.Name) {
+			c.add(Problem{
+				Severity:   SeverityError,
+				Subject:    subject,
+				Message:    fmt.Sprintf("%s にも %s にもサービスがありません", c.opts.ComposePath, c.opts.RootComposePath),
+				Suggestion: "サーバータイプの既定の設定でサービスを追加します",
+				Fix: func() error {
+					return server.AddDockerComposeService(c.opts.ComposePath, s.Name, s.Type)
+				},
+			})
+		}
+
+		for _, p := range c.opts.Proxies {
+			v := c.proxies[p.Config]
+			if v != nil && v.Servers[s.Name] != "" {
+				continue
+			}
+			proxy := p
+			c.add(Problem{
+				Severity:   SeverityWarning,
+				Subject:    subject,
+				Message:    fmt.Sprintf("プロキシ %s (%s) に登録されていません", proxy.Name, proxy.Config),
+				Suggestion: fmt.Sprintf("%s = '%s' を [servers] に追加します", s.Name, s.Address),
+				Fix: func() error {
+					return server.AddVelocityServerConfig(proxy.Config, s.Name, s.Address, c.opts.Domain)
+				},
+			})
+		}
+
+		if host, _, ok := strings.Cut(s.Address, ":"); ok && !c.hostResolves(host) {
+			c.add(Problem{
+				Severity:   SeverityWarning,
+				Subject:    subject,
+				Message:    fmt.Sprintf("アドレス %s のホスト %s はどの docker-compose.yml のサービスにも一致しません", s.Address, host),
+				Suggestion: fmt.Sprintf("address を %s のようにサービス名にしてください", s.Name+":25565"),
+			})
+		}
+	}
+}
+
+// checkCompose は、サービスのビルドコンテキストとボリュームのマウント元を確認します。
+func (c *checker) checkCompose() {
+	registered := make(map[string]bool, len(c.servers))
+	for _, s := range c.servers {
+		registered[s.Name] = true
+	}
+
+	for _, path := range c.composePaths() {
+		cf := c.composes[path]
+		base := filepath.Dir(path)
+		for _, name := range cf.serviceNames() {
+			svc := cf.Services[name]
+			subject := fmt.Sprintf("%s: %s", path, name)
+
+			if path == c.opts.ComposePath && !registered[name] {
+				c.add(Problem{
+					Severity:   SeverityWarning,
+					Subject:    subject,
+					Message:    fmt.Sprintf("%s に登録されていないサービスです", c.opts.ServersPath),
+					Suggestion: "mcctl で管理する場合は servers.json に追加してください",
+				})
+			}
+
+			contextOK := true
+			if ctx := svc.buildContext(); ctx != "" && !isRemote(ctx) {
+				dir := filepath.Join(base, ctx)
+				if _, err := vfs.Stat(dir); err != nil {
+					contextOK = false
+					c.add(Problem{
+						Severity:   SeverityError,
+						Subject:    subject,
+						Message:    fmt.Sprintf("ビルドコンテキスト %s がありません", ctx),
+						Suggestion: "ディレクトリを作成するか、build.context を修正してください",
+					})
+				} else if _, err := vfs.Stat(filepath.Join(dir, svc.dockerfile())); err != nil {
+					c.add(Problem{
+						Severity:   SeverityError,
+						Subject:    subject,
+						Message:    fmt.Sprintf("%s に %s がありません", ctx, svc.dockerfile()),
+						Suggestion: "テンプレートの Dockerfile をコピーしてください",
+					})
+				}
+			}
+
+			for _, src := range svc.bindSources() {
+				host := filepath.Join(base, src)
+				if _, err := vfs.Stat(host); err == nil {
+					continue
+				}
+				p := Problem{
+					Severity:   SeverityError,
+					Subject:    subject,
+					Message:    fmt.Sprintf("ボリュームのマウント元 %s がありません", src),
+					Suggestion: "ファイルを作成するか、volumes から削除してください (存在しないパスは Docker がディレクトリとして作成します)",
+				}
+				// マウント元がディレクトリと分かる場合だけ、空のディレクトリを作成して修正する。
+				if contextOK && filepath.Ext(src) == "" {
+					if _, err := vfs.Stat(filepath.Dir(host)); err == nil {
+						p.Suggestion = "空のディレクトリを作成します"
+						p.Fix = func() error { return vfs.MkdirAll(host, 0755) }
+					}
+				}
+				c.add(p)
+			}
+		}
+	}
+}
+
+// checkPorts は、ホストに公開するポートとサーバーのアドレスの重複を確認します。
+func (c *checker) checkPorts() {
+	published := make(map[string][]string)
+	for _, path := range c.composePaths() {
+		cf := c.composes[path]
+		for _, name := range cf.serviceNames() {
+			for _, port := range cf.Services[name].hostPorts() {
+				published[port] = append(published[port], fmt.Sprintf("%s: %s", path, name))
+			}
+		}
+	}
+	for _, port := range sortedKeys(published) {
+		if users := published[port]; len(users) > 1 {
+			c.add(Problem{
+				Severity:   SeverityError,
+				Subject:    "ports",
+				Message:    fmt.Sprintf("ホストのポート %s が複数のサービスで公開されています: %s", port, strings.Join(users, ", ")),
+				Suggestion: "いずれかのサービスのポートを変更してください",
+			})
+		}
+	}
+
+	addresses := make(map[string][]string)
+	for _, s := range c.servers {
+		addresses[s.Address] = append(addresses[s.Address], s.Name)
+	}
+	for _, addr := range sortedKeys(addresses) {
+		if names := addresses[addr]; len(names) > 1 {
+			c.add(Problem{
+				Severity:   SeverityError,
+				Subject:    c.opts.ServersPath,
+				Message:    fmt.Sprintf("アドレス %s が複数のサーバーで使われています: %s", addr, strings.Join(names, ", ")),
+				Suggestion: "サーバーごとに異なるホストまたはポートを指定してください",
+			})
+		}
+	}
+}
+
+// checkProxies は、Velocity の servers、try、forced-hosts を確認します。
+func (c *checker) checkProxies() {
+	for _, p := range c.opts.Proxies {
+		v := c.proxies[p.Config]
+		if v == nil {
+			continue
+		}
+		targets := make(map[string][]string)
+		for _, name := range sortedKeys(v.Servers) {
+			addr := v.Servers[name]
+			subject := fmt.Sprintf("%s: servers.%s", p.Config, name)
+			targets[addr] = append(targets[addr], name)
+
+			host, _, _ := strings.Cut(addr, ":")
+			if !c.hostResolves(host) {
+				c.add(Problem{
+					Severity:   SeverityError,
+					Subject:    subject,
+					Message:    fmt.Sprintf("アドレス %s のホスト %s はどの docker-compose.yml のサービスにも一致しません", addr, host),
+					Suggestion: "サービス名に合わせてアドレスを修正するか、エントリーを削除してください",
+				})
+				continue
+			}
+			if host != name && c.hostResolves(name) {
+				c.add(Problem{
+					Severity:   SeverityWarning,
+					Subject:    subject,
+					Message:    fmt.Sprintf("サービス %s があるのに、アドレスは別のサービス %s を指しています", name, host),
+					Suggestion: fmt.Sprintf("意図したものでなければ %s = '%s:25565' に修正してください", name, name),
+				})
+			}
+		}
+		for _, addr := range sortedKeys(targets) {
+			if names := targets[addr]; len(names) > 1 {
+				c.add(Problem{
+					Severity:   SeverityWarning,
+					Subject:    p.Config,
+					Message:    fmt.Sprintf("%s が同じアドレス %s を指しています", strings.Join(names, ", "), addr),
+					Suggestion: "重複したエントリーを削除してください",
+				})
+			}
+		}
+
+		for _, name := range v.Try {
+			if v.Servers[name] == "" {
+				c.add(Problem{
+					Severity:   SeverityError,
+					Subject:    fmt.Sprintf("%s: try", p.Config),
+					Message:    fmt.Sprintf("%s は [servers] にありません", name),
+					Suggestion: "[servers] に追加するか、try から削除してください",
+				})
+			}
+		}
+		for _, host := range sortedKeys(v.ForcedHosts) {
+			for _, name := range v.ForcedHosts[host] {
+				if v.Servers[name] == "" {
+					c.add(Problem{
+						Severity:   SeverityError,
+						Subject:    fmt.Sprintf("%s: forced-hosts.%s", p.Config, host),
+						Message:    fmt.Sprintf("%s は [servers] にありません", name),
+						Suggestion: "[servers] に追加するか、forced-hosts から削除してください",
+					})
+				}
+			}
+		}
+	}
+}
+
+// checkTemplates は、各サーバータイプのテンプレートの継承、Dockerfile、テンプレートファイルを確認します。
+func (c *checker) checkTemplates() {
+	for _, info := range server.ListServerTypes() {
+		subject := fmt.Sprintf("type %s", info.Name)
+		if info.Err != nil {
+			c.add(Problem{Severity: SeverityError, Subject: subject, Message: info.Err.Error(), Suggestion: "type.yaml を修正してください"})
+			continue
+		}
+		impl, err := server.GetServerType(info.Name)
+		if err != nil {
+			c.add(Problem{Severity: SeverityError, Subject: subject, Message: err.Error()})
+			continue
+		}
+		chain, err := server.TemplateChain(info.Name)
+		if err != nil {
+			c.add(Problem{Severity: SeverityError, Subject: subject, Message: err.Error(), Suggestion: "テンプレートディレクトリと template.yaml の extends を確認してください"})
+			continue
+		}
+		context := filepath.Join(filepath.Dir(c.opts.ComposePath), impl.GetTemplatePath())
+		if _, err := vfs.Stat(filepath.Join(context, "Dockerfile")); err != nil {
+			c.add(Problem{
+				Severity:   SeverityError,
+				Subject:    subject,
+				Message:    fmt.Sprintf("%s に Dockerfile がありません", context),
+				Suggestion: "Dockerfile を追加してください",
+			})
+		}
+		for _, file := range impl.GetTemplateFiles() {
+			if _, err := server.RenderTemplateFile(chain, file); err != nil {
+				c.add(Problem{
+					Severity:   SeverityError,
+					Subject:    subject,
+					Message:    fmt.Sprintf("テンプレートファイル %s がありません", file),
+					Suggestion: fmt.Sprintf("%s またはその親テンプレートに %s を追加してください", chain[len(chain)-1], file),
+				})
+			}
+		}
+	}
+}
+
+// composeFile は docker-compose.yml のうち doctor が見る項目です。
+type composeFile struct {
+	Services map[string]*composeService `yaml:"services"`
+}
+
+type composeService struct {
+	Build         interface{}   `yaml:"build"`
+	ContainerName string        `yaml:"container_name"`
+	Volumes       []interface{} `yaml:"volumes"`
+	Ports         []interface{} `yaml:"ports"`
+}
+
+func loadCompose(path string) (*composeFile, error) {
+	data, err := vfs.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s の読み込みに失敗しました: %w", path, err)
+	}
+	var cf composeFile
+	if err := yaml.Unmarshal(data, &cf); err != nil {
+		return nil, fmt.Errorf("%s のパースに失敗しました: %w", path, err)
+	}
+	return &cf, nil
+}
+
+func (cf *composeFile) serviceNames() []string {
+	return sortedKeys(cf.Services)
+}
+
+// buildContext returns build.context, or build itself when it is a string.
+func (s *composeService) buildContext() string {
+	switch b := s.Build.(type) {
+	case string:
+		return b
+	case map[string]interface{}:
+		ctx, _ := b["context"].(string)
+		return ctx
+	}
+	return ""
+}
+
+func (s *composeService) dockerfile() string {
+	if b, ok := s.Build.(map[string]interface{}); ok {
+		if f, ok := b["dockerfile"].(string); ok && f != "" {
+			return f
+		}
+	}
+	return "Dockerfile"
+}
+
+// bindSources returns the host paths of bind mounts written in the short syntax.
+// 名前付きボリュームは対象外です。
+func (s *composeService) bindSources() []string {
+	var sources []string
+	for _, v := range s.Volumes {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		src, _, ok := strings.Cut(str, ":")
+		if !ok || !(strings.HasPrefix(src, "./") || strings.HasPrefix(src, "../")) {
+			continue
+		}
+		sources = append(sources, src)
+	}
+	return sources
+}
+
+// hostPorts returns the published host ports, e.g. "25565" for "25565:25577".
+func (s *composeService) hostPorts() []string {
+	var ports []string
+	for _, p := range s.Ports {
+		str := fmt.Sprint(p)
+		str, _, _ = strings.Cut(str, "/")
+		parts := strings.Split(str, ":")
+		switch len(parts) {
+		case 2:
+			ports = append(ports, parts[0])
+		case 3:
+			ports = append(ports, parts[0]+":"+parts[1])
+		}
+	}
+	return ports
+}
+
+func isRemote(ctx string) bool {
+	return strings.Contains(ctx, "://") || strings.HasPrefix(ctx, "git@")
+}
+
+// velocityConfig は velocity.toml のうち doctor が見る項目です。
+type velocityConfig struct {
+	Try         []string            `toml:"try"`
+	Servers     map[string]string   `toml:"servers"`
+	ForcedHosts map[string][]string `toml:"forced-hosts"`
+}
+
+func loadVelocity(path string) (*velocityConfig, error) {
+	data, err := vfs.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s の読み込みに失敗しました: %w", path, err)
+	}
+	// Velocity の標準の書式では try は [servers] の中にあるため、どちらの位置でも読み取る。
+	var raw struct {
+		Try         []string               `toml:"try"`
+		Servers     map[string]interface{} `toml:"servers"`
+		ForcedHosts map[string][]string    `toml:"forced-hosts"`
+	}
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s のパースに失敗しました: %w", path, err)
+	}
+	v := &velocityConfig{Try: raw.Try, Servers: make(map[string]string), ForcedHosts: raw.ForcedHosts}
+	for name, addr := range raw.Servers {
+		switch a := addr.(type) {
+		case string:
+			v.Servers[name] = a
+		case []interface{}:
+			if name == "try" {
+				for _, t := range a {
+					v.Try = append(v.Try, fmt.Sprint(t))
+				}
+			}
+		}
+	}
+	return v, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,382 @@
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcctl/internal/server"
+)
+
+// healthyProject は問題のないプロジェクトです。パスはプロジェクトルートからの相対パスです。
+var healthyProject = map[string]string{
+	"minecraft/servers.json": `{"schemaVersion": 2, "servers": [
+		{"name": "survival", "type": "paper", "address": "survival:25565"}
+	]}`,
+	"minecraft/servers/survival/world/level.dat": "",
+	"minecraft/docker-compose.yml": `services:
+  survival:
+    build:
+      context: ./template/paper
+    container_name: minecraft-survival-server
+    volumes:
+      - ./servers/survival/world:/data/world
+`,
+	"minecraft/template/paper/Dockerfile":        "FROM itzg/minecraft-server\n",
+	"minecraft/template/paper/ops.json":          "[]\n",
+	"minecraft/template/paper/whitelist.json":    "[]\n",
+	"minecraft/template/paper/server.properties": "motd=paper\n",
+	"minecraft/template/paper/paper-global.yml":  "{}\n",
+	"docker-compose.yml": `services:
+  velocity:
+    image: itzg/bungeecord
+    ports:
+      - "25565:25577"
+`,
+	"velocity/velocity.toml": `[servers]
+survival = "survival:25565"
+try = ["survival"]
+
+[forced-hosts]
+"survival.example.com" = ["survival"]
+`,
+}
+
+// setupProject は files を一時ディレクトリに書き出し、そのディレクトリの Options を返します。
+// 値が空文字列ではなく "-" のファイルは書き出しません (healthyProject のファイルを消すために使います)。
+func setupProject(t *testing.T, files map[string]string) Options {
+	t.Helper()
+	root := t.TempDir()
+	old := server.MinecraftDir
+	server.MinecraftDir = filepath.Join(root, "minecraft")
+	t.Cleanup(func() { server.MinecraftDir = old })
+
+	for rel, content := range files {
+		if content == "-" {
+			continue
+		}
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return Options{
+		ServersPath:     filepath.Join(root, "minecraft/servers.json"),
+		ComposePath:     filepath.Join(root, "minecraft/docker-compose.yml"),
+		RootComposePath: filepath.Join(root, "docker-compose.yml"),
+		Proxies:         []Proxy{{Name: "velocity", Config: filepath.Join(root, "velocity/velocity.toml")}},
+		Domain:          "example.com",
+	}
+}
+
+// withFiles は healthyProject を files で上書きしたものを返します。
+func withFiles(files map[string]string) map[string]string {
+	merged := make(map[string]string, len(healthyProject)+len(files))
+	for k, v := range healthyProject {
+		merged[k] = v
+	}
+	for k, v := range files {
+		merged[k] = v
+	}
+	return merged
+}
+
+// wantProblem は期待する問題です。subject と message は部分一致で比べます。
+type wantProblem struct {
+	severity Severity
+	subject  string
+	message  string
+	fixable  bool
+}
+
+func (w wantProblem) matches(p Problem) bool {
+	return p.Severity == w.severity && strings.Contains(p.Subject, w.subject) &&
+		strings.Contains(p.Message, w.message) && p.Fixable() == w.fixable
+}
+
+// relevant は、テンプレートを用意していない組み込みタイプ (forge など) の問題を除きます。
+func relevant(problems []Problem) []Problem {
+	var out []Problem
+	for _, p := range problems {
+		if strings.HasPrefix(p.Subject, "type ") && p.Subject != "type paper" {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func format(problems []Problem) string {
+	var b strings.Builder
+	for _, p := range problems {
+		fmt.Fprintf(&b, "  %s %s: %s (fix=%v)\n", p.Severity, p.Subject, p.Message, p.Fixable())
+	}
+	return b.String()
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []wantProblem
+	}{
+		{
+			name:  "問題なし",
+			files: healthyProject,
+		},
+		{
+			name: "未定義のサーバータイプ",
+			files: withFiles(map[string]string{
+				"minecraft/servers.json": `{"schemaVersion": 2, "servers": [{"name": "survival", "type": "spigot", "address": "survival:25565"}]}`,
+			}),
+			want: []wantProblem{{SeverityError, "servers.json: survival", `サーバータイプ "spigot"`, false}},
+		},
+		{
+			name: "サーバーディレクトリとマウント元がない",
+			files: withFiles(map[string]string{
+				"minecraft/servers/survival/world/level.dat": "-",
+			}),
+			want: []wantProblem{
+				{SeverityError, "servers.json: survival", "サーバーディレクトリ", true},
+				{SeverityError, "docker-compose.yml: survival", "マウント元 ./servers/survival/world", false},
+			},
+		},
+		{
+			name: "マウント元のディレクトリだけがない",
+			files: withFiles(map[string]string{
+				"minecraft/servers/survival/world/level.dat": "-",
+				"minecraft/servers/survival/logs/latest.log": "",
+			}),
+			want: []wantProblem{{SeverityError, "docker-compose.yml: survival", "マウント元 ./servers/survival/world", true}},
+		},
+		{
+			name: "サービスがない",
+			files: withFiles(map[string]string{
+				"minecraft/docker-compose.yml": "services: {}\n",
+			}),
+			want: []wantProblem{
+				{SeverityError, "servers.json: survival", "サービスがありません", true},
+				{SeverityWarning, "servers.json: survival", "ホスト survival はどの docker-compose.yml", false},
+				{SeverityError, "velocity.toml: servers.survival", "ホスト survival はどの docker-compose.yml", false},
+			},
+		},
+		{
+			name: "ルートの docker-compose.yml にあるサービス",
+			files: withFiles(map[string]string{
+				"minecraft/docker-compose.yml": "services: {}\n",
+				"docker-compose.yml":           "services:\n  survival:\n    image: itzg/minecraft-server\n",
+			}),
+		},
+		{
+			name: "servers.json にないサービス",
+			files: withFiles(map[string]string{
+				"minecraft/docker-compose.yml": healthyProject["minecraft/docker-compose.yml"] + "  creative:\n    image: itzg/minecraft-server\n",
+			}),
+			want: []wantProblem{{SeverityWarning, "docker-compose.yml: creative", "登録されていないサービス", false}},
+		},
+		{
+			name: "ビルドコンテキストと Dockerfile",
+			files: withFiles(map[string]string{
+				"minecraft/docker-compose.yml": `services:
+  survival:
+    build: ./template/missing
+    volumes:
+      - ./servers/survival/world:/data/world
+  creative:
+    build:
+      context: ./template/paper
+      dockerfile: Dockerfile.creative
+`,
+				"minecraft/servers.json": `{"schemaVersion": 2, "servers": [
+					{"name": "survival", "type": "paper", "address": "survival:25565"},
+					{"name": "creative", "type": "paper", "address": "creative:25565"}
+				]}`,
+				"minecraft/servers/creative/world/level.dat": "",
+				"velocity/velocity.toml":                     "[servers]\nsurvival = \"survival:25565\"\ncreative = \"creative:25565\"\n",
+			}),
+			want: []wantProblem{
+				{SeverityError, "docker-compose.yml: survival", "ビルドコンテキスト ./template/missing", false},
+				{SeverityError, "docker-compose.yml: creative", "Dockerfile.creative がありません", false},
+			},
+		},
+		{
+			name: "プロキシに登録されていない",
+			files: withFiles(map[string]string{
+				"velocity/velocity.toml": "[servers]\n",
+			}),
+			want: []wantProblem{{SeverityWarning, "servers.json: survival", "プロキシ velocity", true}},
+		},
+		{
+			name: "velocity.toml がない",
+			files: withFiles(map[string]string{
+				"velocity/velocity.toml": "-",
+			}),
+			want: []wantProblem{{SeverityWarning, "servers.json: survival", "プロキシ velocity", true}},
+		},
+		{
+			name: "ポートとアドレスの重複",
+			files: withFiles(map[string]string{
+				"minecraft/docker-compose.yml": healthyProject["minecraft/docker-compose.yml"] + "    ports:\n      - \"25565:25565/tcp\"\n",
+				"minecraft/servers.json": `{"schemaVersion": 2, "servers": [
+					{"name": "survival", "type": "paper", "address": "survival:25565"},
+					{"name": "copy", "type": "paper", "address": "survival:25565"}
+				]}`,
+				"minecraft/servers/copy/world/level.dat": "",
+				"velocity/velocity.toml":                 "[servers]\nsurvival = \"survival:25565\"\ncopy = \"survival:25565\"\n",
+			}),
+			want: []wantProblem{
+				{SeverityError, "ports", "ホストのポート 25565", false},
+				{SeverityError, "servers.json", "アドレス survival:25565 が複数のサーバー", false},
+				{SeverityError, "servers.json: copy", "サービスがありません", true},
+				{SeverityWarning, "velocity.toml", "copy, survival が同じアドレス", false},
+			},
+		},
+		{
+			name: "Velocity の try と forced-hosts",
+			files: withFiles(map[string]string{
+				"velocity/velocity.toml": `try = ["lobby"]
+
+[servers]
+survival = "survival:25565"
+creative = "minecraft-survival-server:25565"
+hub = "hub:25565"
+
+[forced-hosts]
+"survival.example.com" = ["survival", "skyblock"]
+`,
+			}),
+			want: []wantProblem{
+				{SeverityError, "velocity.toml: servers.hub", "ホスト hub", false},
+				{SeverityError, "velocity.toml: try", "lobby は [servers] にありません", false},
+				{SeverityError, "velocity.toml: forced-hosts.survival.example.com", "skyblock は [servers] にありません", false},
+			},
+		},
+		{
+			name: "別のサービスを指すアドレス",
+			files: withFiles(map[string]string{
+				"minecraft/docker-compose.yml": healthyProject["minecraft/docker-compose.yml"] + "  creative:\n    image: itzg/minecraft-server\n",
+				"minecraft/servers.json": `{"schemaVersion": 2, "servers": [
+					{"name": "survival", "type": "paper", "address": "survival:25565"},
+					{"name": "creative", "type": "paper", "address": "creative:25565"}
+				]}`,
+				"minecraft/servers/creative/world/level.dat": "",
+				"velocity/velocity.toml":                     "[servers]\nsurvival = \"survival:25565\"\ncreative = \"survival:25566\"\n",
+			}),
+			want: []wantProblem{{SeverityWarning, "velocity.toml: servers.creative", "別のサービス survival", false}},
+		},
+		{
+			name: "テンプレートの Dockerfile とファイルがない",
+			files: withFiles(map[string]string{
+				"minecraft/template/paper/Dockerfile":       "-",
+				"minecraft/template/paper/paper-global.yml": "-",
+			}),
+			want: []wantProblem{
+				{SeverityError, "type paper", "Dockerfile がありません", false},
+				{SeverityError, "docker-compose.yml: survival", "./template/paper に Dockerfile がありません", false},
+				{SeverityError, "type paper", "テンプレートファイル paper-global.yml", false},
+			},
+		},
+		{
+			name: "親テンプレートのファイルを使う",
+			files: withFiles(map[string]string{
+				"minecraft/template/paper/template.yaml":    "extends: base\n",
+				"minecraft/template/paper/paper-global.yml": "-",
+				"minecraft/template/base/paper-global.yml":  "{}\n",
+			}),
+		},
+		{
+			name: "テンプレートの継承の循環",
+			files: withFiles(map[string]string{
+				"minecraft/template/paper/template.yaml": "extends: base\n",
+				"minecraft/template/base/template.yaml":  "extends: paper\n",
+			}),
+			want: []wantProblem{{SeverityError, "type paper", "循環", false}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := setupProject(t, tt.files)
+			problems, err := Check(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := relevant(problems)
+
+			unmatched := append([]Problem(nil), got...)
+			for _, w := range tt.want {
+				found := false
+				for i, p := range unmatched {
+					if w.matches(p) {
+						unmatched = append(unmatched[:i], unmatched[i+1:]...)
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("%+v が見つかりません", w)
+				}
+			}
+			if len(unmatched) > 0 {
+				t.Errorf("想定外の問題があります:\n%s", format(unmatched))
+			}
+		})
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"servers.json が壊れている", withFiles(map[string]string{"minecraft/servers.json": "{"})},
+		{"docker-compose.yml が壊れている", withFiles(map[string]string{"minecraft/docker-compose.yml": "services: [\n"})},
+		{"velocity.toml が壊れている", withFiles(map[string]string{"velocity/velocity.toml": "[servers\n"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if problems, err := Check(setupProject(t, tt.files)); err == nil {
+				t.Errorf("Check() = %v, want error", format(problems))
+			}
+		})
+	}
+}
+
+func TestFix(t *testing.T) {
+	opts := setupProject(t, withFiles(map[string]string{
+		"minecraft/servers/survival/world/level.dat": "-",
+		"minecraft/servers/survival/logs/latest.log": "",
+		"velocity/velocity.toml":                     "[servers]\n",
+	}))
+	problems, err := Check(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed := 0
+	for _, p := range relevant(problems) {
+		if !p.Fixable() {
+			t.Errorf("自動修正できない問題があります: %s: %s", p.Subject, p.Message)
+			continue
+		}
+		if err := p.Fix(); err != nil {
+			t.Fatalf("%s: %s の修正に失敗しました: %v", p.Subject, p.Message, err)
+		}
+		fixed++
+	}
+	if fixed != 2 {
+		t.Errorf("%d 件を修正しました, want 2", fixed)
+	}
+
+	// 修正した後は問題がない
+	problems, err = Check(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := relevant(problems); len(got) > 0 {
+		t.Errorf("修正後も問題が残っています:\n%s", format(got))
+	}
+}
@@ -0,0 +1,236 @@
+// Package exporter は、管理しているサーバーのメトリクスを Prometheus のテキスト形式で公開します。
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mcctl/internal/rcon"
+	"mcctl/internal/server"
+	"mcctl/internal/slp"
+)
+
+const (
+	pingTimeout = 3 * time.Second
+	rconTimeout = 3 * time.Second
+)
+
+// Exporter は /metrics へのリクエストごとに servers.json を読み直し、
+// 各サーバーに Server List Ping と (Paper 互換のタイプの場合は) RCON で問い合わせます。
+// そのため、サーバーを追加しても再起動は不要です。
+type Exporter struct {
+	ServersPath string
+}
+
+// sample は1台のサーバーから集めた値です。
+type sample struct {
+	labels  string
+	up      bool
+	latency time.Duration
+	online  int
+	max     int
+	tps     map[string]float64
+	mspt    float64
+	hasMSPT bool
+}
+
+// ServeHTTP writes the metrics of every managed server.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	servers, err := server.LoadServers(e.ServersPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// ?server=NAME が指定された場合はそのサーバーだけを返す。
+	// Prometheus からサーバーごとに別ターゲットとしてスクレイプするために使う。
+	if name := r.URL.Query().Get("server"); name != "" {
+		var filtered []server.Server
+		for _, s := range servers {
+			if s.Name == name {
+				filtered = append(filtered, s)
+			}
+		}
+		servers = filtered
+	}
+
+	samples := make([]sample, len(servers))
+	var wg sync.WaitGroup
+	for i, s := range servers {
+		wg.Add(1)
+		go func(i int, s server.Server) {
+			defer wg.Done()
+			samples[i] = e.collect(s)
+		}(i, s)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	write(w, samples, time.Since(start))
+}
+
+func (e *Exporter) collect(s server.Server) sample {
+	serverType := strings.ToLower(s.Type)
+	version := s.MCVersion
+	if version == "" {
+		if _, v, err := server.RuntimeInfo(e.ServersPath, s.Name); err == nil {
+			version = v
+		}
+	}
+	smp := sample{
+		labels: formatLabels("name", s.Name, "type", serverType, "version", version),
+	}
+
+	address, err := server.GameAddress(e.ServersPath, s.Name)
+	if err != nil {
+		return smp
+	}
+	resp, err := slp.Ping(address, pingTimeout)
+	if err != nil {
+		return smp
+	}
+	smp.up = true
+	smp.latency = resp.Latency
+	smp.online = resp.Players.Online
+	smp.max = resp.Players.Max
+
+	// TPS と MSPT は Paper (と Purpur などのフォーク) の独自コマンドでしか取得できない
+	if server.IsPaperCompatible(serverType) {
+		e.collectPaper(s.Name, &smp)
+	}
+	return smp
+}
+
+func (e *Exporter) collectPaper(name string, smp *sample) {
+	address, password, err := server.RCONTarget(e.ServersPath, name)
+	if err != nil {
+		return
+	}
+	client, err := rcon.Dial(address, password, rconTimeout)
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	if out, err := client.Command("tps"); err == nil {
+		smp.tps = parseTPS(out)
+	}
+	if out, err := client.Command("mspt"); err == nil {
+		smp.mspt, smp.hasMSPT = parseMSPT(out)
+	}
+}
+
+var (
+	formatCode = regexp.MustCompile(`§.`)
+	number     = regexp.MustCompile(`\d+(?:\.\d+)?`)
+)
+
+// parseTPS は Paper の `tps` の出力を解釈します。
+// 例: "§6TPS from last 1m, 5m, 15m: §a20.0, §a*20.0, §a19.98"
+func parseTPS(out string) map[string]float64 {
+	out = formatCode.ReplaceAllString(out, "")
+	_, values, found := strings.Cut(out, ": ")
+	if !found {
+		return nil
+	}
+	windows := []string{"1m", "5m", "15m"}
+	tps := make(map[string]float64)
+	for i, v := range number.FindAllString(values, len(windows)) {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			tps[windows[i]] = f
+		}
+	}
+	return tps
+}
+
+// parseMSPT は Paper の `mspt` の出力から直近5秒の平均を取り出します。
+// 例: "Server tick times (avg/min/max) from last 5s, 10s, 1m:\n◴ 1.2/0.8/3.4, ..."
+func parseMSPT(out string) (float64, bool) {
+	out = formatCode.ReplaceAllString(out, "")
+	_, values, found := strings.Cut(out, ":")
+	if !found {
+		return 0, false
+	}
+	m := number.FindString(values)
+	if m == "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(m, 64)
+	return f, err == nil
+}
+
+// labelEscaper は Prometheus のテキスト形式でラベルの値に必要なエスケープを行います。
+// Go の %q と違い、エスケープするのはバックスラッシュ、ダブルクォート、改行だけです。
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels は名前と値を交互に並べた pairs から `name="value",...` を作ります。
+func formatLabels(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+func write(w io.Writer, samples []sample, elapsed time.Duration) {
+	gauge := func(name, help string, value func(sample) (float64, bool)) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, s := range samples {
+			v, ok := value(s)
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(w, "%s{%s} %s\n", name, s.labels, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	}
+
+	gauge("mcctl_server_up", "Whether the server answered a Server List Ping.", func(s sample) (float64, bool) {
+		if s.up {
+			return 1, true
+		}
+		return 0, true
+	})
+	gauge("mcctl_server_ping_seconds", "Round-trip time of the Server List Ping.", func(s sample) (float64, bool) {
+		return s.latency.Seconds(), s.up
+	})
+	gauge("mcctl_server_players_online", "Number of players online.", func(s sample) (float64, bool) {
+		return float64(s.online), s.up
+	})
+	gauge("mcctl_server_players_max", "Maximum number of players.", func(s sample) (float64, bool) {
+		return float64(s.max), s.up
+	})
+
+	fmt.Fprintf(w, "# HELP mcctl_server_tps Ticks per second reported by Paper.\n# TYPE mcctl_server_tps gauge\n")
+	for _, s := range samples {
+		windows := make([]string, 0, len(s.tps))
+		for window := range s.tps {
+			windows = append(windows, window)
+		}
+		sort.Strings(windows)
+		for _, window := range windows {
+			fmt.Fprintf(w, "mcctl_server_tps{%s,%s} %s\n", s.labels, formatLabels("window", window),
+				strconv.FormatFloat(s.tps[window], 'g', -1, 64))
+		}
+	}
+
+	gauge("mcctl_server_mspt_milliseconds", "Average milliseconds per tick over the last 5s reported by Paper.", func(s sample) (float64, bool) {
+		return s.mspt, s.hasMSPT
+	})
+
+	fmt.Fprintf(w, "# HELP mcctl_scrape_duration_seconds Time taken to collect all server metrics.\n# TYPE mcctl_scrape_duration_seconds gauge\n")
+	fmt.Fprintf(w, "mcctl_scrape_duration_seconds %s\n", strconv.FormatFloat(elapsed.Seconds(), 'g', -1, 64))
+}
@@ -0,0 +1,176 @@
+package exporter
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTPS(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want map[string]float64
+	}{
+		{
+			name: "Paper の色コード付き",
+			out:  "§6TPS from last 1m, 5m, 15m: §a19.98, §a19.99, §a20.0",
+			want: map[string]float64{"1m": 19.98, "5m": 19.99, "15m": 20.0},
+		},
+		{
+			// 20 を超えると Paper は値を 20.0 に丸めて * を付ける
+			name: "20 を超えた値の *",
+			out:  "§6TPS from last 1m, 5m, 15m: §a*20.0, §a*20.0, §a*20.0",
+			want: map[string]float64{"1m": 20, "5m": 20, "15m": 20},
+		},
+		{
+			name: "低い TPS",
+			out:  "§6TPS from last 1m, 5m, 15m: §c12.34, §e17.5, §a19.2",
+			want: map[string]float64{"1m": 12.34, "5m": 17.5, "15m": 19.2},
+		},
+		{
+			name: "色コードなし",
+			out:  "TPS from last 1m, 5m, 15m: 20.0, 20.0, 19.95",
+			want: map[string]float64{"1m": 20, "5m": 20, "15m": 19.95},
+		},
+		{
+			name: "Paper 以外のサーバー",
+			out:  `Unknown command. Type "/help" for help.`,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTPS(tt.out); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTPS(%q) = %v, want %v", tt.out, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMSPT(t *testing.T) {
+	tests := []struct {
+		name   string
+		out    string
+		want   float64
+		wantOK bool
+	}{
+		{
+			name: "Paper の色コード付き",
+			out: "§6Server tick times §e(§7avg§e/§7min§e/§7max§e)§6 from last 5s§7,§6 10s§7,§6 1m§e:\n" +
+				"§6◴ §a1.2§7/§a0.8§7/§a3.4§e, §a1.3§7/§a0.7§7/§a4.1§e, §a1.1§7/§a0.6§7/§a12.0",
+			want:   1.2,
+			wantOK: true,
+		},
+		{
+			name: "重いサーバー",
+			out: "§6Server tick times §e(§7avg§e/§7min§e/§7max§e)§6 from last 5s§7,§6 10s§7,§6 1m§e:\n" +
+				"§6◴ §c61.5§7/§a40.2§7/§c120.8§e, §e48.0§7/§a30.1§7/§c99.9§e, §a30.0§7/§a10.0§7/§c80.0",
+			want:   61.5,
+			wantOK: true,
+		},
+		{
+			name:   "色コードなし",
+			out:    "Server tick times (avg/min/max) from last 5s, 10s, 1m:\n◴ 2.5/1.0/4.0, 2.0/1.0/4.0, 1.5/1.0/4.0",
+			want:   2.5,
+			wantOK: true,
+		},
+		{
+			name: "Paper 以外のサーバー",
+			out:  "Unknown or incomplete command, see below for error\nmspt<--[HERE]",
+		},
+		{
+			name: "値がない",
+			out:  "Server tick times (avg/min/max) from last 5s, 10s, 1m:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseMSPT(tt.out)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseMSPT(%q) = %v, %v, want %v, %v", tt.out, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	tests := []struct {
+		name  string
+		pairs []string
+		want  string
+	}{
+		{
+			name:  "通常の値",
+			pairs: []string{"name", "survival", "type", "paper"},
+			want:  `name="survival",type="paper"`,
+		},
+		{
+			name:  "バックスラッシュ、ダブルクォート、改行",
+			pairs: []string{"name", "a\\b\"c\nd"},
+			want:  `name="a\\b\"c\nd"`,
+		},
+		{
+			// Go の %q と違い、タブや日本語はそのまま出力する
+			name:  "タブと日本語",
+			pairs: []string{"name", "サバイバル\t1"},
+			want:  "name=\"サバイバル\t1\"",
+		},
+		{
+			name:  "空の値",
+			pairs: []string{"version", ""},
+			want:  `version=""`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatLabels(tt.pairs...); got != tt.want {
+				t.Errorf("formatLabels(%q) = %s, want %s", tt.pairs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	samples := []sample{
+		{
+			labels:  formatLabels("name", `my"server`, "type", "paper", "version", "1.20.4"),
+			up:      true,
+			latency: 25 * time.Millisecond,
+			online:  2,
+			max:     20,
+			tps:     map[string]float64{"1m": 19.5, "5m": 20, "15m": 20},
+			mspt:    1.2,
+			hasMSPT: true,
+		},
+		{
+			labels: formatLabels("name", "down", "type", "vanilla", "version", ""),
+		},
+	}
+	var buf bytes.Buffer
+	write(&buf, samples, 1500*time.Millisecond)
+	out := buf.String()
+
+	for _, line := range []string{
+		`mcctl_server_up{name="my\"server",type="paper",version="1.20.4"} 1`,
+		`mcctl_server_up{name="down",type="vanilla",version=""} 0`,
+		`mcctl_server_ping_seconds{name="my\"server",type="paper",version="1.20.4"} 0.025`,
+		`mcctl_server_players_online{name="my\"server",type="paper",version="1.20.4"} 2`,
+		`mcctl_server_players_max{name="my\"server",type="paper",version="1.20.4"} 20`,
+		`mcctl_server_tps{name="my\"server",type="paper",version="1.20.4",window="15m"} 20`,
+		`mcctl_server_tps{name="my\"server",type="paper",version="1.20.4",window="1m"} 19.5`,
+		`mcctl_server_tps{name="my\"server",type="paper",version="1.20.4",window="5m"} 20`,
+		`mcctl_server_mspt_milliseconds{name="my\"server",type="paper",version="1.20.4"} 1.2`,
+		`mcctl_scrape_duration_seconds 1.5`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("出力に %s がありません:\n%s", line, out)
+		}
+	}
+	// 応答しなかったサーバーは up 以外のメトリクスを出力しない
+	if strings.Contains(out, `ping_seconds{name="down"`) || strings.Contains(out, `players_online{name="down"`) {
+		t.Errorf("停止中のサーバーのメトリクスが出力されています:\n%s", out)
+	}
+}
@@ -0,0 +1,39 @@
+// Package filelock は、複数の mcctl のプロセス (デーモンと手動のコマンドなど) が
+// 同じファイルを同時に書き換えないためのロックファイルを扱います。
+package filelock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Lock は path のロックファイルの排他ロックを取り、解放する関数を返します。
+// 他のプロセスがロックを持っている場合は、解放されるまで待ちます。
+func Lock(path string) (func(), error) {
+	return lock(path, true)
+}
+
+// RLock は path のロックファイルの共有ロックを取り、解放する関数を返します。
+// 共有ロックは同時に複数持てますが、排他ロックとは同時に持てません。
+func RLock(path string) (func(), error) {
+	return lock(path, false)
+}
+
+func lock(path string, exclusive bool) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("ロックファイル %s を開けませんでした: %w", path, err)
+	}
+	if err := flock(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s をロックできませんでした: %w", path, err)
+	}
+	return func() {
+		funlock(f)
+		f.Close()
+	}, nil
+}
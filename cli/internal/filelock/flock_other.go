@@ -0,0 +1,13 @@
+//go:build !unix
+
+package filelock
+
+import "os"
+
+// flock は、flock(2) のない環境ではロックしません。
+// mcctl のデーモンはコンテナ (Linux) で動かすため、ここで競合することはありません。
+func flock(f *os.File, exclusive bool) error {
+	return nil
+}
+
+func funlock(f *os.File) {}
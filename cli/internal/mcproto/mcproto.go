@@ -0,0 +1,148 @@
+// Package mcproto は、Minecraft Java Edition のプロトコルで使われる
+// VarInt・文字列・パケットの読み書きを実装します。
+// 圧縮と暗号化が有効になる前 (ハンドシェイク・ステータス・ログイン開始) のみを扱います。
+package mcproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxPacketLength は受け付けるパケットの最大長です (プロトコル上の上限は 2^21-1)。
+const maxPacketLength = 1<<21 - 1
+
+// Handshake の next state
+const (
+	StateStatus = 1
+	StateLogin  = 2
+)
+
+var errVarIntTooBig = errors.New("VarIntが長すぎます")
+
+// AppendVarInt appends v encoded as a VarInt.
+func AppendVarInt(b []byte, v int32) []byte {
+	u := uint32(v)
+	for {
+		if u&^0x7F == 0 {
+			return append(b, byte(u))
+		}
+		b = append(b, byte(u&0x7F|0x80))
+		u >>= 7
+	}
+}
+
+// ReadVarInt reads a VarInt from r.
+func ReadVarInt(r io.ByteReader) (int32, error) {
+	var result uint32
+	for i := 0; i < 5; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint32(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			return int32(result), nil
+		}
+	}
+	return 0, errVarIntTooBig
+}
+
+// AppendString appends s prefixed with its length as a VarInt.
+func AppendString(b []byte, s string) []byte {
+	b = AppendVarInt(b, int32(len(s)))
+	return append(b, s...)
+}
+
+// ReadString reads a VarInt-prefixed UTF-8 string.
+func ReadString(r *bytes.Reader) (string, error) {
+	n, err := ReadVarInt(r)
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || int(n) > r.Len() {
+		return "", fmt.Errorf("不正な文字列長: %d", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// AppendUint16 appends v in big-endian order, as used for the handshake port.
+func AppendUint16(b []byte, v uint16) []byte {
+	return binary.BigEndian.AppendUint16(b, v)
+}
+
+// ReadPacket はパケットを1つ読み込み、パケットIDと残りのデータを返します。
+func ReadPacket(r *bufio.Reader) (int32, *bytes.Reader, error) {
+	length, err := ReadVarInt(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if length <= 0 || length > maxPacketLength {
+		return 0, nil, fmt.Errorf("不正なパケット長: %d", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+
+	payload := bytes.NewReader(data)
+	id, err := ReadVarInt(payload)
+	if err != nil {
+		return 0, nil, err
+	}
+	return id, payload, nil
+}
+
+// WritePacket は、長さとパケットIDを付けてパケットを書き込みます。
+func WritePacket(w io.Writer, id int32, payload []byte) error {
+	body := AppendVarInt(nil, id)
+	body = append(body, payload...)
+	packet := AppendVarInt(nil, int32(len(body)))
+	packet = append(packet, body...)
+	_, err := w.Write(packet)
+	return err
+}
+
+// Handshake は、接続開始時にクライアントが送るパケットです。
+type Handshake struct {
+	ProtocolVersion int32
+	ServerAddress   string
+	ServerPort      uint16
+	NextState       int32
+}
+
+// Encode returns the payload of the handshake packet (ID 0x00).
+func (h Handshake) Encode() []byte {
+	b := AppendVarInt(nil, h.ProtocolVersion)
+	b = AppendString(b, h.ServerAddress)
+	b = AppendUint16(b, h.ServerPort)
+	return AppendVarInt(b, h.NextState)
+}
+
+// DecodeHandshake decodes the payload of a handshake packet.
+func DecodeHandshake(r *bytes.Reader) (Handshake, error) {
+	var h Handshake
+	var err error
+	if h.ProtocolVersion, err = ReadVarInt(r); err != nil {
+		return h, err
+	}
+	if h.ServerAddress, err = ReadString(r); err != nil {
+		return h, err
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(r, port[:]); err != nil {
+		return h, err
+	}
+	h.ServerPort = binary.BigEndian.Uint16(port[:])
+	if h.NextState, err = ReadVarInt(r); err != nil {
+		return h, err
+	}
+	return h, nil
+}
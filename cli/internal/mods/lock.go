@@ -0,0 +1,212 @@
+package mods
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mcctl/internal/vfs"
+)
+
+// LockFileName はサーバーディレクトリに置くロックファイルの名前です。
+const LockFileName = "mods.lock"
+
+// Entry は、ロックファイルに記録された1つの MOD です。
+type Entry struct {
+	ID       string `json:"id"`
+	Version  string `json:"version"`
+	Filename string `json:"filename"`
+	SHA512   string `json:"sha512"`
+	Source   string `json:"source"`
+	URL      string `json:"url,omitempty"`
+}
+
+// Lock は mods.lock の内容です。
+type Lock struct {
+	Mods []Entry `json:"mods"`
+}
+
+// LoadLock はサーバーディレクトリの mods.lock を読み込みます。
+// ファイルが存在しない場合は空のロックを返します。
+func LoadLock(serverDir string) (*Lock, error) {
+	lock := &Lock{}
+	data, err := vfs.ReadFile(filepath.Join(serverDir, LockFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return lock, nil
+		}
+		return nil, fmt.Errorf("mods.lockの読み込みに失敗しました: %w", err)
+	}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("mods.lockのパースに失敗しました: %w", err)
+	}
+	return lock, nil
+}
+
+// Save は mods.lock を ID 順に並べて書き出します。
+func (l *Lock) Save(serverDir string) error {
+	sort.Slice(l.Mods, func(i, j int) bool { return l.Mods[i].ID < l.Mods[j].ID })
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("mods.lockのエンコードに失敗しました: %w", err)
+	}
+	return vfs.WriteFile(filepath.Join(serverDir, LockFileName), append(data, '\n'), 0644)
+}
+
+// Find returns the entry for id.
+func (l *Lock) Find(id string) (Entry, bool) {
+	for _, e := range l.Mods {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Put adds or replaces the entry with the same ID.
+func (l *Lock) Put(e Entry) {
+	for i := range l.Mods {
+		if l.Mods[i].ID == e.ID {
+			l.Mods[i] = e
+			return
+		}
+	}
+	l.Mods = append(l.Mods, e)
+}
+
+// Remove deletes the entry for id and reports whether it existed.
+func (l *Lock) Remove(id string) (Entry, bool) {
+	for i, e := range l.Mods {
+		if e.ID == id {
+			l.Mods = append(l.Mods[:i], l.Mods[i+1:]...)
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// SHA512 returns the hex-encoded SHA-512 of data.
+func SHA512(data []byte) string {
+	sum := sha512.Sum512(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// FileSHA512 returns the hex-encoded SHA-512 of the file at path.
+func FileSHA512(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha512.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Install は、リリースの jar をソースから取得して dir に書き込みます。
+// 書き込む前にハッシュを検証し、一致しない場合やハッシュがない場合はファイルを残しません。
+// 同じ名前のファイルは、書き込みが終わってから置き換えます。
+// 書き込みは vfs を通すため、記録中はディスクに書き込みません。
+func Install(src Source, r Release, dir string) error {
+	if err := checkPathElement("ファイル名", r.Filename); err != nil {
+		return err
+	}
+	if r.SHA512 == "" {
+		return fmt.Errorf("%s@%s には sha512 がないため、検証できずインストールできません", r.ID, r.Version)
+	}
+	rc, err := src.Open(r)
+	if err != nil {
+		return fmt.Errorf("%s@%s の取得に失敗しました: %w", r.ID, r.Version, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return fmt.Errorf("%s@%s の取得に失敗しました: %w", r.ID, r.Version, err)
+	}
+	if !strings.EqualFold(SHA512(data), r.SHA512) {
+		return fmt.Errorf("%s のハッシュが一致しません", r.Filename)
+	}
+
+	if err := vfs.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	target := filepath.Join(dir, r.Filename)
+	if vfs.Recording() {
+		// Commit が一時ファイルを経由して置き換える
+		return vfs.WriteFile(target, data, 0644)
+	}
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Status は、ロックファイルのエントリとディスク上の jar の状態です。
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusMissing  Status = "missing"
+	StatusModified Status = "modified"
+)
+
+// Check は mods ディレクトリ内の jar がロックファイルと一致しているかを確認します。
+func Check(modsDir string, e Entry) (Status, error) {
+	sum, err := FileSHA512(filepath.Join(modsDir, e.Filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return StatusMissing, nil
+		}
+		return "", err
+	}
+	if !strings.EqualFold(sum, e.SHA512) {
+		return StatusModified, nil
+	}
+	return StatusOK, nil
+}
+
+// Unmanaged は、mods ディレクトリにあってロックファイルに記録されていない jar を返します。
+func Unmanaged(modsDir string, lock *Lock) ([]string, error) {
+	managed := make([]string, 0, len(lock.Mods))
+	for _, e := range lock.Mods {
+		managed = append(managed, e.Filename)
+	}
+	return UnmanagedJars(modsDir, managed)
+}
+
+// UnmanagedJars は、dir にある jar のうち managed に含まれないものを返します。
+func UnmanagedJars(dir string, managed []string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	known := make(map[string]bool, len(managed))
+	for _, name := range managed {
+		known[name] = true
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasSuffix(name, ".jar") && !known[name] {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
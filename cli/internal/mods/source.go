@@ -0,0 +1,230 @@
+// Package mods は、サーバーごとの mods.lock を使って Forge/Fabric の MOD を管理します。
+package mods
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound は、ソースに該当するリリースがない場合に返されます。
+var ErrNotFound = errors.New("該当するリリースが見つかりません")
+
+// Release は、ソースから取得できる MOD の1バージョンです。
+type Release struct {
+	ID           string   `json:"id"`
+	Version      string   `json:"version"`
+	Filename     string   `json:"filename"`
+	SHA512       string   `json:"sha512"`
+	URL          string   `json:"url,omitempty"`
+	GameVersions []string `json:"gameVersions,omitempty"`
+	Loaders      []string `json:"loaders,omitempty"`
+}
+
+// Compatible は、リリースが指定した Minecraft バージョンとローダーで使えるかを返します。
+// 情報がない項目は制約なしとみなします。
+func (r Release) Compatible(mcVersion, loader string) bool {
+	return matchAny(r.GameVersions, mcVersion) && matchAny(r.Loaders, loader)
+}
+
+func matchAny(list []string, v string) bool {
+	if len(list) == 0 || v == "" {
+		return true
+	}
+	for _, item := range list {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Source は MOD の配布元です。
+// Modrinth や CurseForge の API クライアントはこのインターフェースを実装して登録します。
+type Source interface {
+	// Name は mods.lock に記録するソース名です。
+	Name() string
+	// Versions は、id のリリースを新しい順に返します。
+	Versions(id string) ([]Release, error)
+	// Open はリリースの jar を開きます。
+	Open(r Release) (io.ReadCloser, error)
+}
+
+// Resolve は、version に一致するリリース (空の場合は互換性のある最新のリリース) を返します。
+func Resolve(src Source, id, version, mcVersion, loader string) (Release, error) {
+	releases, err := src.Versions(id)
+	if err != nil {
+		return Release{}, err
+	}
+	for _, r := range releases {
+		if version != "" && r.Version != version {
+			continue
+		}
+		if version == "" && !r.Compatible(mcVersion, loader) {
+			continue
+		}
+		return r, nil
+	}
+	if version != "" {
+		return Release{}, fmt.Errorf("%s@%s: %w", id, version, ErrNotFound)
+	}
+	return Release{}, fmt.Errorf("%s (Minecraft %s, %s): %w", id, mcVersion, loader, ErrNotFound)
+}
+
+// LocalSource はローカルのキャッシュディレクトリを配布元とするソースです。
+// ネットワークなしで MOD をインストールできます。
+//
+// ディレクトリ構成:
+//
+//	<dir>/<id>/<version>/<filename>.jar
+//	<dir>/<id>/<version>/release.json   (Release のメタデータ)
+type LocalSource struct {
+	Dir string
+}
+
+// Name implements Source.
+func (s *LocalSource) Name() string {
+	return "local"
+}
+
+// Versions implements Source.
+func (s *LocalSource) Versions(id string) ([]Release, error) {
+	if err := checkPathElement("ID", id); err != nil {
+		return nil, err
+	}
+	metas, err := filepath.Glob(filepath.Join(s.Dir, id, "*", "release.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(metas) == 0 {
+		return nil, fmt.Errorf("%s: %w", id, ErrNotFound)
+	}
+
+	releases := make([]Release, 0, len(metas))
+	for _, path := range metas {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var r Release
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("%s のパースに失敗しました: %w", path, err)
+		}
+		releases = append(releases, r)
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return CompareVersions(releases[i].Version, releases[j].Version) > 0
+	})
+	return releases, nil
+}
+
+// Open implements Source.
+func (s *LocalSource) Open(r Release) (io.ReadCloser, error) {
+	if err := checkRelease(r); err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(s.Dir, r.ID, r.Version, r.Filename))
+}
+
+// checkRelease は、ID、バージョン、ファイル名がキャッシュやサーバーディレクトリの外を指さないことを確認します。
+func checkRelease(r Release) error {
+	if err := checkPathElement("ID", r.ID); err != nil {
+		return err
+	}
+	if err := checkPathElement("バージョン", r.Version); err != nil {
+		return err
+	}
+	return checkPathElement("ファイル名", r.Filename)
+}
+
+// checkPathElement は、v がパスの1要素として使えることを確認します。
+func checkPathElement(kind, v string) error {
+	if v == "" || v == "." || v == ".." || strings.ContainsAny(v, `/\`) {
+		return fmt.Errorf("不正な%sです: %q", kind, v)
+	}
+	return nil
+}
+
+// Add は jar をキャッシュに取り込み、メタデータを書き出します。
+// SHA512 はファイルの内容から計算されます。
+func (s *LocalSource) Add(jarPath string, r Release) (Release, error) {
+	data, err := os.ReadFile(jarPath)
+	if err != nil {
+		return Release{}, fmt.Errorf("jarの読み込みに失敗しました: %w", err)
+	}
+	if r.Filename == "" {
+		r.Filename = filepath.Base(jarPath)
+	}
+	if err := checkRelease(r); err != nil {
+		return Release{}, err
+	}
+	r.SHA512 = SHA512(data)
+
+	dir := filepath.Join(s.Dir, r.ID, r.Version)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Release{}, fmt.Errorf("キャッシュディレクトリの作成に失敗しました: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, r.Filename), data, 0644); err != nil {
+		return Release{}, fmt.Errorf("キャッシュへの書き込みに失敗しました: %w", err)
+	}
+	meta, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return Release{}, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "release.json"), meta, 0644); err != nil {
+		return Release{}, fmt.Errorf("メタデータの書き込みに失敗しました: %w", err)
+	}
+	return r, nil
+}
+
+// CompareVersions は "1.2.10" と "1.2.9" のようなバージョン文字列を数値として比較します。
+// 数値でない部分は文字列として比較します。
+func CompareVersions(a, b string) int {
+	split := func(v string) []string {
+		return strings.FieldsFunc(v, func(r rune) bool { return r == '.' || r == '-' || r == '+' || r == '_' })
+	}
+	pa, pb := split(a), split(b)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := parseUint(pa[i])
+		nb, errB := parseUint(pb[i])
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+		default:
+			if c := strings.Compare(pa[i], pb[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(pa) < len(pb):
+		return -1
+	case len(pa) > len(pb):
+		return 1
+	}
+	return 0
+}
+
+func parseUint(s string) (uint64, error) {
+	var n uint64
+	if s == "" {
+		return 0, errors.New("empty")
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, errors.New("not a number")
+		}
+		n = n*10 + uint64(c-'0')
+	}
+	return n, nil
+}
@@ -0,0 +1,108 @@
+package mods
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mcctl/internal/vfs"
+)
+
+func TestLocalSourceRejectsEscapingPaths(t *testing.T) {
+	src := &LocalSource{Dir: t.TempDir()}
+	jar := filepath.Join(t.TempDir(), "jei.jar")
+	os.WriteFile(jar, []byte("jei"), 0644)
+
+	for _, r := range []Release{
+		{ID: "../jei", Version: "1.0.0"},
+		{ID: "jei", Version: "../../1.0.0"},
+		{ID: "jei", Version: ".."},
+		{ID: "jei", Version: "1.0.0", Filename: `..\jei.jar`},
+	} {
+		if _, err := src.Add(jar, r); err == nil {
+			t.Errorf("Add(%+v) が受け付けられました", r)
+		}
+		if _, err := src.Open(Release{ID: r.ID, Version: r.Version, Filename: "jei.jar"}); err == nil {
+			t.Errorf("Open(%+v) が受け付けられました", r)
+		}
+	}
+	if _, err := src.Versions("../jei"); err == nil {
+		t.Error("Versions(../jei) が受け付けられました")
+	}
+}
+
+func TestInstall(t *testing.T) {
+	src := &LocalSource{Dir: t.TempDir()}
+	jar := filepath.Join(t.TempDir(), "jei-1.0.0.jar")
+	os.WriteFile(jar, []byte("jei"), 0644)
+	release, err := src.Add(jar, Release{ID: "jei", Version: "1.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := Install(src, release, dir); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "jei-1.0.0.jar")); string(data) != "jei" {
+		t.Errorf("jei-1.0.0.jar = %q", data)
+	}
+
+	unverified := release
+	unverified.SHA512 = ""
+	if err := Install(src, unverified, t.TempDir()); err == nil {
+		t.Error("sha512 のないリリースがインストールされました")
+	}
+
+	mismatch := release
+	mismatch.SHA512 = SHA512([]byte("other"))
+	empty := t.TempDir()
+	if err := Install(src, mismatch, empty); err == nil {
+		t.Error("ハッシュが一致しないリリースがインストールされました")
+	}
+	if entries, _ := os.ReadDir(empty); len(entries) != 0 {
+		t.Errorf("失敗したインストールでファイルが残っています: %v", entries)
+	}
+}
+
+// TestInstallRecording は、--dry-run で記録している間は jar も mods.lock もディスクに書き込まないことを確認します。
+func TestInstallRecording(t *testing.T) {
+	src := &LocalSource{Dir: t.TempDir()}
+	jar := filepath.Join(t.TempDir(), "jei-1.0.0.jar")
+	os.WriteFile(jar, []byte("jei"), 0644)
+	release, err := src.Add(jar, Release{ID: "jei", Version: "1.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverDir := t.TempDir()
+	modsDir := filepath.Join(serverDir, "mods")
+	vfs.Record()
+	defer vfs.Discard()
+	if err := Install(src, release, modsDir); err != nil {
+		t.Fatal(err)
+	}
+	lock := &Lock{}
+	lock.Put(Entry{ID: release.ID, Version: release.Version, Filename: release.Filename, SHA512: release.SHA512, Source: src.Name()})
+	if err := lock.Save(serverDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(modsDir); !os.IsNotExist(err) {
+		t.Errorf("記録中に mods ディレクトリが作られました: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(serverDir, LockFileName)); !os.IsNotExist(err) {
+		t.Errorf("記録中に mods.lock が書き込まれました: %v", err)
+	}
+	// 記録中の読み込みには記録した内容が見える
+	if loaded, err := LoadLock(serverDir); err != nil || len(loaded.Mods) != 1 {
+		t.Errorf("LoadLock() = %+v, %v", loaded, err)
+	}
+
+	if err := vfs.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := Check(modsDir, lock.Mods[0]); err != nil || status != StatusOK {
+		t.Errorf("Commit 後の Check() = %v, %v", status, err)
+	}
+}
@@ -0,0 +1,184 @@
+package monitoring
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"mcctl/internal/server"
+	"mcctl/internal/vfs"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultAlertThresholds は、サーバーの alerts で上書きされなかった項目に使う値です。
+var DefaultAlertThresholds = server.AlertThresholds{
+	DownFor:     "2m",
+	MinTPS:      15,
+	MemoryRatio: 0.9,
+	MaxRestarts: 3,
+}
+
+// AlertOptions は、アラートルールの生成先と Prometheus から見たパスです。
+type AlertOptions struct {
+	ServersPath string
+	// ComposeFiles は、cAdvisor のメトリクスを絞り込むコンテナ名を探す docker-compose.yml です。
+	ComposeFiles         []string
+	RulesPath            string
+	RulesGlob            string
+	PrometheusConfigPath string
+}
+
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// thresholdsFor merges the server's overrides onto the defaults.
+func thresholdsFor(s server.Server) server.AlertThresholds {
+	t := DefaultAlertThresholds
+	if s.Alerts == nil {
+		return t
+	}
+	if s.Alerts.DownFor != "" {
+		t.DownFor = s.Alerts.DownFor
+	}
+	if s.Alerts.MinTPS != 0 {
+		t.MinTPS = s.Alerts.MinTPS
+	}
+	if s.Alerts.MemoryRatio != 0 {
+		t.MemoryRatio = s.Alerts.MemoryRatio
+	}
+	if s.Alerts.MaxRestarts != 0 {
+		t.MaxRestarts = s.Alerts.MaxRestarts
+	}
+	return t
+}
+
+// serverRules はサーバー1台分のアラートルールを返します。
+// container は cAdvisor のメトリクスの name ラベルになる、サーバーのコンテナ名です。
+func serverRules(s server.Server, container string) []rule {
+	t := thresholdsFor(s)
+	labels := func(severity string) map[string]string {
+		return map[string]string{"severity": severity, "server": s.Name}
+	}
+	return []rule{
+		{
+			Alert:  "MinecraftServerDown",
+			Expr:   fmt.Sprintf(`mcctl_server_up{name=%q} == 0`, s.Name),
+			For:    t.DownFor,
+			Labels: labels("critical"),
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("%s が応答しません", s.Name),
+			},
+		},
+		{
+			Alert:  "MinecraftLowTPS",
+			Expr:   fmt.Sprintf(`mcctl_server_tps{name=%q,window="1m"} < %g`, s.Name, t.MinTPS),
+			For:    "5m",
+			Labels: labels("warning"),
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("%s のTPSが %g を下回っています", s.Name, t.MinTPS),
+			},
+		},
+		{
+			Alert: "MinecraftMemoryNearLimit",
+			Expr: fmt.Sprintf(`container_memory_working_set_bytes{name=%q} / (container_spec_memory_limit_bytes{name=%q} > 0) > %g`,
+				container, container, t.MemoryRatio),
+			For:    "5m",
+			Labels: labels("warning"),
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("%s のメモリ使用量がコンテナ上限の %g%% を超えています", s.Name, t.MemoryRatio*100),
+			},
+		},
+		{
+			Alert:  "MinecraftCrashLoop",
+			Expr:   fmt.Sprintf(`changes(container_start_time_seconds{name=%q}[15m]) > %d`, container, t.MaxRestarts),
+			Labels: labels("critical"),
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("%s が15分間に %d 回を超えて再起動しています", s.Name, t.MaxRestarts),
+			},
+		},
+	}
+}
+
+// RenderAlertRules は servers からルールファイルの内容を生成します。
+// コンテナ名は composeFiles のサービスの定義から求めます。
+func RenderAlertRules(servers []server.Server, composeFiles []string) ([]byte, error) {
+	sorted := append([]server.Server(nil), servers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	file := ruleFile{Groups: []ruleGroup{}}
+	for _, s := range sorted {
+		file.Groups = append(file.Groups, ruleGroup{
+			Name:  JobName(s.Name),
+			Rules: serverRules(s, server.ServiceContainerName(composeFiles, s.Name)),
+		})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# mcctl alerts generate で生成されたファイルです。直接編集しないでください。\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(file); err != nil {
+		return nil, fmt.Errorf("アラートルールのエンコードに失敗しました: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GenerateAlerts は、ルールファイルを書き出し、prometheus/config.yml の rule_files に登録します。
+func GenerateAlerts(opts AlertOptions) error {
+	servers, err := server.LoadServers(opts.ServersPath)
+	if err != nil {
+		return err
+	}
+	data, err := RenderAlertRules(servers, opts.ComposeFiles)
+	if err != nil {
+		return err
+	}
+
+	if err := vfs.MkdirAll(filepath.Dir(opts.RulesPath), 0755); err != nil {
+		return fmt.Errorf("ルールディレクトリの作成に失敗しました: %w", err)
+	}
+	if err := vfs.WriteFile(opts.RulesPath, data, 0644); err != nil {
+		return fmt.Errorf("ルールファイルの書き込みに失敗しました: %w", err)
+	}
+	return ensureRuleFiles(opts)
+}
+
+func ensureRuleFiles(opts AlertOptions) error {
+	doc, err := readYAML(opts.PrometheusConfigPath)
+	if err != nil {
+		return err
+	}
+	root := documentRoot(doc)
+
+	files := mappingValue(root, "rule_files")
+	if files == nil {
+		files = &yaml.Node{Kind: yaml.SequenceNode}
+		setMappingValue(root, "rule_files", files)
+	}
+	for _, f := range files.Content {
+		if f.Value == opts.RulesGlob {
+			return nil
+		}
+	}
+	files.Content = append(files.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: opts.RulesGlob})
+	return writeYAML(opts.PrometheusConfigPath, doc)
+}
@@ -0,0 +1,113 @@
+package monitoring
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"mcctl/internal/server"
+)
+
+var update = flag.Bool("update", false, "testdata の .golden ファイルを更新する")
+
+var (
+	survival = server.Server{Name: "survival", Type: "paper"}
+	creative = server.Server{Name: "creative", Type: "fabric", Alerts: &server.AlertThresholds{
+		DownFor:     "10m",
+		MinTPS:      18,
+		MemoryRatio: 0.8,
+		MaxRestarts: 5,
+	}}
+)
+
+func TestRenderAlertRulesGolden(t *testing.T) {
+	tests := []struct {
+		golden  string
+		servers []server.Server
+	}{
+		{"empty.golden", nil},
+		{"defaults.golden", []server.Server{survival}},
+		{"overrides.golden", []server.Server{survival, creative}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			got, err := RenderAlertRules(tt.servers, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("RenderAlertRules() と %s が異なります (go test -update で更新できます)\n--- got\n%s\n--- want\n%s", path, got, want)
+			}
+		})
+	}
+}
+
+func TestAlertRulesContainerName(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "My Project")
+	os.MkdirAll(dir, 0755)
+	compose := filepath.Join(dir, "docker-compose.yml")
+	os.WriteFile(compose, []byte(`services:
+  lobby:
+    image: itzg/minecraft-server
+    container_name: hub
+  survival:
+    image: itzg/minecraft-server
+`), 0644)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"lobby", `container_memory_working_set_bytes{name="hub"}`},
+		{"survival", `container_memory_working_set_bytes{name="myproject-survival-1"}`},
+		{"creative", `container_memory_working_set_bytes{name="minecraft-creative-server"}`},
+	}
+	for _, tt := range tests {
+		got, err := RenderAlertRules([]server.Server{{Name: tt.name}}, []string{compose})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(got, []byte(tt.want)) {
+			t.Errorf("%s のルールに %s がありません:\n%s", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestAlertRulesPromtool は、生成したルールを testdata/alerts_test.yml の時系列で promtool test rules に評価させます。
+// promtool がない環境ではスキップします。
+func TestAlertRulesPromtool(t *testing.T) {
+	promtool, err := exec.LookPath("promtool")
+	if err != nil {
+		t.Skip("promtool が見つかりません")
+	}
+	rules, err := RenderAlertRules([]server.Server{survival, creative}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := os.ReadFile(filepath.Join("testdata", "alerts_test.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "rules.yml"), rules, 0644)
+	os.WriteFile(filepath.Join(dir, "alerts_test.yml"), fixture, 0644)
+
+	cmd := exec.Command(promtool, "test", "rules", "alerts_test.yml")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("promtool test rules: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,256 @@
+// Package monitoring は、サーバーの追加・削除・名前変更に合わせて
+// Prometheus のスクレイプ対象と mc-monitor の監視対象を更新します。
+package monitoring
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mcctl/internal/server"
+	"mcctl/internal/vfs"
+
+	"gopkg.in/yaml.v3"
+)
+
+// JobName はサーバーごとのスクレイプ対象に付ける job ラベルです。
+// Grafana のパネルはこのラベルでサーバーを絞り込めます。
+func JobName(serverName string) string {
+	return "minecraft-" + serverName
+}
+
+// scrapeJobName は file_sd のターゲットを読み込むスクレイプジョブの名前です。
+const scrapeJobName = "mcctl"
+
+// Options は、同期するファイルのパスと mcctl exporter のアドレスです。
+type Options struct {
+	ServersPath string
+	// TargetsPath は file_sd 用のJSONファイルです (例: prometheus/targets/minecraft.json)。
+	TargetsPath string
+	// TargetsGlob は、Prometheus コンテナ内から見た file_sd のパターンです。
+	TargetsGlob string
+	// PrometheusConfigPath は prometheus/config.yml です。
+	PrometheusConfigPath string
+	// ComposePath は mc-monitor が定義されているルートの docker-compose.yml です。
+	ComposePath string
+	// ExporterAddress は Prometheus から見た `mcctl exporter` のアドレスです。
+	ExporterAddress string
+}
+
+// targetGroup は file_sd の1エントリです。
+type targetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// Sync は servers.json をもとに、file_sd のターゲットファイル・スクレイプ設定・
+// mc-monitor の EXPORT_SERVERS を再生成します。
+func Sync(opts Options) error {
+	servers, err := server.LoadServers(opts.ServersPath)
+	if err != nil {
+		return err
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Name < servers[j].Name })
+
+	if err := writeTargets(opts, servers); err != nil {
+		return err
+	}
+	if err := ensureScrapeJob(opts); err != nil {
+		return err
+	}
+	return updateExportServers(opts, servers)
+}
+
+func writeTargets(opts Options, servers []server.Server) error {
+	groups := make([]targetGroup, 0, len(servers))
+	for _, s := range servers {
+		groups = append(groups, targetGroup{
+			Targets: []string{opts.ExporterAddress},
+			Labels: map[string]string{
+				"job":    JobName(s.Name),
+				"server": s.Name,
+				// exporter のメトリクスにも type ラベルがあるため、同じ名前にすると exported_type に変えられてしまう
+				"server_type": strings.ToLower(s.Type),
+			},
+		})
+	}
+
+	data, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ターゲットファイルのエンコードに失敗しました: %w", err)
+	}
+	if err := vfs.MkdirAll(filepath.Dir(opts.TargetsPath), 0755); err != nil {
+		return fmt.Errorf("ターゲットディレクトリの作成に失敗しました: %w", err)
+	}
+	if err := vfs.WriteFile(opts.TargetsPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("ターゲットファイルの書き込みに失敗しました: %w", err)
+	}
+	return nil
+}
+
+// ensureScrapeJob は、file_sd を読み込むスクレイプジョブが prometheus/config.yml に
+// なければ追加します。既存のジョブやコメントはそのまま残します。
+func ensureScrapeJob(opts Options) error {
+	doc, err := readYAML(opts.PrometheusConfigPath)
+	if err != nil {
+		return err
+	}
+	root := documentRoot(doc)
+
+	jobs := mappingValue(root, "scrape_configs")
+	if jobs == nil {
+		jobs = &yaml.Node{Kind: yaml.SequenceNode}
+		setMappingValue(root, "scrape_configs", jobs)
+	}
+	for _, job := range jobs.Content {
+		if name := mappingValue(job, "job_name"); name != nil && name.Value == scrapeJobName {
+			return nil
+		}
+	}
+
+	var job yaml.Node
+	spec := fmt.Sprintf(`job_name: %s
+metrics_path: /metrics
+file_sd_configs:
+  - files:
+      - %s
+relabel_configs:
+  - source_labels: [server]
+    target_label: __param_server
+`, scrapeJobName, opts.TargetsGlob)
+	if err := yaml.Unmarshal([]byte(spec), &job); err != nil {
+		return err
+	}
+	jobs.Content = append(jobs.Content, job.Content[0])
+
+	return writeYAML(opts.PrometheusConfigPath, doc)
+}
+
+// updateExportServers は、mc-monitor サービスの EXPORT_SERVERS を
+// 管理しているサーバーのアドレス一覧に置き換えます。
+// サーバーがない場合は、存在しないサーバーを監視しないよう EXPORT_SERVERS を削除します
+// (mc-monitor は監視対象がないと起動しません)。
+func updateExportServers(opts Options, servers []server.Server) error {
+	addresses := make([]string, 0, len(servers))
+	for _, s := range servers {
+		address, err := server.GameAddress(opts.ServersPath, s.Name)
+		if err != nil {
+			return err
+		}
+		addresses = append(addresses, address)
+	}
+	value := strings.Join(addresses, ",")
+
+	data, err := vfs.ReadFile(opts.ComposePath)
+	if err != nil {
+		return fmt.Errorf("%s の読み込みに失敗しました: %w", opts.ComposePath, err)
+	}
+	doc, err := readYAML(opts.ComposePath)
+	if err != nil {
+		return err
+	}
+	monitor := mappingValue(mappingValue(documentRoot(doc), "services"), "monitor")
+	if monitor == nil {
+		return nil
+	}
+	env := mappingValue(monitor, "environment")
+	current := mappingValue(env, "EXPORT_SERVERS")
+	if len(servers) == 0 {
+		if current == nil || current.Kind != yaml.ScalarNode {
+			return nil
+		}
+		// 書き換えと同じく、手で整形されたファイルを再エンコードせずに EXPORT_SERVERS の行だけを削除する
+		lines := strings.Split(string(data), "\n")
+		lines = append(lines[:current.Line-1], lines[current.Line:]...)
+		if err := vfs.WriteFile(opts.ComposePath, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+			return fmt.Errorf("%s の書き込みに失敗しました: %w", opts.ComposePath, err)
+		}
+		return nil
+	}
+	if current == nil || current.Kind != yaml.ScalarNode {
+		if env == nil || env.Kind != yaml.MappingNode {
+			env = &yaml.Node{Kind: yaml.MappingNode}
+			setMappingValue(monitor, "environment", env)
+		}
+		setMappingValue(env, "EXPORT_SERVERS", &yaml.Node{Kind: yaml.ScalarNode, Value: value})
+		return writeYAML(opts.ComposePath, doc)
+	}
+	if current.Value == value {
+		return nil
+	}
+
+	// ルートの docker-compose.yml は手で整形されているため、再エンコードせずに
+	// EXPORT_SERVERS の値がある行だけを書き換える
+	lines := strings.Split(string(data), "\n")
+	line := lines[current.Line-1]
+	lines[current.Line-1] = line[:current.Column-1] + value
+	if err := vfs.WriteFile(opts.ComposePath, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return fmt.Errorf("%s の書き込みに失敗しました: %w", opts.ComposePath, err)
+	}
+	return nil
+}
+
+func readYAML(path string) (*yaml.Node, error) {
+	data, err := vfs.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s の読み込みに失敗しました: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s のパースに失敗しました: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	return &doc, nil
+}
+
+func writeYAML(path string, doc *yaml.Node) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("%s のエンコードに失敗しました: %w", path, err)
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if err := vfs.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("%s の書き込みに失敗しました: %w", path, err)
+	}
+	return nil
+}
+
+func documentRoot(doc *yaml.Node) *yaml.Node {
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		return doc.Content[0]
+	}
+	return doc
+}
+
+// mappingValue returns the value for key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets key to value in a mapping node, keeping the key's position if it exists.
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+}
@@ -0,0 +1,43 @@
+package mrpack
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// DefaultFetcher は http(s) と file の URL からファイルを取得します。
+// file の URL は、パックのファイルをローカルディスクから配る場合に使います。
+type DefaultFetcher struct {
+	Client *http.Client
+}
+
+// Fetch implements Fetcher.
+func (f *DefaultFetcher) Fetch(rawURL string) (io.ReadCloser, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("不正なURLです: %s", rawURL)
+	}
+	switch u.Scheme {
+	case "file":
+		return os.Open(u.Path)
+	case "http", "https":
+		client := f.Client
+		if client == nil {
+			client = &http.Client{Timeout: 5 * time.Minute}
+		}
+		resp, err := client.Get(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %s", rawURL, resp.Status)
+		}
+		return resp.Body, nil
+	}
+	return nil, fmt.Errorf("未対応のURLです: %s", rawURL)
+}
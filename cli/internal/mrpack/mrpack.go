@@ -0,0 +1,244 @@
+// Package mrpack は Modrinth のモッドパック (.mrpack) を読み込み、サーバー側のファイルを展開します。
+//
+// 形式は https://support.modrinth.com/en/articles/8802351-modrinth-modpack-format-mrpack を参照してください。
+package mrpack
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mcctl/internal/packfs"
+)
+
+// IndexFileName は .mrpack 内のインデックスファイルの名前です。
+const IndexFileName = "modrinth.index.json"
+
+// Override directories, applied in this order.
+const (
+	OverridesDir       = "overrides"
+	ServerOverridesDir = "server-overrides"
+)
+
+// Index は modrinth.index.json の内容です。
+type Index struct {
+	FormatVersion int               `json:"formatVersion"`
+	Game          string            `json:"game"`
+	VersionID     string            `json:"versionId"`
+	Name          string            `json:"name"`
+	Summary       string            `json:"summary,omitempty"`
+	Files         []File            `json:"files"`
+	Dependencies  map[string]string `json:"dependencies"`
+}
+
+// File は、パックに含まれるダウンロード対象のファイルです。
+type File struct {
+	Path      string            `json:"path"`
+	Hashes    map[string]string `json:"hashes"`
+	Env       *Env              `json:"env,omitempty"`
+	Downloads []string          `json:"downloads"`
+	FileSize  int64             `json:"fileSize"`
+}
+
+// Env は、クライアントとサーバーでファイルが必要かどうかです。
+// 値は "required", "optional", "unsupported" のいずれかです。
+type Env struct {
+	Client string `json:"client"`
+	Server string `json:"server"`
+}
+
+// ForServer reports whether the file should be installed on a server.
+// env がない場合は両方で必要なファイルとして扱います。
+func (f File) ForServer() bool {
+	return f.Env == nil || f.Env.Server != "unsupported"
+}
+
+// Pack は開いた .mrpack です。
+type Pack struct {
+	Index Index
+	zr    *zip.ReadCloser
+}
+
+// Open は .mrpack を開いてインデックスを読み込みます。
+func Open(packPath string) (*Pack, error) {
+	zr, err := zip.OpenReader(packPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s を開けませんでした: %w", packPath, err)
+	}
+	f, err := zr.Open(IndexFileName)
+	if err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("%s に %s がありません", packPath, IndexFileName)
+	}
+	defer f.Close()
+
+	var index Index
+	if err := json.NewDecoder(f).Decode(&index); err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("%s のパースに失敗しました: %w", IndexFileName, err)
+	}
+	if index.Game != "minecraft" {
+		zr.Close()
+		return nil, fmt.Errorf("未対応のゲームです: %s", index.Game)
+	}
+	if index.FormatVersion != 1 {
+		zr.Close()
+		return nil, fmt.Errorf("未対応の formatVersion です: %d", index.FormatVersion)
+	}
+	return &Pack{Index: index, zr: zr}, nil
+}
+
+// Close closes the underlying archive.
+func (p *Pack) Close() error {
+	return p.zr.Close()
+}
+
+// MinecraftVersion returns the Minecraft version the pack depends on.
+func (p *Pack) MinecraftVersion() string {
+	return p.Index.Dependencies["minecraft"]
+}
+
+// loaders は dependencies のキーと mcctl のサーバータイプ、
+// itzg/minecraft-server でローダーのバージョンを指定する環境変数の対応です。
+var loaders = []struct {
+	dependency string
+	serverType string
+	env        string
+}{
+	{"forge", "forge", "FORGE_VERSION"},
+	{"fabric-loader", "fabric", "FABRIC_LOADER_VERSION"},
+}
+
+// Runtime は、パックを動かすサーバータイプと docker-compose の環境変数を返します。
+func (p *Pack) Runtime() (string, []string, error) {
+	mc := p.MinecraftVersion()
+	if mc == "" {
+		return "", nil, fmt.Errorf("dependencies に minecraft がありません")
+	}
+	for _, l := range loaders {
+		if v, ok := p.Index.Dependencies[l.dependency]; ok {
+			return l.serverType, []string{"VERSION=" + mc, l.env + "=" + v}, nil
+		}
+	}
+	for dep := range p.Index.Dependencies {
+		if dep != "minecraft" {
+			return "", nil, fmt.Errorf("未対応のローダーです: %s", dep)
+		}
+	}
+	return "vanilla", []string{"VERSION=" + mc}, nil
+}
+
+// Fetcher は downloads の URL からファイルを取得します。
+type Fetcher interface {
+	Fetch(url string) (io.ReadCloser, error)
+}
+
+// Installed は、展開したダウンロード対象のファイルです。
+type Installed struct {
+	Path   string
+	SHA512 string
+	URL    string
+}
+
+// Result は Install の結果です。
+type Result struct {
+	Files []Installed
+	// Skipped は env.server が unsupported のため展開しなかったファイルです。
+	Skipped []string
+	// Overrides は overrides と server-overrides から展開したファイルです。
+	Overrides []string
+}
+
+// Install は、サーバー側のファイルを取得してハッシュを検証し、dst に展開します。
+// その後 overrides、server-overrides の順に上書きします。
+func (p *Pack) Install(dst string, fetcher Fetcher) (Result, error) {
+	var result Result
+	for _, f := range p.Index.Files {
+		if !f.ForServer() {
+			result.Skipped = append(result.Skipped, f.Path)
+			continue
+		}
+		target, err := packfs.SafeJoin(dst, f.Path)
+		if err != nil {
+			return result, err
+		}
+		url, err := p.download(f, target, fetcher)
+		if err != nil {
+			return result, err
+		}
+		result.Files = append(result.Files, Installed{Path: f.Path, SHA512: f.Hashes["sha512"], URL: url})
+	}
+
+	for _, dir := range []string{OverridesDir, ServerOverridesDir} {
+		files, err := packfs.ExtractDir(&p.zr.Reader, dir, dst)
+		if err != nil {
+			return result, err
+		}
+		result.Overrides = append(result.Overrides, files...)
+	}
+	return result, nil
+}
+
+// download は downloads の URL を順に試し、ハッシュが一致したものを target に書き込みます。
+func (p *Pack) download(f File, target string, fetcher Fetcher) (string, error) {
+	if len(f.Downloads) == 0 {
+		return "", fmt.Errorf("%s に downloads がありません", f.Path)
+	}
+	if f.Hashes["sha1"] == "" && f.Hashes["sha512"] == "" {
+		return "", fmt.Errorf("%s にハッシュがありません", f.Path)
+	}
+
+	var lastErr error
+	for _, url := range f.Downloads {
+		data, err := fetch(fetcher, url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err := verify(f, data); err != nil {
+			lastErr = err
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return "", fmt.Errorf("%s の書き込みに失敗しました: %w", f.Path, err)
+		}
+		return url, nil
+	}
+	return "", fmt.Errorf("%s を取得できませんでした: %w", f.Path, lastErr)
+}
+
+func fetch(fetcher Fetcher, url string) ([]byte, error) {
+	rc, err := fetcher.Fetch(url)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// verify checks the sha1 and sha512 hashes listed for f.
+func verify(f File, data []byte) error {
+	if want := f.Hashes["sha1"]; want != "" {
+		sum := sha1.Sum(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), want) {
+			return fmt.Errorf("%s の sha1 が一致しません", f.Path)
+		}
+	}
+	if want := f.Hashes["sha512"]; want != "" {
+		sum := sha512.Sum512(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), want) {
+			return fmt.Errorf("%s の sha512 が一致しません", f.Path)
+		}
+	}
+	return nil
+}
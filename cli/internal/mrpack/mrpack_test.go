@@ -0,0 +1,164 @@
+package mrpack
+
+import (
+	"archive/zip"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// buildPack は testdata/<name> を .mrpack にまとめ、開いて返します。
+// modrinth.index.json の {{base}} は、testdata/downloads を配る httptest.Server の URL に置き換えます。
+func buildPack(t *testing.T, name string) *Pack {
+	t.Helper()
+	srv := httptest.NewServer(http.FileServer(http.Dir(filepath.Join("testdata", "downloads"))))
+	t.Cleanup(srv.Close)
+
+	root := filepath.Join("testdata", name)
+	var entries []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			entries = append(entries, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// server-overrides がアーカイブの中で overrides より前にあっても、overrides の後に展開されることを確かめる
+	sort.Sort(sort.Reverse(sort.StringSlice(entries)))
+
+	packPath := filepath.Join(t.TempDir(), name+".mrpack")
+	out, err := os.Create(packPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(out)
+	for _, path := range entries {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, _ := filepath.Rel(root, path)
+		if rel == IndexFileName {
+			data = []byte(strings.ReplaceAll(string(data), "{{base}}", srv.URL))
+		}
+		w, err := zw.Create(filepath.ToSlash(rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	p, err := Open(packPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestRuntime(t *testing.T) {
+	serverType, env, err := buildPack(t, "basic").Runtime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serverType != "fabric" || !reflect.DeepEqual(env, []string{"VERSION=1.20.1", "FABRIC_LOADER_VERSION=0.15.0"}) {
+		t.Errorf("Runtime() = %s, %v", serverType, env)
+	}
+
+	tests := []struct {
+		dependencies map[string]string
+		serverType   string
+		env          []string
+		wantErr      bool
+	}{
+		{map[string]string{"minecraft": "1.20.1", "forge": "47.2.0"}, "forge", []string{"VERSION=1.20.1", "FORGE_VERSION=47.2.0"}, false},
+		{map[string]string{"minecraft": "1.20.1"}, "vanilla", []string{"VERSION=1.20.1"}, false},
+		{map[string]string{"minecraft": "1.20.1", "quilt-loader": "0.20.0"}, "", nil, true},
+		{map[string]string{"fabric-loader": "0.15.0"}, "", nil, true},
+	}
+	for _, tt := range tests {
+		p := &Pack{Index: Index{Dependencies: tt.dependencies}}
+		serverType, env, err := p.Runtime()
+		if (err != nil) != tt.wantErr || serverType != tt.serverType || !reflect.DeepEqual(env, tt.env) {
+			t.Errorf("Runtime(%v) = %q, %v, %v", tt.dependencies, serverType, env, err)
+		}
+	}
+}
+
+func TestInstall(t *testing.T) {
+	p := buildPack(t, "basic")
+	dst := t.TempDir()
+	result, err := p.Install(dst, &DefaultFetcher{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result.Skipped, []string{"mods/sodium.jar"}) {
+		t.Errorf("Skipped = %v, want [mods/sodium.jar]", result.Skipped)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "mods", "sodium.jar")); !os.IsNotExist(err) {
+		t.Errorf("env.server が unsupported の mods/sodium.jar が展開されています")
+	}
+
+	if len(result.Files) != 2 {
+		t.Fatalf("Files = %+v, want 2 files", result.Files)
+	}
+	// ferritecore の最初の URL はハッシュが一致しないため、2番目の URL から取得する
+	if got := result.Files[1]; got.Path != "mods/ferritecore.jar" || !strings.HasSuffix(got.URL, "/mods/ferritecore.jar") {
+		t.Errorf("Files[1] = %+v", got)
+	}
+	for path, want := range map[string]string{
+		"mods/lithium.jar":     "lithium\n",
+		"mods/ferritecore.jar": "ferritecore\n",
+		"config/shared.toml":   "# server-overrides\nmode = \"server\"\n",
+		"config/common.txt":    "only in overrides\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(dst, path))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", path, data, err, want)
+		}
+	}
+	if want := []string{"config/common.txt", "config/shared.toml", "config/shared.toml"}; !reflect.DeepEqual(sorted(result.Overrides), want) {
+		t.Errorf("Overrides = %v, want %v", result.Overrides, want)
+	}
+}
+
+func TestInstallRejectsHashMismatch(t *testing.T) {
+	p := buildPack(t, "basic")
+	p.Index.Files[0].Hashes["sha512"] = strings.Repeat("0", 128)
+
+	dst := t.TempDir()
+	_, err := p.Install(dst, &DefaultFetcher{})
+	if err == nil || !strings.Contains(err.Error(), "sha512 が一致しません") {
+		t.Fatalf("Install() error = %v, want sha512 mismatch", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "mods", "lithium.jar")); !os.IsNotExist(err) {
+		t.Errorf("ハッシュが一致しない mods/lithium.jar が書き込まれています")
+	}
+}
+
+func TestInstallRejectsEscapingPath(t *testing.T) {
+	p := buildPack(t, "basic")
+	p.Index.Files[0].Path = "../outside.jar"
+	if _, err := p.Install(t.TempDir(), &DefaultFetcher{}); err == nil {
+		t.Fatal("Install() で展開先の外を指す path が受け付けられました")
+	}
+}
+
+func sorted(s []string) []string {
+	s = append([]string(nil), s...)
+	sort.Strings(s)
+	return s
+}
@@ -0,0 +1,61 @@
+// Package packfs は、モッドパックのアーカイブをサーバーディレクトリに展開するための共通処理です。
+package packfs
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SafeJoin joins the archive-relative path rel to dst, rejecting paths that escape dst.
+func SafeJoin(dst, rel string) (string, error) {
+	clean := path.Clean(strings.ReplaceAll(rel, "\\", "/"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || filepath.VolumeName(clean) != "" {
+		return "", fmt.Errorf("不正なパスです: %s", rel)
+	}
+	return filepath.Join(dst, filepath.FromSlash(clean)), nil
+}
+
+// ExtractDir は、アーカイブ内の prefix ディレクトリの中身を dst に書き出し、展開したパスを返します。
+func ExtractDir(zr *zip.Reader, prefix, dst string) ([]string, error) {
+	var files []string
+	for _, zf := range zr.File {
+		rel, ok := strings.CutPrefix(zf.Name, prefix+"/")
+		if !ok || rel == "" || zf.FileInfo().IsDir() {
+			continue
+		}
+		target, err := SafeJoin(dst, rel)
+		if err != nil {
+			return files, err
+		}
+		if err := extractFile(zf, target); err != nil {
+			return files, fmt.Errorf("%s の展開に失敗しました: %w", zf.Name, err)
+		}
+		files = append(files, rel)
+	}
+	return files, nil
+}
+
+func extractFile(zf *zip.File, target string) error {
+	rc, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
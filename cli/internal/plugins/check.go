@@ -0,0 +1,91 @@
+package plugins
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"mcctl/internal/mods"
+)
+
+// Severity は依存関係チェックで見つかった問題の重大度です。
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Problem は依存関係チェックで見つかった問題です。
+type Problem struct {
+	Plugin   string
+	Severity Severity
+	Message  string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Plugin, p.Message)
+}
+
+// CheckAPIVersion は、api-version がサーバーの Minecraft バージョンで使えるかを返します。
+// api-version は "1.20" のように短いことが多いため、同じ桁数に揃えて比較します。
+func CheckAPIVersion(apiVersion, mcVersion string) bool {
+	if apiVersion == "" || mcVersion == "" {
+		return true
+	}
+	parts := strings.Split(mcVersion, ".")
+	if n := len(strings.Split(apiVersion, ".")); n < len(parts) {
+		parts = parts[:n]
+	}
+	return mods.CompareVersions(apiVersion, strings.Join(parts, ".")) <= 0
+}
+
+// Check は、プラグインの集合について depend, softdepend, api-version を確認します。
+// depend が欠けている場合と api-version が新しすぎる場合はエラー、softdepend が欠けている場合は警告です。
+func Check(descriptors []Descriptor, mcVersion string) []Problem {
+	present := make(map[string]bool, len(descriptors))
+	for _, d := range descriptors {
+		present[strings.ToLower(d.Name)] = true
+	}
+
+	var problems []Problem
+	for _, d := range descriptors {
+		if !CheckAPIVersion(d.APIVersion, mcVersion) {
+			problems = append(problems, Problem{
+				Plugin:   d.Name,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("api-version %s はサーバーの Minecraft %s より新しいです", d.APIVersion, mcVersion),
+			})
+		}
+		for _, dep := range d.Depend {
+			if !present[strings.ToLower(dep)] {
+				problems = append(problems, Problem{
+					Plugin:   d.Name,
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("必須の依存プラグイン %s がありません", dep),
+				})
+			}
+		}
+		for _, dep := range d.SoftDepend {
+			if !present[strings.ToLower(dep)] {
+				problems = append(problems, Problem{
+					Plugin:   d.Name,
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("任意の依存プラグイン %s がありません", dep),
+				})
+			}
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Plugin < problems[j].Plugin })
+	return problems
+}
+
+// HasErrors reports whether any problem is an error.
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package plugins
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// ConfigTemplateName は、プラグインごとの設定テンプレートのファイル名です。
+// テンプレートは <templateDir>/<Plugin>/config.yml.tmpl に置きます。
+const ConfigTemplateName = "config.yml.tmpl"
+
+// ConfigData は設定テンプレートに渡す値です。
+type ConfigData struct {
+	Server           string
+	Address          string
+	MinecraftVersion string
+	Plugin           string
+	PluginVersion    string
+}
+
+// HasConfigTemplate reports whether templateDir has a config template for plugin.
+func HasConfigTemplate(templateDir, plugin string) bool {
+	_, err := os.Stat(filepath.Join(templateDir, plugin, ConfigTemplateName))
+	return err == nil
+}
+
+// RenderConfig は、プラグインの設定テンプレートを plugins/<Plugin>/config.yml に書き出します。
+// テンプレートがない場合は何もせず false を返します。
+// overwrite が false の場合、既存の config.yml は変更しません。
+func RenderConfig(templateDir, pluginsDir string, data ConfigData, overwrite bool) (bool, error) {
+	src := filepath.Join(templateDir, data.Plugin, ConfigTemplateName)
+	text, err := os.ReadFile(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("設定テンプレートの読み込みに失敗しました: %w", err)
+	}
+
+	dst := filepath.Join(pluginsDir, data.Plugin, "config.yml")
+	if !overwrite {
+		if _, err := os.Stat(dst); err == nil {
+			return false, nil
+		}
+	}
+
+	tmpl, err := template.New(data.Plugin).Parse(string(text))
+	if err != nil {
+		return false, fmt.Errorf("%s のパースに失敗しました: %w", src, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return false, fmt.Errorf("%s の展開に失敗しました: %w", src, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(dst, buf.Bytes(), 0644); err != nil {
+		return false, fmt.Errorf("%s の書き込みに失敗しました: %w", dst, err)
+	}
+	return true, nil
+}
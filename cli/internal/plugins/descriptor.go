@@ -0,0 +1,89 @@
+package plugins
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Descriptor は jar に含まれる plugin.yml のうち、依存関係の確認に使う項目です。
+type Descriptor struct {
+	Name       string   `yaml:"name"`
+	Version    string   `yaml:"version"`
+	Main       string   `yaml:"main"`
+	APIVersion string   `yaml:"api-version"`
+	Depend     []string `yaml:"depend"`
+	SoftDepend []string `yaml:"softdepend"`
+	LoadBefore []string `yaml:"loadbefore"`
+}
+
+// descriptorFiles は jar 内で探すファイルの順序です。
+// paper-plugin.yml の依存関係は形式が異なるため、name と api-version だけが使われます。
+var descriptorFiles = []string{"plugin.yml", "paper-plugin.yml"}
+
+// ReadDescriptor は jar から plugin.yml を読み込みます。
+func ReadDescriptor(jarPath string) (Descriptor, error) {
+	zr, err := zip.OpenReader(jarPath)
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("%s を開けませんでした: %w", filepath.Base(jarPath), err)
+	}
+	defer zr.Close()
+
+	for _, name := range descriptorFiles {
+		f, err := zr.Open(name)
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return Descriptor{}, err
+		}
+		return ParseDescriptor(data)
+	}
+	return Descriptor{}, fmt.Errorf("%s に plugin.yml がありません", filepath.Base(jarPath))
+}
+
+// ParseDescriptor は plugin.yml の内容をパースします。
+func ParseDescriptor(data []byte) (Descriptor, error) {
+	var d Descriptor
+	if err := yaml.Unmarshal(data, &d); err != nil {
+		return Descriptor{}, fmt.Errorf("plugin.ymlのパースに失敗しました: %w", err)
+	}
+	if d.Name == "" {
+		return Descriptor{}, errors.New("plugin.yml に name がありません")
+	}
+	return d, nil
+}
+
+// ReadDir は plugins ディレクトリ内のすべての jar の plugin.yml を、ファイル名をキーにして返します。
+// 読み込めなかった jar はエラーとして別に返します。
+func ReadDir(pluginsDir string) (map[string]Descriptor, map[string]error, error) {
+	entries, err := os.ReadDir(pluginsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	descriptors := make(map[string]Descriptor)
+	failed := make(map[string]error)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jar") {
+			continue
+		}
+		d, err := ReadDescriptor(filepath.Join(pluginsDir, entry.Name()))
+		if err != nil {
+			failed[entry.Name()] = err
+			continue
+		}
+		descriptors[entry.Name()] = d
+	}
+	return descriptors, failed, nil
+}
@@ -0,0 +1,96 @@
+// Package plugins は、サーバーごとの plugins.lock を使って Paper のプラグインを管理します。
+// jar の取得とハッシュ検証は mods パッケージのソースをそのまま使います。
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"mcctl/internal/mods"
+)
+
+// LockFileName はサーバーディレクトリに置くロックファイルの名前です。
+const LockFileName = "plugins.lock"
+
+// Entry は、ロックファイルに記録された1つのプラグインです。
+// Plugin は plugin.yml の name で、依存関係の解決と設定ディレクトリの名前に使います。
+type Entry struct {
+	mods.Entry
+	Plugin string `json:"plugin"`
+}
+
+// Lock は plugins.lock の内容です。
+type Lock struct {
+	Plugins []Entry `json:"plugins"`
+}
+
+// LoadLock はサーバーディレクトリの plugins.lock を読み込みます。
+// ファイルが存在しない場合は空のロックを返します。
+func LoadLock(serverDir string) (*Lock, error) {
+	lock := &Lock{}
+	data, err := os.ReadFile(filepath.Join(serverDir, LockFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return lock, nil
+		}
+		return nil, fmt.Errorf("plugins.lockの読み込みに失敗しました: %w", err)
+	}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("plugins.lockのパースに失敗しました: %w", err)
+	}
+	return lock, nil
+}
+
+// Save は plugins.lock を ID 順に並べて書き出します。
+func (l *Lock) Save(serverDir string) error {
+	sort.Slice(l.Plugins, func(i, j int) bool { return l.Plugins[i].ID < l.Plugins[j].ID })
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("plugins.lockのエンコードに失敗しました: %w", err)
+	}
+	return os.WriteFile(filepath.Join(serverDir, LockFileName), append(data, '\n'), 0644)
+}
+
+// Find returns the entry for id.
+func (l *Lock) Find(id string) (Entry, bool) {
+	for _, e := range l.Plugins {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Put adds or replaces the entry with the same ID.
+func (l *Lock) Put(e Entry) {
+	for i := range l.Plugins {
+		if l.Plugins[i].ID == e.ID {
+			l.Plugins[i] = e
+			return
+		}
+	}
+	l.Plugins = append(l.Plugins, e)
+}
+
+// Remove deletes the entry for id and reports whether it existed.
+func (l *Lock) Remove(id string) (Entry, bool) {
+	for i, e := range l.Plugins {
+		if e.ID == id {
+			l.Plugins = append(l.Plugins[:i], l.Plugins[i+1:]...)
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Unmanaged は、plugins ディレクトリにあってロックファイルに記録されていない jar を返します。
+func Unmanaged(pluginsDir string, lock *Lock) ([]string, error) {
+	managed := make([]string, 0, len(lock.Plugins))
+	for _, e := range lock.Plugins {
+		managed = append(managed, e.Filename)
+	}
+	return mods.UnmanagedJars(pluginsDir, managed)
+}
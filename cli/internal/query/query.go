@@ -0,0 +1,261 @@
+// Package query は、Minecraft サーバーの Query プロトコル (GameSpy4 / UT3 形式, UDP) のクライアントです。
+// server.properties で enable-query=true のサーバーから、プレイヤー一覧やプラグイン一覧を取得できます。
+package query
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	typeHandshake byte = 9
+	typeStat      byte = 0
+)
+
+var magic = []byte{0xFE, 0xFD}
+
+// kvPadding と playerPadding は、フルステータス応答のキー/値とプレイヤー一覧の前に入る固定のバイト列です。
+var (
+	kvPadding     = []byte("splitnum\x00\x80\x00")
+	playerPadding = []byte("\x01player_\x00\x00")
+)
+
+// BasicStat はベーシックステータスの応答です。
+type BasicStat struct {
+	MOTD       string
+	GameType   string
+	Map        string
+	NumPlayers int
+	MaxPlayers int
+	HostPort   int
+	HostIP     string
+}
+
+// FullStat はフルステータスの応答です。
+type FullStat struct {
+	MOTD       string
+	GameType   string
+	GameID     string
+	Version    string
+	Map        string
+	NumPlayers int
+	MaxPlayers int
+	HostPort   int
+	HostIP     string
+	// ServerMod は "Paper on 1.20.1" のようなサーバー実装の名前です。
+	ServerMod string
+	Plugins   []string
+	Players   []string
+	// Raw はキー/値部分をそのまま保持します。
+	Raw map[string]string
+}
+
+// Client は1台のサーバーに対する Query クライアントです。
+type Client struct {
+	conn      net.Conn
+	sessionID int32
+	token     int32
+	timeout   time.Duration
+}
+
+// Dial はサーバーにハンドシェイクを送り、チャレンジトークンを取得します。
+func Dial(address string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("Queryポートに接続できません: %w", err)
+	}
+	c := &Client{
+		conn: conn,
+		// セッションIDは各バイトの下位4ビットしか使われない
+		sessionID: int32(time.Now().UnixNano()) & 0x0F0F0F0F,
+		timeout:   timeout,
+	}
+	if err := c.handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close closes the UDP socket.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) request(packetType byte, payload []byte) ([]byte, error) {
+	req := append([]byte{}, magic...)
+	req = append(req, packetType)
+	req = binary.BigEndian.AppendUint32(req, uint32(c.sessionID))
+	req = append(req, payload...)
+
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(req); err != nil {
+		return nil, fmt.Errorf("Queryパケットの送信に失敗しました: %w", err)
+	}
+
+	buf := make([]byte, 65535)
+	n, err := c.conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("Query応答の受信に失敗しました: %w", err)
+	}
+	if n < 5 || buf[0] != packetType || int32(binary.BigEndian.Uint32(buf[1:5])) != c.sessionID {
+		return nil, errors.New("不正なQuery応答です")
+	}
+	return buf[5:n], nil
+}
+
+func (c *Client) handshake() error {
+	resp, err := c.request(typeHandshake, nil)
+	if err != nil {
+		return err
+	}
+	token, err := strconv.ParseInt(string(bytes.TrimRight(resp, "\x00")), 10, 32)
+	if err != nil {
+		return fmt.Errorf("チャレンジトークンを解釈できません: %w", err)
+	}
+	c.token = int32(token)
+	return nil
+}
+
+func (c *Client) tokenPayload() []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(c.token))
+}
+
+// Basic はベーシックステータスを取得します。
+func (c *Client) Basic() (*BasicStat, error) {
+	resp, err := c.request(typeStat, c.tokenPayload())
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, 5)
+	rest := resp
+	for i := 0; i < 5; i++ {
+		s, r, ok := cutNull(rest)
+		if !ok {
+			return nil, errors.New("不正なベーシックステータス応答です")
+		}
+		fields = append(fields, s)
+		rest = r
+	}
+	if len(rest) < 2 {
+		return nil, errors.New("不正なベーシックステータス応答です")
+	}
+	// ポート番号だけはリトルエンディアン
+	port := binary.LittleEndian.Uint16(rest[:2])
+	ip, _, _ := cutNull(rest[2:])
+
+	stat := &BasicStat{
+		MOTD:     fields[0],
+		GameType: fields[1],
+		Map:      fields[2],
+		HostPort: int(port),
+		HostIP:   ip,
+	}
+	stat.NumPlayers, _ = strconv.Atoi(fields[3])
+	stat.MaxPlayers, _ = strconv.Atoi(fields[4])
+	return stat, nil
+}
+
+// Full はフルステータスを取得します。
+func (c *Client) Full() (*FullStat, error) {
+	// フルステータスはトークンの後に4バイトのパディングを付けて要求する
+	resp, err := c.request(typeStat, append(c.tokenPayload(), 0, 0, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(resp, kvPadding) {
+		return nil, errors.New("不正なフルステータス応答です")
+	}
+	rest := resp[len(kvPadding):]
+
+	raw := make(map[string]string)
+	for {
+		key, r, ok := cutNull(rest)
+		if !ok {
+			return nil, errors.New("不正なフルステータス応答です")
+		}
+		rest = r
+		if key == "" {
+			break
+		}
+		value, r, ok := cutNull(rest)
+		if !ok {
+			return nil, errors.New("不正なフルステータス応答です")
+		}
+		rest = r
+		raw[key] = value
+	}
+
+	var players []string
+	if bytes.HasPrefix(rest, playerPadding) {
+		rest = rest[len(playerPadding):]
+		for {
+			name, r, ok := cutNull(rest)
+			if !ok || name == "" {
+				break
+			}
+			players = append(players, name)
+			rest = r
+		}
+	}
+
+	stat := &FullStat{
+		MOTD:     raw["hostname"],
+		GameType: raw["gametype"],
+		GameID:   raw["game_id"],
+		Version:  raw["version"],
+		Map:      raw["map"],
+		HostIP:   raw["hostip"],
+		Players:  players,
+		Raw:      raw,
+	}
+	stat.NumPlayers, _ = strconv.Atoi(raw["numplayers"])
+	stat.MaxPlayers, _ = strconv.Atoi(raw["maxplayers"])
+	stat.HostPort, _ = strconv.Atoi(raw["hostport"])
+	stat.ServerMod, stat.Plugins = parsePlugins(raw["plugins"])
+	return stat, nil
+}
+
+// parsePlugins は "Paper on 1.20.1: LuckPerms 5.4; Vault 1.7" 形式の文字列を分解します。
+// バニラのサーバーは空文字列を返します。
+func parsePlugins(s string) (string, []string) {
+	if s == "" {
+		return "", nil
+	}
+	mod, list, found := strings.Cut(s, ": ")
+	if !found {
+		return strings.TrimSpace(s), nil
+	}
+	var plugins []string
+	for _, p := range strings.Split(list, "; ") {
+		if p = strings.TrimSpace(p); p != "" {
+			plugins = append(plugins, p)
+		}
+	}
+	return mod, plugins
+}
+
+func cutNull(b []byte) (string, []byte, bool) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return "", nil, false
+	}
+	return string(b[:i]), b[i+1:], true
+}
+
+// FullStatOf は、接続・フルステータス取得・切断をまとめて行います。
+func FullStatOf(address string, timeout time.Duration) (*FullStat, error) {
+	c, err := Dial(address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.Full()
+}
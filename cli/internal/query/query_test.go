@@ -0,0 +1,183 @@
+package query
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// challengeToken はテスト用サーバーが返すチャレンジトークンです。バニラのサーバーは負の値も返します。
+const challengeToken int32 = -9513307
+
+// fullStatResponse は Paper のサーバーが返すフルステータスのキー/値とプレイヤー一覧です。
+var fullStatResponse = []byte("splitnum\x00\x80\x00" +
+	"hostname\x00A Minecraft Server\x00" +
+	"gametype\x00SMP\x00" +
+	"game_id\x00MINECRAFT\x00" +
+	"version\x001.20.4\x00" +
+	"plugins\x00Paper on 1.20.4-R0.1-SNAPSHOT: LuckPerms 5.4.102; Vault 1.7.3-b131\x00" +
+	"map\x00world\x00" +
+	"numplayers\x002\x00" +
+	"maxplayers\x0020\x00" +
+	"hostport\x0025565\x00" +
+	"hostip\x00172.18.0.2\x00" +
+	"\x00" +
+	"\x01player_\x00\x00" +
+	"Steve\x00Alex\x00" +
+	"\x00")
+
+// fakeServer は Query プロトコルに応答するテスト用の UDP サーバーを起動し、アドレスを返します。
+// sessionOffset を 0 以外にすると、応答のセッションIDをずらします。
+func fakeServer(t *testing.T, sessionOffset int32) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+			if n < 7 || !bytes.Equal(req[:2], magic) {
+				t.Errorf("不正なリクエスト: %x", req)
+				continue
+			}
+			packetType := req[2]
+			session := int32(binary.BigEndian.Uint32(req[3:7])) + sessionOffset
+			payload := req[7:]
+
+			resp := []byte{packetType}
+			resp = binary.BigEndian.AppendUint32(resp, uint32(session))
+			switch {
+			case packetType == typeHandshake:
+				resp = append(resp, []byte("-9513307\x00")...)
+			case packetType == typeStat && len(payload) >= 4:
+				if token := int32(binary.BigEndian.Uint32(payload[:4])); token != challengeToken {
+					t.Errorf("トークン = %d, want %d", token, challengeToken)
+					continue
+				}
+				if len(payload) == 8 {
+					resp = append(resp, fullStatResponse...)
+				} else {
+					resp = append(resp, "A Minecraft Server\x00SMP\x00world\x002\x0020\x00"...)
+					resp = binary.LittleEndian.AppendUint16(resp, 25565)
+					resp = append(resp, "172.18.0.2\x00"...)
+				}
+			default:
+				t.Errorf("不明なリクエスト: %x", req)
+				continue
+			}
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestHandshakeToken(t *testing.T) {
+	c, err := Dial(fakeServer(t, 0), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.token != challengeToken {
+		t.Errorf("token = %d, want %d", c.token, challengeToken)
+	}
+	if c.sessionID&^0x0F0F0F0F != 0 {
+		t.Errorf("セッションID %08x に下位4ビット以外のビットが立っています", c.sessionID)
+	}
+}
+
+func TestBasic(t *testing.T) {
+	c, err := Dial(fakeServer(t, 0), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	got, err := c.Basic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &BasicStat{
+		MOTD:       "A Minecraft Server",
+		GameType:   "SMP",
+		Map:        "world",
+		NumPlayers: 2,
+		MaxPlayers: 20,
+		HostPort:   25565,
+		HostIP:     "172.18.0.2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Basic() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFull(t *testing.T) {
+	got, err := FullStatOf(fakeServer(t, 0), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &FullStat{
+		MOTD:       "A Minecraft Server",
+		GameType:   "SMP",
+		GameID:     "MINECRAFT",
+		Version:    "1.20.4",
+		Map:        "world",
+		NumPlayers: 2,
+		MaxPlayers: 20,
+		HostPort:   25565,
+		HostIP:     "172.18.0.2",
+		ServerMod:  "Paper on 1.20.4-R0.1-SNAPSHOT",
+		Plugins:    []string{"LuckPerms 5.4.102", "Vault 1.7.3-b131"},
+		Players:    []string{"Steve", "Alex"},
+		Raw: map[string]string{
+			"hostname":   "A Minecraft Server",
+			"gametype":   "SMP",
+			"game_id":    "MINECRAFT",
+			"version":    "1.20.4",
+			"plugins":    "Paper on 1.20.4-R0.1-SNAPSHOT: LuckPerms 5.4.102; Vault 1.7.3-b131",
+			"map":        "world",
+			"numplayers": "2",
+			"maxplayers": "20",
+			"hostport":   "25565",
+			"hostip":     "172.18.0.2",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Full() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSessionMismatch(t *testing.T) {
+	if _, err := Dial(fakeServer(t, 1), time.Second); err == nil {
+		t.Error("セッションIDが異なる応答でエラーになりませんでした")
+	}
+}
+
+func TestParsePlugins(t *testing.T) {
+	tests := []struct {
+		in      string
+		mod     string
+		plugins []string
+	}{
+		{"", "", nil},
+		{"Paper on 1.20.4", "Paper on 1.20.4", nil},
+		{"Paper on 1.20.4: ", "Paper on 1.20.4", nil},
+		{"Paper on 1.20.4: LuckPerms 5.4; Vault 1.7", "Paper on 1.20.4", []string{"LuckPerms 5.4", "Vault 1.7"}},
+	}
+	for _, tt := range tests {
+		mod, plugins := parsePlugins(tt.in)
+		if mod != tt.mod || !reflect.DeepEqual(plugins, tt.plugins) {
+			t.Errorf("parsePlugins(%q) = %q, %q, want %q, %q", tt.in, mod, plugins, tt.mod, tt.plugins)
+		}
+	}
+}
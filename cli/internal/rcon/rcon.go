@@ -0,0 +1,133 @@
+package rcon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Packet types defined by the Source RCON protocol, which Minecraft implements.
+const (
+	typeResponse int32 = 0
+	typeCommand  int32 = 2
+	typeLogin    int32 = 3
+)
+
+// maxPayload はサーバーが1パケットで返す最大のペイロード長です。
+const maxPayload = 4096
+
+// ErrAuthFailed はパスワードが間違っている場合に返されます。
+var ErrAuthFailed = errors.New("RCON認証に失敗しました")
+
+// Client はMinecraftサーバーのRCONクライアントです。
+type Client struct {
+	conn    net.Conn
+	nextID  int32
+	timeout time.Duration
+}
+
+// Dial はRCONポートに接続してログインします。
+func Dial(address, password string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("RCONへの接続に失敗しました: %w", err)
+	}
+	c := &Client{conn: conn, nextID: 1, timeout: timeout}
+
+	id, err := c.send(typeLogin, password)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	respID, _, _, err := c.read()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// 認証に失敗すると、サーバーはリクエストIDの代わりに -1 を返す
+	if respID == -1 || respID != id {
+		conn.Close()
+		return nil, ErrAuthFailed
+	}
+	return c, nil
+}
+
+// Close closes the underlying connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Command はコマンドを実行して、その出力を返します。
+func (c *Client) Command(command string) (string, error) {
+	id, err := c.send(typeCommand, command)
+	if err != nil {
+		return "", err
+	}
+
+	// 長い応答は複数パケットに分割されるため、応答の終わりを検出するための
+	// 番兵として無効なタイプのパケットを送る。サーバーはこれに "Unknown request" で応答する。
+	sentinel, err := c.send(typeResponse, "")
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	for {
+		respID, _, body, err := c.read()
+		if err != nil {
+			return "", err
+		}
+		if respID == sentinel {
+			return out.String(), nil
+		}
+		if respID != id {
+			return "", fmt.Errorf("予期しないRCON応答ID: %d", respID)
+		}
+		out.WriteString(body)
+	}
+}
+
+func (c *Client) send(packetType int32, body string) (int32, error) {
+	id := c.nextID
+	c.nextID++
+
+	var buf bytes.Buffer
+	length := int32(4 + 4 + len(body) + 2)
+	binary.Write(&buf, binary.LittleEndian, length)
+	binary.Write(&buf, binary.LittleEndian, id)
+	binary.Write(&buf, binary.LittleEndian, packetType)
+	buf.WriteString(body)
+	buf.Write([]byte{0, 0})
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(buf.Bytes()); err != nil {
+		return 0, fmt.Errorf("RCONパケットの送信に失敗しました: %w", err)
+	}
+	return id, nil
+}
+
+func (c *Client) read() (int32, int32, string, error) {
+	c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+
+	var length int32
+	if err := binary.Read(c.conn, binary.LittleEndian, &length); err != nil {
+		return 0, 0, "", fmt.Errorf("RCONパケットの受信に失敗しました: %w", err)
+	}
+	if length < 10 || length > maxPayload+10 {
+		return 0, 0, "", fmt.Errorf("不正なRCONパケット長: %d", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.conn, payload); err != nil {
+		return 0, 0, "", fmt.Errorf("RCONパケットの受信に失敗しました: %w", err)
+	}
+
+	id := int32(binary.LittleEndian.Uint32(payload[0:4]))
+	packetType := int32(binary.LittleEndian.Uint32(payload[4:8]))
+	body := string(bytes.TrimRight(payload[8:], "\x00"))
+	return id, packetType, body, nil
+}
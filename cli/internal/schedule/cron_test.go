@@ -0,0 +1,94 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		expr string
+		from string
+		want string // 空は一致する時刻がないこと
+	}{
+		// ステップ
+		{"*/15 * * * *", "2024-01-01 10:07", "2024-01-01 10:15"},
+		{"0 */6 * * *", "2024-01-01 07:00", "2024-01-01 12:00"},
+		{"5/20 * * * *", "2024-01-01 10:26", "2024-01-01 10:45"},
+		// 範囲と範囲のステップ
+		{"30 9-17/4 * * *", "2024-01-01 14:00", "2024-01-01 17:30"},
+		{"0 0 * * 1-5", "2024-01-06 10:00", "2024-01-08 00:00"},
+		// リスト
+		{"0 0 1,15 * *", "2024-01-02 00:00", "2024-01-15 00:00"},
+		{"0 6,18 * * *", "2024-01-01 06:00", "2024-01-01 18:00"},
+		// 7 は日曜日
+		{"0 0 * * 7", "2024-01-01 00:00", "2024-01-07 00:00"},
+		// 日と曜日の両方を指定した場合は、どちらかに一致すればよい (13日または金曜日)
+		{"0 0 13 * 5", "2024-01-01 00:00", "2024-01-05 00:00"},
+		{"0 0 13 * 5", "2024-01-06 00:00", "2024-01-12 00:00"},
+		// * で始まるフィールドは * として扱うので、奇数日かつ月曜日
+		{"0 0 */2 * 1", "2024-01-02 00:00", "2024-01-15 00:00"},
+		{"0 0 1 * */2", "2024-01-02 00:00", "2024-02-01 00:00"},
+		// マクロ
+		{"@monthly", "2024-01-15 00:00", "2024-02-01 00:00"},
+		{"@weekly", "2024-01-01 00:00", "2024-01-07 00:00"},
+		// 次の時刻は from より後
+		{"0 12 * * *", "2024-01-01 12:00", "2024-01-02 12:00"},
+		// 年をまたぐ・うるう日
+		{"0 0 1 1 *", "2024-06-01 00:00", "2025-01-01 00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		// 一致する日がない
+		{"0 0 31 2 *", "2024-01-01 00:00", ""},
+		{"0 0 30 2 *", "2024-01-01 00:00", ""},
+		{"0 0 31 4,6,9,11 *", "2024-01-01 00:00", ""},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		got := c.Next(at(tt.from))
+		if tt.want == "" {
+			if !got.IsZero() {
+				t.Errorf("%q.Next(%s) = %s, want zero", tt.expr, tt.from, got.Format("2006-01-02 15:04"))
+			}
+			continue
+		}
+		if !got.Equal(at(tt.want)) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.expr, tt.from, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"0 0 0 * *",
+		"0 0 32 * *",
+		"0 0 * 13 *",
+		"0 0 * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-b * * * *",
+		"@yearly",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) にエラーがありません", expr)
+		}
+	}
+}
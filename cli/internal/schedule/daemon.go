@@ -0,0 +1,143 @@
+package schedule
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// reloadInterval は、スケジュールファイルの変更を確認する間隔です。
+const reloadInterval = time.Minute
+
+// Daemon は、スケジュールファイルに従ってジョブを実行し続けます。
+type Daemon struct {
+	Path   string
+	Runner *Runner
+
+	mu      sync.Mutex
+	running map[string]bool
+	wg      sync.WaitGroup
+}
+
+// Run は ctx がキャンセルされるまでジョブを実行します。
+// 戻る前に、実行中のジョブの完了を待ちます。
+func (d *Daemon) Run(ctx context.Context) error {
+	d.running = make(map[string]bool)
+	defer d.wg.Wait()
+
+	file, modTime, err := d.load()
+	if err != nil {
+		return err
+	}
+	d.Runner.logf("%d 個のジョブを読み込みました\n", len(file.Jobs))
+
+	now := time.Now()
+	d.catchUp(ctx, file, now)
+	next := d.plan(file, now)
+
+	for {
+		wake := now.Add(reloadInterval)
+		for _, t := range next {
+			if !t.IsZero() && t.Before(wake) {
+				wake = t
+			}
+		}
+
+		timer := time.NewTimer(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		now = time.Now()
+
+		if info, err := os.Stat(d.Path); err == nil && !info.ModTime().Equal(modTime) {
+			reloaded, newModTime, err := d.load()
+			if err != nil {
+				d.Runner.logf("スケジュールファイルの再読み込みに失敗しました: %v\n", err)
+			} else {
+				d.Runner.logf("スケジュールファイルを再読み込みしました (%d 個のジョブ)\n", len(reloaded.Jobs))
+				file, modTime = reloaded, newModTime
+				next = d.plan(file, now)
+			}
+		}
+
+		for _, job := range file.Jobs {
+			t, ok := next[job.Name]
+			if !ok || t.IsZero() || now.Before(t) {
+				continue
+			}
+			d.start(ctx, job, TriggerSchedule)
+			next[job.Name] = job.Next(now)
+		}
+	}
+}
+
+func (d *Daemon) load() (*File, time.Time, error) {
+	file, err := LoadFile(d.Path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var modTime time.Time
+	if info, err := os.Stat(d.Path); err == nil {
+		modTime = info.ModTime()
+	}
+	return file, modTime, nil
+}
+
+func (d *Daemon) plan(file *File, now time.Time) map[string]time.Time {
+	next := make(map[string]time.Time, len(file.Jobs))
+	for _, job := range file.Jobs {
+		next[job.Name] = job.Next(now)
+	}
+	return next
+}
+
+// catchUp は、前回の実行以降に予定時刻を過ぎていたジョブを1回だけ実行します。
+// 一度も実行されたことがないジョブは対象外です。
+func (d *Daemon) catchUp(ctx context.Context, file *File, now time.Time) {
+	for _, job := range file.Jobs {
+		if !job.ShouldCatchUp() {
+			continue
+		}
+		last, found, err := d.Runner.History.Last(job.Name)
+		if err != nil {
+			d.Runner.logf("[%s] 実行履歴の読み込みに失敗しました: %v\n", job.Name, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		if missed := job.Next(last.StartedAt); !missed.IsZero() && missed.Before(now) {
+			d.Runner.logf("[%s] %s の実行が行われていなかったため、今から実行します\n",
+				job.Name, missed.Format("2006-01-02 15:04"))
+			d.start(ctx, job, TriggerCatchUp)
+		}
+	}
+}
+
+// start はジョブをゴルーチンで実行します。前回の実行が終わっていない場合はスキップします。
+// ctx がキャンセルされると、再起動のカウントダウン中のジョブは再起動せずに終わります。
+func (d *Daemon) start(ctx context.Context, job Job, trigger string) {
+	d.mu.Lock()
+	if d.running[job.Name] {
+		d.mu.Unlock()
+		d.Runner.logf("[%s] 前回の実行が終わっていないためスキップします\n", job.Name)
+		return
+	}
+	d.running[job.Name] = true
+	d.mu.Unlock()
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer func() {
+			d.mu.Lock()
+			delete(d.running, job.Name)
+			d.mu.Unlock()
+		}()
+		d.Runner.Execute(ctx, job, trigger)
+	}()
+}
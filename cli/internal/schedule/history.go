@@ -0,0 +1,130 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"mcctl/internal/filelock"
+)
+
+// maxHistory は、ジョブごとに保持する実行履歴の件数です。
+const maxHistory = 50
+
+// 実行のきっかけ
+const (
+	TriggerSchedule = "schedule"
+	TriggerCatchUp  = "catch-up"
+	TriggerManual   = "manual"
+)
+
+// Run は、ジョブの1回分の実行記録です。
+type Run struct {
+	Trigger    string    `json:"trigger"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Success    bool      `json:"success"`
+	Output     string    `json:"output,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// History は、ジョブ名ごとの実行履歴をJSONファイルに保存します。
+// デーモンと `mcctl schedule run-now` が同じファイルを更新するため、
+// 記録のたびにロックを取ってファイルを読み直し、一時ファイルに書いてから置き換えます。
+type History struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (h *History) load() (map[string][]Run, error) {
+	runs := make(map[string][]Run)
+	data, err := os.ReadFile(h.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return runs, nil
+		}
+		return nil, fmt.Errorf("実行履歴の読み込みに失敗しました: %w", err)
+	}
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("実行履歴のパースに失敗しました: %w", err)
+	}
+	return runs, nil
+}
+
+// Runs はジョブの実行履歴を新しい順に返します。
+func (h *History) Runs(job string) ([]Run, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	all, err := h.load()
+	if err != nil {
+		return nil, err
+	}
+	runs := all[job]
+	reversed := make([]Run, len(runs))
+	for i, r := range runs {
+		reversed[len(runs)-1-i] = r
+	}
+	return reversed, nil
+}
+
+// Last returns the most recent run of job, if any.
+func (h *History) Last(job string) (Run, bool, error) {
+	runs, err := h.Runs(job)
+	if err != nil || len(runs) == 0 {
+		return Run{}, false, err
+	}
+	return runs[0], true, nil
+}
+
+// Record は実行記録を追加し、古い記録を切り詰めます。
+func (h *History) Record(job string, run Run) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	unlock, err := filelock.Lock(h.Path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	all, err := h.load()
+	if err != nil {
+		return err
+	}
+	runs := append(all[job], run)
+	if len(runs) > maxHistory {
+		runs = runs[len(runs)-maxHistory:]
+	}
+	all[job] = runs
+
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return fmt.Errorf("実行履歴のエンコードに失敗しました: %w", err)
+	}
+	// 読み込み中のプロセスが書きかけのファイルを見ないよう、一時ファイルに書いてからリネームする
+	tmp, err := os.CreateTemp(filepath.Dir(h.Path), "."+filepath.Base(h.Path)+"-")
+	if err != nil {
+		return fmt.Errorf("実行履歴の書き込みに失敗しました: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("実行履歴の書き込みに失敗しました: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), h.Path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("実行履歴の書き込みに失敗しました: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"mcctl/internal/backup"
+	"mcctl/internal/docker"
+	"mcctl/internal/rcon"
+	"mcctl/internal/server"
+	"mcctl/internal/snapshot"
+)
+
+// rconTimeout はジョブから送るRCONコマンドのタイムアウトです。
+const rconTimeout = 10 * time.Second
+
+// Runner は、ジョブのアクションを実行します。
+// デーモンと `mcctl schedule run-now` の両方から使われます。
+type Runner struct {
+	ServersPath   string
+	BackupRoot    string
+	SnapshotRoot  string
+	RetentionPath string
+	ComposeFiles  []string
+	History       *History
+	Log           io.Writer
+}
+
+// Execute はジョブを実行し、その結果を実行履歴に記録します。
+// ctx がキャンセルされると、再起動前のカウントダウンを中断します。
+func (r *Runner) Execute(ctx context.Context, job Job, trigger string) Run {
+	run := Run{Trigger: trigger, StartedAt: time.Now()}
+	r.logf("[%s] %s を開始します (%s)\n", job.Name, job.Action, trigger)
+
+	output, err := r.run(ctx, job)
+	run.FinishedAt = time.Now()
+	run.Output = strings.TrimSpace(output)
+	if err != nil {
+		run.Error = err.Error()
+		r.logf("[%s] 失敗しました: %v\n", job.Name, err)
+	} else {
+		run.Success = true
+		r.logf("[%s] 完了しました (%s)\n", job.Name, run.FinishedAt.Sub(run.StartedAt).Round(time.Second))
+	}
+
+	if r.History != nil {
+		if err := r.History.Record(job.Name, run); err != nil {
+			r.logf("[%s] 実行履歴の記録に失敗しました: %v\n", job.Name, err)
+		}
+	}
+	return run
+}
+
+func (r *Runner) run(ctx context.Context, job Job) (string, error) {
+	switch job.Action {
+	case ActionRestart:
+		return r.restart(ctx, job)
+	case ActionBackup:
+		return r.backup(job)
+	case ActionPrune:
+		return r.prune(job)
+	case ActionRCON:
+		return r.command(job.Server, job.Command)
+	}
+	return "", fmt.Errorf("未知のアクション %q", job.Action)
+}
+
+func (r *Runner) logf(format string, args ...interface{}) {
+	if r.Log != nil {
+		fmt.Fprintf(r.Log, format, args...)
+	}
+}
+
+// command はサーバーに1つのRCONコマンドを送ります。
+func (r *Runner) command(serverName, command string) (string, error) {
+	address, password, err := server.RCONTarget(r.ServersPath, serverName)
+	if err != nil {
+		return "", err
+	}
+	client, err := rcon.Dial(address, password, rconTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	return client.Command(command)
+}
+
+// countdownMarks は、再起動前に告知を出す残り時間です。
+var countdownMarks = []time.Duration{
+	30 * time.Minute, 15 * time.Minute, 10 * time.Minute, 5 * time.Minute,
+	time.Minute, 30 * time.Second, 10 * time.Second, 5 * time.Second,
+}
+
+func (r *Runner) restart(ctx context.Context, job Job) (string, error) {
+	compose, err := docker.ForService(r.ComposeFiles, job.Server)
+	if err != nil {
+		return "", err
+	}
+	countdown, err := job.CountdownDuration()
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	announced := false
+	cancel := func() (string, error) {
+		if announced {
+			r.command(job.Server, "say 再起動は中止されました")
+		}
+		return out.String(), fmt.Errorf("カウントダウン中に中断されたため、再起動しませんでした: %w", ctx.Err())
+	}
+
+	remaining := countdown
+	for _, mark := range countdownMarks {
+		if mark > remaining {
+			continue
+		}
+		if err := sleep(ctx, remaining-mark); err != nil {
+			return cancel()
+		}
+		remaining = mark
+		msg := fmt.Sprintf("say サーバーはあと%sで再起動します", formatRemaining(mark))
+		// サーバーが既に落ちている場合もあるので、告知の失敗では中断しない
+		if _, err := r.command(job.Server, msg); err != nil {
+			fmt.Fprintf(&out, "告知に失敗しました: %v\n", err)
+			break
+		}
+		announced = true
+	}
+	if err := sleep(ctx, remaining); err != nil {
+		return cancel()
+	}
+
+	if _, err := r.command(job.Server, "save-all"); err == nil {
+		out.WriteString("save-all を実行しました\n")
+	}
+	if err := compose.Restart(job.Server); err != nil {
+		return out.String(), err
+	}
+	out.WriteString("コンテナを再起動しました\n")
+	return out.String(), nil
+}
+
+// sleep waits for d, returning ctx.Err() if ctx is cancelled first.
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+func formatRemaining(d time.Duration) string {
+	if d >= time.Minute {
+		return fmt.Sprintf("%d分", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%d秒", int(d.Seconds()))
+}
+
+func (r *Runner) backup(job Job) (string, error) {
+	serverDir := server.ServerDirectory(job.Server)
+	if _, err := os.Stat(serverDir); err != nil {
+		return "", fmt.Errorf("サーバーディレクトリが見つかりません: %s", serverDir)
+	}
+
+	// 書き込み途中のリージョンファイルを保存しないよう、自動保存を止めてから取得する。
+	// サーバーが停止している場合はそのままバックアップする。
+	var out strings.Builder
+	if _, err := r.command(job.Server, "save-off"); err == nil {
+		defer r.command(job.Server, "save-on")
+		r.command(job.Server, "save-all flush")
+	}
+
+	now := time.Now()
+	if job.Snapshot {
+		store, err := snapshot.Open(r.SnapshotRoot)
+		if err != nil {
+			return "", err
+		}
+		m, stats, err := store.Create(job.Server, serverDir, now)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&out, "スナップショット %s/%s を作成しました (新規ブロック %d, 追加 %d bytes)\n",
+			m.Server, m.ID, stats.NewBlocks, stats.StoredBytes)
+		return out.String(), nil
+	}
+
+	b, err := backup.Create(r.BackupRoot, job.Server, serverDir, now)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&out, "バックアップを作成しました: %s (%d bytes)\n", b.Path, b.Size)
+	return out.String(), nil
+}
+
+func (r *Runner) prune(job Job) (string, error) {
+	config, err := backup.LoadRetentionConfig(r.RetentionPath)
+	if err != nil {
+		return "", err
+	}
+
+	names := []string{job.Server}
+	if job.Server == "" {
+		names, err = backup.ListServers(r.BackupRoot)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var out strings.Builder
+	for _, name := range names {
+		removed, err := backup.Prune(r.BackupRoot, name, config.PolicyFor(name), false)
+		for _, b := range removed {
+			fmt.Fprintf(&out, "削除しました: %s\n", b.Path)
+		}
+		if err != nil {
+			return out.String(), err
+		}
+	}
+	return out.String(), nil
+}
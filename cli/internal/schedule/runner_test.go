@@ -0,0 +1,65 @@
+package schedule
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRestartCountdownStopsOnCancel(t *testing.T) {
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, "docker-compose.yml")
+	os.WriteFile(composePath, []byte("services:\n  survival:\n    image: itzg/minecraft-server\n"), 0644)
+	r := &Runner{ServersPath: filepath.Join(dir, "servers.json"), ComposeFiles: []string{composePath}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	run := r.Execute(ctx, Job{Name: "restart", Action: ActionRestart, Server: "survival", Countdown: "10m"}, TriggerManual)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("キャンセルしてから戻るまでに %v かかりました", elapsed)
+	}
+	if run.Success || !strings.Contains(run.Error, "再起動しませんでした") {
+		t.Errorf("run = %+v, want cancelled restart", run)
+	}
+}
+
+func TestHistoryRecordConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+
+	// デーモンと run-now のように、別々の History から同じファイルに記録する
+	const writers, runs = 4, 10
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h := &History{Path: path}
+			for i := 0; i < runs; i++ {
+				if err := h.Record("backup", Run{Trigger: TriggerManual, Success: true}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := (&History{Path: path}).Runs("backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != writers*runs {
+		t.Errorf("%d 件の記録が残りました。want %d", len(got), writers*runs)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	for _, e := range entries {
+		if e.Name() != "history.json" && e.Name() != "history.json.lock" {
+			t.Errorf("一時ファイルが残っています: %s", e.Name())
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package schedule
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"mcctl/internal/wake"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ジョブのアクション
+const (
+	ActionRestart = "restart"
+	ActionBackup  = "backup"
+	ActionPrune   = "prune"
+	ActionRCON    = "rcon"
+)
+
+// File は、スケジュールファイル (例: minecraft/schedule.yaml) の構造体です。
+type File struct {
+	Jobs []Job `yaml:"jobs"`
+	// Sleep は、アイドル時に停止しログイン時に起動するサーバーの一覧です。
+	Sleep []wake.Config `yaml:"sleep,omitempty"`
+}
+
+// Job は、cron式で定期実行される1つのジョブです。
+type Job struct {
+	Name   string `yaml:"name"`
+	Server string `yaml:"server,omitempty"` // prune では省略するとすべてのサーバーが対象
+	Cron   string `yaml:"cron"`
+	Action string `yaml:"action"`
+
+	// Countdown は restart の前にプレイヤーへ告知する時間です (例: "5m")。
+	Countdown string `yaml:"countdown,omitempty"`
+	// Command は rcon で実行するコマンドです (例: "say こんにちは")。
+	Command string `yaml:"command,omitempty"`
+	// Snapshot が true の場合、backup は tar.gz ではなくスナップショットストアに保存します。
+	Snapshot bool `yaml:"snapshot,omitempty"`
+	// CatchUp は、デーモンが停止していた間に実行されなかった分を起動時に1回実行するかどうかです。
+	// 省略時は true です。
+	CatchUp *bool `yaml:"catchUp,omitempty"`
+
+	cron *Cron
+}
+
+// LoadFile はスケジュールファイルを読み込み、各ジョブを検証します。
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &File{}, nil
+		}
+		return nil, fmt.Errorf("スケジュールファイルの読み込みに失敗しました: %w", err)
+	}
+
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("スケジュールファイルのパースに失敗しました: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range f.Jobs {
+		job := &f.Jobs[i]
+		if job.Name == "" {
+			return nil, fmt.Errorf("%d 番目のジョブに name がありません", i+1)
+		}
+		if seen[job.Name] {
+			return nil, fmt.Errorf("ジョブ名 %s が重複しています", job.Name)
+		}
+		seen[job.Name] = true
+		if err := job.validate(); err != nil {
+			return nil, fmt.Errorf("ジョブ %s: %w", job.Name, err)
+		}
+	}
+
+	for _, sleep := range f.Sleep {
+		if sleep.Server == "" || sleep.Listen == "" {
+			return nil, fmt.Errorf("sleep の各項目には server と listen が必要です")
+		}
+	}
+	return &f, nil
+}
+
+func (j *Job) validate() error {
+	c, err := ParseCron(j.Cron)
+	if err != nil {
+		return err
+	}
+	j.cron = c
+
+	switch j.Action {
+	case ActionRestart:
+		if j.Server == "" {
+			return fmt.Errorf("restart には server が必要です")
+		}
+		if _, err := j.CountdownDuration(); err != nil {
+			return err
+		}
+	case ActionBackup:
+		if j.Server == "" {
+			return fmt.Errorf("backup には server が必要です")
+		}
+	case ActionRCON:
+		if j.Server == "" || j.Command == "" {
+			return fmt.Errorf("rcon には server と command が必要です")
+		}
+	case ActionPrune:
+	default:
+		return fmt.Errorf("未知のアクション %q", j.Action)
+	}
+	return nil
+}
+
+// Find returns the job with the given name.
+func (f *File) Find(name string) (Job, bool) {
+	for _, job := range f.Jobs {
+		if job.Name == name {
+			return job, true
+		}
+	}
+	return Job{}, false
+}
+
+// Next returns the first scheduled time after t.
+func (j Job) Next(t time.Time) time.Time {
+	return j.cron.Next(t)
+}
+
+// CronExpr returns the parsed cron expression of the job.
+func (j Job) CronExpr() *Cron {
+	return j.cron
+}
+
+// CountdownDuration は Countdown を time.Duration として返します。
+func (j Job) CountdownDuration() (time.Duration, error) {
+	if j.Countdown == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(j.Countdown)
+	if err != nil {
+		return 0, fmt.Errorf("countdown %q をパースできません: %w", j.Countdown, err)
+	}
+	return d, nil
+}
+
+// ShouldCatchUp reports whether missed runs should be executed on startup.
+func (j Job) ShouldCatchUp() bool {
+	return j.CatchUp == nil || *j.CatchUp
+}
@@ -0,0 +1,316 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mcctl/internal/vfs"
+
+	"gopkg.in/yaml.v3"
+)
+
+// nameDerivedProperties は、サーバー名から付けられていることが多い server.properties のキーです。
+var nameDerivedProperties = []string{"motd", "level-name", "server-name"}
+
+// nameDerivedEnvironment は、itzg/minecraft-server で server.properties の値を上書きする環境変数のうち、
+// サーバー名から付けられていることが多いものです。
+var nameDerivedEnvironment = []string{"MOTD", "LEVEL", "SERVER_NAME"}
+
+// WorldDirectories は、server.properties の level-name から決まるワールドのディレクトリです。
+// Paper などはネザーとエンドを別のディレクトリに保存します。
+func WorldDirectories(levelName string) []string {
+	if levelName == "" {
+		levelName = "world"
+	}
+	return []string{levelName, levelName + "_nether", levelName + "_the_end"}
+}
+
+// CopyDirectory は srcDir を dstDir にコピーします。dstDir は存在していてはいけません。
+// skip が true を返したファイルやディレクトリ (srcDir からの相対パス) はコピーしません。
+func CopyDirectory(srcDir, dstDir string, skip func(rel string) bool) error {
+	if _, err := os.Stat(dstDir); err == nil {
+		return fmt.Errorf("%s はすでに存在します", dstDir)
+	}
+	return filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && skip != nil && skip(filepath.ToSlash(rel)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		dst := filepath.Join(dstDir, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(dst, info.Mode().Perm()|0700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, dst)
+		case info.Mode().IsRegular():
+			return copyRegularFile(path, dst, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyRegularFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ReplaceServerName は value の中のサーバー名 from を to に置き換えます。
+// 英数字の途中に現れるもの (例: "survivalist" の "survival") は置き換えず、大文字と小文字は区別しません。
+// 置き換えた後の文字列は再び探さないため、to が from を含んでいても (例: "survival-staging") 一度だけ置き換えます。
+func ReplaceServerName(value, from, to string) string {
+	if from == "" {
+		return value
+	}
+	lower, target := strings.ToLower(value), strings.ToLower(from)
+	if len(lower) != len(value) {
+		// 小文字にすると長さが変わる文字を含む場合は、大文字と小文字を区別して探す
+		lower, target = value, from
+	}
+
+	var b strings.Builder
+	i := 0
+	for i < len(value) {
+		n := strings.Index(lower[i:], target)
+		if n < 0 {
+			break
+		}
+		start, end := i+n, i+n+len(target)
+		if (start > 0 && isAlphanumeric(value[start-1])) || (end < len(value) && isAlphanumeric(value[end])) {
+			// 区切りにない一致は残し、次の文字から探し直す
+			b.WriteString(value[i : start+1])
+			i = start + 1
+			continue
+		}
+		b.WriteString(value[i:start])
+		b.WriteString(to)
+		i = end
+	}
+	b.WriteString(value[i:])
+	return b.String()
+}
+
+func isAlphanumeric(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// RenameInServerDirectory は、複製したサーバーディレクトリの server.properties にある
+// サーバー名から付けた値 (motd、level-name など) を新しい名前に書き換えます。
+// level-name が変わった場合は、ワールドのディレクトリも合わせて名前を変えます。
+// 書き換えた "key: 変更前 -> 変更後" の一覧を返します。
+func RenameInServerDirectory(serverDir, from, to string) ([]string, error) {
+	path := filepath.Join(serverDir, "server.properties")
+	data, err := vfs.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("server.propertiesの読み込みに失敗しました: %w", err)
+	}
+	props := ParseProperties(data)
+
+	var changes []string
+	overrides := make(map[string]string)
+	for _, key := range nameDerivedProperties {
+		old, ok := props[key]
+		if !ok {
+			continue
+		}
+		if renamed := ReplaceServerName(old, from, to); renamed != old {
+			overrides[key] = renamed
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", key, old, renamed))
+		}
+	}
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+
+	var patch strings.Builder
+	for key, value := range overrides {
+		fmt.Fprintf(&patch, "%s=%s\n", key, strings.ReplaceAll(value, ":", `\:`))
+	}
+	if err := vfs.WriteFile(path, MergeProperties(data, []byte(patch.String())), 0644); err != nil {
+		return nil, fmt.Errorf("server.propertiesの書き込みに失敗しました: %w", err)
+	}
+
+	if newLevel, ok := overrides["level-name"]; ok {
+		oldDirs, newDirs := WorldDirectories(props["level-name"]), WorldDirectories(newLevel)
+		for i := range oldDirs {
+			src := filepath.Join(serverDir, oldDirs[i])
+			if _, err := os.Stat(src); err != nil {
+				continue
+			}
+			if err := os.Rename(src, filepath.Join(serverDir, newDirs[i])); err != nil {
+				return changes, fmt.Errorf("ワールド %s の名前の変更に失敗しました: %w", oldDirs[i], err)
+			}
+		}
+	}
+	return changes, nil
+}
+
+// FindDockerComposeService は、files のうち serviceName を定義している最初の docker-compose.yml と、そのサービスを返します。
+func FindDockerComposeService(files []string, serviceName string) (string, DockerComposeService, error) {
+	for _, file := range files {
+		data, err := vfs.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", DockerComposeService{}, fmt.Errorf("docker-compose.ymlの読み込みに失敗しました: %w", err)
+		}
+		var compose DockerCompose
+		if err := yaml.Unmarshal(data, &compose); err != nil {
+			return "", DockerComposeService{}, fmt.Errorf("%s のパースに失敗しました: %w", file, err)
+		}
+		if service, ok := compose.Services[serviceName]; ok {
+			return file, service, nil
+		}
+	}
+	return "", DockerComposeService{}, fmt.Errorf("サービス %s が docker-compose.yml に見つかりません", serviceName)
+}
+
+// CloneDockerComposeService は、srcCompose の srcName のサービスを複製し、ComposePath に dstName として追加します。
+// srcDir の中を指す build.context と volumes は dstDir の中を指すように書き換え、
+// MOTD などサーバー名から付けた環境変数も新しい名前にします。
+func CloneDockerComposeService(srcCompose, srcName, srcDir, dstName, dstDir string) error {
+	_, service, err := FindDockerComposeService([]string{srcCompose}, srcName)
+	if err != nil {
+		return err
+	}
+
+	rebase := func(p string) (string, error) {
+		return rebasePath(p, filepath.Dir(srcCompose), filepath.Dir(ComposePath()), srcDir, dstDir)
+	}
+	if service.Build.Context != "" {
+		if service.Build.Context, err = rebase(service.Build.Context); err != nil {
+			return err
+		}
+	}
+	volumes := make([]string, 0, len(service.Volumes))
+	for _, v := range service.Volumes {
+		host, rest, found := strings.Cut(v, ":")
+		if found && (strings.HasPrefix(host, ".") || strings.HasPrefix(host, "/")) {
+			if host, err = rebase(host); err != nil {
+				return err
+			}
+			v = host + ":" + rest
+		}
+		volumes = append(volumes, v)
+	}
+	service.Volumes = volumes
+	service.ContainerName = ContainerName(dstName)
+	service.Environment = renameEnvironment(service.Environment, srcName, dstName)
+
+	return UpdateDockerCompose(ComposePath(), func(compose *DockerCompose) error {
+		if _, exists := compose.Services[dstName]; exists {
+			return fmt.Errorf("サービス %s はすでに存在します", dstName)
+		}
+		compose.Services[dstName] = service
+		return nil
+	})
+}
+
+// rebasePath は、fromBase からの相対パス p を toBase からの相対パスに直します。
+// p が srcDir の中を指す場合は dstDir の中を指すようにします。
+func rebasePath(p, fromBase, toBase, srcDir, dstDir string) (string, error) {
+	abs := p
+	if !filepath.IsAbs(p) {
+		abs = filepath.Join(fromBase, p)
+	}
+	absFrom, err := filepath.Abs(abs)
+	if err != nil {
+		return "", err
+	}
+	srcAbs, err := filepath.Abs(srcDir)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(srcAbs, absFrom); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+		if absFrom, err = filepath.Abs(filepath.Join(dstDir, rel)); err != nil {
+			return "", err
+		}
+	} else if filepath.IsAbs(p) {
+		return p, nil
+	}
+
+	toAbs, err := filepath.Abs(toBase)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(toAbs, absFrom)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	if !strings.HasPrefix(rel, "../") {
+		rel = "./" + rel
+	}
+	return rel, nil
+}
+
+// renameEnvironment は、配列またはマップ形式の environment の MOTD などの値のサーバー名を置き換えます。
+func renameEnvironment(env interface{}, from, to string) interface{} {
+	rename := func(key, value string) string {
+		for _, k := range nameDerivedEnvironment {
+			if k == key {
+				return ReplaceServerName(value, from, to)
+			}
+		}
+		return value
+	}
+	switch e := env.(type) {
+	case []interface{}:
+		renamed := make([]interface{}, 0, len(e))
+		for _, item := range e {
+			k, v, found := strings.Cut(fmt.Sprint(item), "=")
+			if found {
+				item = k + "=" + rename(k, v)
+			}
+			renamed = append(renamed, item)
+		}
+		return renamed
+	case map[string]interface{}:
+		renamed := make(map[string]interface{}, len(e))
+		for k, v := range e {
+			if s, ok := v.(string); ok {
+				v = rename(k, s)
+			}
+			renamed[k] = v
+		}
+		return renamed
+	}
+	return env
+}
@@ -0,0 +1,25 @@
+package server
+
+import "testing"
+
+func TestReplaceServerName(t *testing.T) {
+	tests := []struct {
+		value, from, to, want string
+	}{
+		{"survival", "survival", "creative", "creative"},
+		{"Survival Server", "survival", "creative", "creative Server"},
+		{"survivalist", "survival", "creative", "survivalist"},
+		{"survival-survival", "survival", "creative", "creative-creative"},
+		{"a survival world, survival2", "survival", "creative", "a creative world, survival2"},
+		// 新しい名前が古い名前を含んでいても、一度だけ置き換える
+		{"survival", "survival", "survival-staging", "survival-staging"},
+		{"survival_world", "survival", "survival-staging", "survival-staging_world"},
+		{"welcome to survival!", "survival", "survival2", "welcome to survival2!"},
+		{"", "survival", "creative", ""},
+	}
+	for _, tt := range tests {
+		if got := ReplaceServerName(tt.value, tt.from, tt.to); got != tt.want {
+			t.Errorf("ReplaceServerName(%q, %q, %q) = %q, want %q", tt.value, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
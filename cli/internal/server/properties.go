@@ -0,0 +1,183 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"mcctl/internal/vfs"
+)
+
+// ReadServerProperties は、サーバーディレクトリの server.properties を読み込みます。
+// コメント行と空行は無視され、値のエスケープ (例: "minecraft\:normal") は解除されます。
+func ReadServerProperties(serverDir string) (map[string]string, error) {
+	data, err := vfs.ReadFile(filepath.Join(serverDir, "server.properties"))
+	if err != nil {
+		return nil, fmt.Errorf("server.propertiesの読み込みに失敗しました: %w", err)
+	}
+	return ParseProperties(data), nil
+}
+
+// ParseProperties parses the contents of a Java .properties file.
+func ParseProperties(data []byte) map[string]string {
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		props[strings.TrimSpace(key)] = strings.ReplaceAll(strings.TrimSpace(value), `\:`, ":")
+	}
+	return props
+}
+
+// ReadDockerfileEnv は、サーバーディレクトリの Dockerfile に書かれた ENV を読み込みます。
+// `ENV KEY="value"` と `ENV KEY value` の両方の形式に対応します。
+func ReadDockerfileEnv(serverDir string) (map[string]string, error) {
+	data, err := vfs.ReadFile(filepath.Join(serverDir, "Dockerfile"))
+	if err != nil {
+		return nil, fmt.Errorf("Dockerfileの読み込みに失敗しました: %w", err)
+	}
+
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) < 4 || !strings.EqualFold(line[:4], "ENV ") {
+			continue
+		}
+		rest := strings.TrimSpace(line[4:])
+		key, value, found := strings.Cut(rest, "=")
+		if !found || strings.ContainsAny(key, " \t") {
+			key, value, _ = strings.Cut(rest, " ")
+		}
+		env[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	return env, nil
+}
+
+// GameAddress は、サーバーのゲーム用アドレス (host:port) を返します。
+// 管理用JSONに登録されていないサーバーは、Compose のサービス名をホスト名とみなします。
+func GameAddress(jsonPath, name string) (string, error) {
+	s, found, err := FindServer(jsonPath, name)
+	if err != nil {
+		return "", err
+	}
+	if !found || s.Address == "" {
+		return name + ":25565", nil
+	}
+	if !strings.Contains(s.Address, ":") {
+		return s.Address + ":25565", nil
+	}
+	return s.Address, nil
+}
+
+// RCONTarget は、サーバーのRCONアドレスとパスワードを返します。
+// ホスト名は管理用JSONのアドレス (なければサーバー名) から、
+// ポートとパスワードは server.properties (なければ Dockerfile の RCON_PASSWORD) から決めます。
+func RCONTarget(jsonPath, name string) (string, string, error) {
+	address, err := GameAddress(jsonPath, name)
+	if err != nil {
+		return "", "", err
+	}
+	host, _, _ := strings.Cut(address, ":")
+
+	serverDir := ServerDirectory(name)
+	props, err := ReadServerProperties(serverDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	port := props["rcon.port"]
+	if port == "" {
+		port = "25575"
+	}
+	password := props["rcon.password"]
+	if password == "" {
+		if env, err := ReadDockerfileEnv(serverDir); err == nil {
+			password = env["RCON_PASSWORD"]
+		}
+	}
+	if password == "" {
+		return "", "", fmt.Errorf("サーバー %s のRCONパスワードが設定されていません", name)
+	}
+	return host + ":" + port, password, nil
+}
+
+// QueryTarget は、サーバーの Query (UDP) アドレスを返します。
+// server.properties で enable-query が有効になっていない場合はエラーを返します。
+func QueryTarget(jsonPath, name string) (string, error) {
+	address, err := GameAddress(jsonPath, name)
+	if err != nil {
+		return "", err
+	}
+	host, _, _ := strings.Cut(address, ":")
+
+	props, err := ReadServerProperties(ServerDirectory(name))
+	if err != nil {
+		return "", err
+	}
+	if props["enable-query"] != "true" {
+		return "", fmt.Errorf("サーバー %s は enable-query が有効になっていません", name)
+	}
+	port := props["query.port"]
+	if port == "" {
+		port = "25565"
+	}
+	return host + ":" + port, nil
+}
+
+// DefaultMinecraftVersion は、サーバータイプの既定の環境変数から VERSION を取り出します。
+func DefaultMinecraftVersion(serverType string) string {
+	impl, err := GetServerType(serverType)
+	if err != nil {
+		return ""
+	}
+	for _, env := range impl.GetEnvironment() {
+		if v, ok := strings.CutPrefix(env, "VERSION="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// RuntimeInfo は、サーバーのタイプ (forge, paper など) と Minecraft のバージョンを返します。
+// バージョンは ComposePath のサービスの VERSION、管理用JSONの mcVersion、サーバーディレクトリの Dockerfile の VERSION の順に探します。
+// 管理用JSONに登録されていないサーバーは、サーバーディレクトリの Dockerfile から推定します。
+func RuntimeInfo(jsonPath, name string) (string, string, error) {
+	s, found, err := FindServer(jsonPath, name)
+	if err != nil {
+		return "", "", err
+	}
+	if found {
+		serverType := strings.ToLower(s.Type)
+		env, err := DockerComposeEnvironment(ComposePath(), name)
+		if err != nil {
+			return "", "", err
+		}
+		if v := env["VERSION"]; v != "" {
+			return serverType, v, nil
+		}
+		if s.MCVersion != "" {
+			return serverType, s.MCVersion, nil
+		}
+		// mcctl adopt で登録したサーバーは、バージョンを自分の Dockerfile に持っている
+		if env, err := ReadDockerfileEnv(ServerDirectory(name)); err == nil && env["VERSION"] != "" {
+			return serverType, env["VERSION"], nil
+		}
+		return serverType, DefaultMinecraftVersion(serverType), nil
+	}
+
+	env, err := ReadDockerfileEnv(ServerDirectory(name))
+	if err != nil {
+		return "", "", fmt.Errorf("サーバー %s が見つかりません: %w", name, err)
+	}
+	return strings.ToLower(env["TYPE"]), env["VERSION"], nil
+}
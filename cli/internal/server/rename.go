@@ -0,0 +1,207 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"mcctl/internal/vfs"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RenameVelocityServer は velocity.toml の中のサーバー名 from を to に変えます。
+// [servers] のキー、forced-hosts の <from>.<domain> と転送先、try (トップレベルと [servers] の中) が対象です。
+// [servers] のアドレスのホストが from の場合は、ホストも to にします。
+// from がどこにもなければファイルを書き換えず、false を返します。
+func RenameVelocityServer(tomlPath, from, to, domain string) (bool, error) {
+	found := false
+	err := UpdateVelocityConfig(tomlPath, func(config map[string]interface{}) error {
+		servers := VelocitySection(config, "servers")
+		if current, ok := servers[from]; ok {
+			if _, exists := servers[to]; exists {
+				return fmt.Errorf("%s の [servers] にはすでに %s があります", tomlPath, to)
+			}
+			delete(servers, from)
+			if address, ok := current.(string); ok {
+				current = RenameAddressHost(address, from, to)
+			}
+			servers[to] = current
+			found = true
+		}
+
+		forcedHosts := VelocitySection(config, "forced-hosts")
+		for host, targets := range forcedHosts {
+			if renamed, ok := renameInList(targets, from, to); ok {
+				forcedHosts[host] = renamed
+				found = true
+			}
+		}
+		if targets, ok := forcedHosts[from+"."+domain]; ok {
+			delete(forcedHosts, from+"."+domain)
+			forcedHosts[to+"."+domain] = targets
+			found = true
+		}
+
+		for _, section := range []map[string]interface{}{config, servers} {
+			if renamed, ok := renameInList(section["try"], from, to); ok {
+				section["try"] = renamed
+				found = true
+			}
+		}
+		if !found {
+			return errUnchanged
+		}
+		return nil
+	})
+	if err == errUnchanged {
+		return false, nil
+	}
+	return found, err
+}
+
+// RenameAddressHost は、アドレス (host:port) のホストが from の場合に to に置き換えます。
+func RenameAddressHost(address, from, to string) string {
+	host, port, found := strings.Cut(address, ":")
+	if host != from {
+		return address
+	}
+	if !found {
+		return to
+	}
+	return to + ":" + port
+}
+
+// errUnchanged は、update が何も変更しなかったことを UpdateVelocityConfig の呼び出し元に伝えます。
+var errUnchanged = errors.New("unchanged")
+
+// renameInList は、TOML の配列 list の中の from を to に置き換えます。
+func renameInList(list interface{}, from, to string) ([]interface{}, bool) {
+	items, ok := list.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	renamed := make([]interface{}, len(items))
+	changed := false
+	for i, item := range items {
+		if item == from {
+			item = to
+			changed = true
+		}
+		renamed[i] = item
+	}
+	return renamed, changed
+}
+
+// RenameDockerComposeService は、composePath のサービス from を to に変えます。
+// サービスのキー、container_name (ContainerName(from) の場合)、fromDir の中を指すパスを書き換えます。
+// サービスの定義の行だけを書き換えるため、コメントや他のサービスの書式は変わりません。
+func RenameDockerComposeService(composePath, from, to, fromDir, toDir string) error {
+	data, err := vfs.ReadFile(composePath)
+	if err != nil {
+		return fmt.Errorf("docker-compose.ymlの読み込みに失敗しました: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s のパースに失敗しました: %w", composePath, err)
+	}
+	if first, _ := ComposeServiceLines(&doc, to); first != 0 {
+		return fmt.Errorf("サービス %s はすでに %s にあります", to, composePath)
+	}
+	first, last := ComposeServiceLines(&doc, from)
+	if first == 0 {
+		return fmt.Errorf("サービス %s が %s に見つかりません", from, composePath)
+	}
+
+	base := filepath.Dir(composePath)
+	oldRel, err := composeRelative(base, fromDir)
+	if err != nil {
+		return err
+	}
+	newRel, err := composeRelative(base, toDir)
+	if err != nil {
+		return err
+	}
+	oldAbs, err := filepath.Abs(fromDir)
+	if err != nil {
+		return err
+	}
+	newAbs, err := filepath.Abs(toDir)
+	if err != nil {
+		return err
+	}
+
+	key := regexp.MustCompile(`^(\s*)(["']?)` + regexp.QuoteMeta(from) + `(["']?\s*:)`)
+	container := regexp.MustCompile(`(container_name:\s*["']?)` + regexp.QuoteMeta(ContainerName(from)) + `(["']?\s*$)`)
+	// パスの区切りまで一致するものだけを置き換える (./servers/large が ./servers/large-paper に一致しないように)。
+	// volumes の "./" や "/" で始まらない値は名前付きボリュームなので、build.context 以外は "./" を必須にする
+	relPath := regexp.MustCompile(`(^|[\s'"])(\./)` + regexp.QuoteMeta(oldRel) + `([/:'"\s]|$)`)
+	if strings.HasPrefix(oldRel, "../") {
+		relPath = regexp.MustCompile(`(^|[\s'"])()` + regexp.QuoteMeta(oldRel) + `([/:'"\s]|$)`)
+	}
+	contextPath := regexp.MustCompile(`(context:\s*["']?)(\./)?` + regexp.QuoteMeta(oldRel) + `(/?["']?\s*$)`)
+	absPath := regexp.MustCompile(`(^|[\s'"])` + regexp.QuoteMeta(oldAbs) + `([/:'"\s]|$)`)
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	lines[first-1] = key.ReplaceAll(lines[first-1], []byte("${1}${2}"+to+"${3}"))
+	for i := first - 1; i < last && i < len(lines); i++ {
+		lines[i] = container.ReplaceAll(lines[i], []byte("${1}"+ContainerName(to)+"${2}"))
+		lines[i] = relPath.ReplaceAll(lines[i], []byte("${1}${2}"+newRel+"${3}"))
+		lines[i] = contextPath.ReplaceAll(lines[i], []byte("${1}${2}"+newRel+"${3}"))
+		lines[i] = absPath.ReplaceAll(lines[i], []byte("${1}"+newAbs+"${2}"))
+	}
+	if err := vfs.WriteFile(composePath, bytes.Join(lines, nil), 0644); err != nil {
+		return fmt.Errorf("docker-compose.ymlの書き込みに失敗しました: %w", err)
+	}
+	return nil
+}
+
+// composeRelative は、docker-compose.yml のあるディレクトリ base から見た dir のパスを "./" を付けずに返します。
+func composeRelative(base, dir string) (string, error) {
+	baseAbs, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	dirAbs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(baseAbs, dirAbs)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(filepath.ToSlash(rel), "./"), nil
+}
+
+// ComposeServiceLines は、docker-compose.yml の services の下の service の定義が始まる行と終わる行 (1 始まり) を返します。
+// 終わりの行は、次のサービスや次のトップレベルのキーの手前です。見つからない場合は 0 を返します。
+func ComposeServiceLines(doc *yaml.Node, service string) (int, int) {
+	if len(doc.Content) == 0 {
+		return 0, 0
+	}
+	root := doc.Content[0]
+	end := int(^uint(0) >> 1)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "services" {
+			continue
+		}
+		if i+2 < len(root.Content) {
+			end = root.Content[i+2].Line - 1
+		}
+		services := root.Content[i+1]
+		for j := 0; j+1 < len(services.Content); j += 2 {
+			if services.Content[j].Value != service {
+				continue
+			}
+			last := end
+			if j+2 < len(services.Content) {
+				last = services.Content[j+2].Line - 1
+			}
+			return services.Content[j].Line, last
+		}
+	}
+	return 0, 0
+}
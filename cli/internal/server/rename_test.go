@@ -0,0 +1,157 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+func TestRenameAddressHost(t *testing.T) {
+	tests := []struct {
+		address, want string
+	}{
+		{"large:25565", "large-paper:25565"},
+		{"large", "large-paper"},
+		{"large-2:25565", "large-2:25565"},
+		{"192.168.0.10:25565", "192.168.0.10:25565"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := RenameAddressHost(tt.address, "large", "large-paper"); got != tt.want {
+			t.Errorf("RenameAddressHost(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestRenameVelocityServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "velocity.toml")
+	os.WriteFile(path, []byte(`try = ['lobby', 'large']
+
+[forced-hosts]
+'large.example.com' = ['large', 'lobby']
+'lobby.example.com' = ['lobby']
+
+[servers]
+large = 'large:25565'
+lobby = 'lobby:25565'
+try = ['large']
+`), 0644)
+
+	changed, err := RenameVelocityServer(path, "large", "large-paper", "example.com")
+	if err != nil || !changed {
+		t.Fatalf("RenameVelocityServer() = %v, %v", changed, err)
+	}
+	var config struct {
+		Try         []string               `toml:"try"`
+		ForcedHosts map[string][]string    `toml:"forced-hosts"`
+		Servers     map[string]interface{} `toml:"servers"`
+	}
+	data, _ := os.ReadFile(path)
+	if err := toml.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"lobby", "large-paper"}; !reflect.DeepEqual(config.Try, want) {
+		t.Errorf("try = %v, want %v", config.Try, want)
+	}
+	wantHosts := map[string][]string{
+		"large-paper.example.com": {"large-paper", "lobby"},
+		"lobby.example.com":       {"lobby"},
+	}
+	if !reflect.DeepEqual(config.ForcedHosts, wantHosts) {
+		t.Errorf("forced-hosts = %v, want %v", config.ForcedHosts, wantHosts)
+	}
+	wantServers := map[string]interface{}{
+		"large-paper": "large-paper:25565",
+		"lobby":       "lobby:25565",
+		"try":         []interface{}{"large-paper"},
+	}
+	if !reflect.DeepEqual(config.Servers, wantServers) {
+		t.Errorf("servers = %v, want %v", config.Servers, wantServers)
+	}
+
+	// もう large はないので変更しない
+	if changed, err := RenameVelocityServer(path, "large", "large-paper", "example.com"); err != nil || changed {
+		t.Errorf("2回目の RenameVelocityServer() = %v, %v, want false", changed, err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(data) {
+		t.Error("変更がないのに velocity.toml が書き換えられました")
+	}
+	// 変更先の名前がすでにある
+	if _, err := RenameVelocityServer(path, "lobby", "large-paper", "example.com"); err == nil {
+		t.Error("[servers] にある名前への変更が成功しました")
+	}
+}
+
+func TestRenameDockerComposeService(t *testing.T) {
+	root := t.TempDir()
+	composePath := filepath.Join(root, "docker-compose.yml")
+	oldDir := filepath.Join(root, "minecraft", "servers", "large")
+	newDir := filepath.Join(root, "minecraft", "servers", "large-paper")
+
+	const compose = `# 手で書いたコメント
+services:
+  large:
+    build:
+      context: ./minecraft/servers/large
+    container_name: minecraft-large-server
+    volumes:
+      - ./minecraft/servers/large/data:/data
+      - ./minecraft/servers/large-2/data:/backup
+      - large-cache:/cache
+  large-2:
+    container_name: minecraft-large-2-server
+    volumes:
+      - ./minecraft/servers/large-2/data:/data
+
+volumes:
+  large-cache:
+`
+	os.WriteFile(composePath, []byte(compose), 0644)
+
+	if err := RenameDockerComposeService(composePath, "large", "large-paper", oldDir, newDir); err != nil {
+		t.Fatal(err)
+	}
+	want := `# 手で書いたコメント
+services:
+  large-paper:
+    build:
+      context: ./minecraft/servers/large-paper
+    container_name: minecraft-large-paper-server
+    volumes:
+      - ./minecraft/servers/large-paper/data:/data
+      - ./minecraft/servers/large-2/data:/backup
+      - large-cache:/cache
+  large-2:
+    container_name: minecraft-large-2-server
+    volumes:
+      - ./minecraft/servers/large-2/data:/data
+
+volumes:
+  large-cache:
+`
+	if got, _ := os.ReadFile(composePath); string(got) != want {
+		t.Errorf("docker-compose.yml:\n%s\nwant:\n%s", got, want)
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+	}{
+		{"変更先のサービスがある", "large-paper", "large-2"},
+		{"変更元のサービスがない", "large", "large-3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := os.ReadFile(composePath)
+			if err := RenameDockerComposeService(composePath, tt.from, tt.to, oldDir, newDir); err == nil {
+				t.Error("エラーになりませんでした")
+			}
+			if after, _ := os.ReadFile(composePath); string(after) != string(before) {
+				t.Error("失敗したのに docker-compose.yml が書き換えられました")
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"mcctl/internal/vfs"
+)
+
+// ServersSchemaVersion は、mcctl が書き込む servers.json のスキーマのバージョンです。
+//
+//   - 1: サーバーの配列。サーバータイプを version に保存していた
+//   - 2: {"schemaVersion": 2, "servers": [...]}。サーバータイプは type に保存する
+const ServersSchemaVersion = 2
+
+// ServersFile は servers.json の内容です。
+type ServersFile struct {
+	SchemaVersion int      `json:"schemaVersion"`
+	Servers       []Server `json:"servers"`
+}
+
+// SetRuntime は、環境変数 (VERSION、MEMORY、FORGE_VERSION など) から MCVersion、LoaderVersion、Memory を設定します。
+// 値のない項目は変更しません。
+func (s *Server) SetRuntime(env map[string]string) {
+	if v := env["VERSION"]; v != "" {
+		s.MCVersion = v
+	}
+	for _, key := range []string{"FORGE_VERSION", "NEOFORGE_VERSION", "FABRIC_LOADER_VERSION"} {
+		if v := env[key]; v != "" {
+			s.LoaderVersion = v
+			break
+		}
+	}
+	for _, key := range []string{"MEMORY", "MAX_MEMORY"} {
+		if v := env[key]; v != "" {
+			s.Memory = v
+			break
+		}
+	}
+}
+
+// serversMigrations[i] は、スキーマ i+1 の servers.json をスキーマ i+2 に変換します。
+// スキーマを変えるときは、ServersSchemaVersion を上げてここに変換を追加します。
+var serversMigrations = []func(data []byte) ([]byte, error){
+	migrateServersV1,
+}
+
+// migrateServersV1 は、サーバーの配列をオブジェクトで包み、version を type に移します。
+func migrateServersV1(data []byte) ([]byte, error) {
+	var servers []map[string]interface{}
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return nil, err
+	}
+	for _, s := range servers {
+		if v, ok := s["version"]; ok {
+			s["type"] = v
+			delete(s, "version")
+		}
+	}
+	if servers == nil {
+		servers = []map[string]interface{}{}
+	}
+	return json.Marshal(map[string]interface{}{"schemaVersion": 2, "servers": servers})
+}
+
+// serversSchemaVersion は data のスキーマのバージョンを返します。配列はスキーマ 1 です。
+func serversSchemaVersion(data []byte) (int, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] == '[' {
+		return 1, nil
+	}
+	var header struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return 0, err
+	}
+	if header.SchemaVersion < 2 {
+		return 0, fmt.Errorf("schemaVersion がありません")
+	}
+	return header.SchemaVersion, nil
+}
+
+// decodeServersFile は、data を最新のスキーマに変換して読み込みます。
+// 変換する前のスキーマのバージョンも返します。
+func decodeServersFile(data []byte) (ServersFile, int, error) {
+	from, err := serversSchemaVersion(data)
+	if err != nil {
+		return ServersFile{}, 0, fmt.Errorf("管理用JSONのパースに失敗しました: %w", err)
+	}
+	if from > ServersSchemaVersion {
+		return ServersFile{}, 0, fmt.Errorf("管理用JSONのスキーマ %d はこの mcctl が対応している %d より新しいです。mcctl を更新してください", from, ServersSchemaVersion)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = []byte("[]")
+	}
+	for v := from; v < ServersSchemaVersion; v++ {
+		data, err = serversMigrations[v-1](data)
+		if err != nil {
+			return ServersFile{}, 0, fmt.Errorf("管理用JSONをスキーマ %d から %d に変換できませんでした: %w", v, v+1, err)
+		}
+	}
+
+	var doc ServersFile
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return ServersFile{}, 0, fmt.Errorf("管理用JSONのパースに失敗しました: %w", err)
+	}
+	return doc, from, nil
+}
+
+// backupOldServersFile は、jsonPath が古いスキーマであれば、書き換える前の内容を <jsonPath>.v<from>.bak に残し、そのパスを返します。
+// ファイルがないか、すでに最新の場合は何もせず、空のパスを返します。
+func backupOldServersFile(jsonPath string) (string, error) {
+	data, err := vfs.ReadFile(jsonPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("管理用JSONの読み込みに失敗しました: %w", err)
+	}
+	from, err := serversSchemaVersion(data)
+	if err != nil || from >= ServersSchemaVersion {
+		// 読めないファイルや新しいスキーマのファイルは、LoadServers の時点でエラーになっている
+		return "", nil
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", jsonPath, from)
+	if _, err := vfs.Stat(backup); err == nil {
+		// 以前のバックアップは上書きしない
+		backup = fmt.Sprintf("%s.v%d.%s.bak", jsonPath, from, time.Now().Format("20060102-150405"))
+	}
+	if err := vfs.WriteFile(backup, data, 0644); err != nil {
+		return "", fmt.Errorf("バックアップ %s の作成に失敗しました: %w", backup, err)
+	}
+	return backup, nil
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const serversV1 = `[
+  {"name": "survival", "version": "forge", "address": "survival:25565"},
+  {"name": "lobby", "version": "paper", "address": "lobby:25565"}
+]
+`
+
+func TestDecodeServersFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		from int
+		want []Server
+	}{
+		{"v1", serversV1, 1, []Server{
+			{Name: "survival", Type: "forge", Address: "survival:25565"},
+			{Name: "lobby", Type: "paper", Address: "lobby:25565"},
+		}},
+		{"v1 empty array", "[]", 1, []Server{}},
+		{"empty file", "  \n", 1, []Server{}},
+		{"v2", `{"schemaVersion": 2, "servers": [{"name": "survival", "type": "forge", "mcVersion": "1.20.1", "address": "survival:25565"}]}`, 2, []Server{
+			{Name: "survival", Type: "forge", MCVersion: "1.20.1", Address: "survival:25565"},
+		}},
+	}
+	for _, tt := range tests {
+		doc, from, err := decodeServersFile([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if from != tt.from || doc.SchemaVersion != ServersSchemaVersion || !reflect.DeepEqual(doc.Servers, tt.want) {
+			t.Errorf("%s: decodeServersFile() = %+v, %d, want %+v, %d", tt.name, doc, from, tt.want, tt.from)
+		}
+	}
+
+	for _, data := range []string{`{"servers": []}`, `{"schemaVersion": 3, "servers": []}`, `{`} {
+		if _, _, err := decodeServersFile([]byte(data)); err == nil {
+			t.Errorf("decodeServersFile(%s) にエラーがありません", data)
+		}
+	}
+}
+
+func TestLoadServersDoesNotRewriteV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "servers.json")
+	os.WriteFile(path, []byte(serversV1), 0644)
+
+	servers, err := LoadServers(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 2 || servers[0].Type != "forge" {
+		t.Errorf("LoadServers() = %+v", servers)
+	}
+	if data, _ := os.ReadFile(path); string(data) != serversV1 {
+		t.Errorf("LoadServers() が servers.json を書き換えました:\n%s", data)
+	}
+	if _, err := os.Stat(path + ".v1.bak"); !os.IsNotExist(err) {
+		t.Errorf("LoadServers() がバックアップを作成しました")
+	}
+}
+
+func TestSaveServersMigratesV1(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "servers.json")
+	os.WriteFile(path, []byte(serversV1), 0644)
+
+	servers, err := LoadServers(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveServers(path, servers[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(path + ".v1.bak"); string(data) != serversV1 {
+		t.Errorf("servers.json.v1.bak = %q", data)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"schemaVersion": 2`) || !strings.Contains(string(data), `"type": "forge"`) || strings.Contains(string(data), `"version"`) {
+		t.Errorf("servers.json =\n%s", data)
+	}
+
+	// 2回目の保存はすでにスキーマ 2 なので、バックアップを作らない
+	if err := SaveServers(path, servers); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("バックアップが増えています: %v", entries)
+	}
+
+	// 以前のバックアップは上書きしない
+	os.WriteFile(path, []byte(serversV1), 0644)
+	backup, err := backupOldServersFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup == path+".v1.bak" || !strings.HasPrefix(backup, path+".v1.") {
+		t.Errorf("backupOldServersFile() = %s", backup)
+	}
+}
@@ -4,12 +4,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"time"
+
+	"mcctl/internal/vfs"
 
 	"github.com/pelletier/go-toml/v2"
 	"gopkg.in/yaml.v3"
 )
 
+// MinecraftDir は docker-compose.yml、servers/、template/ を含むディレクトリです。
+// mcctl.yaml の paths.minecraft で変更できます。
+var MinecraftDir = "minecraft"
+
+// ComposePath returns the docker-compose.yml that holds the Minecraft server services.
+func ComposePath() string {
+	return MinecraftDir + "/docker-compose.yml"
+}
+
 // ServerTypeInterface defines the interface for different server types
 type ServerTypeInterface interface {
 	GetEnvironment() []string
@@ -19,6 +34,22 @@ type ServerTypeInterface interface {
 	GetTemplateFiles() []string
 }
 
+// PaperCompatible は、Paper の独自コマンド (tps, mspt) が使えるサーバータイプが実装します。
+// ServerTypeInterface に含めないのは、実装しないタイプを false として扱うためです。
+type PaperCompatible interface {
+	PaperCompatible() bool
+}
+
+// IsPaperCompatible reports whether servers of serverType accept Paper's commands.
+func IsPaperCompatible(serverType string) bool {
+	impl, err := GetServerType(serverType)
+	if err != nil {
+		return false
+	}
+	p, ok := impl.(PaperCompatible)
+	return ok && p.PaperCompatible()
+}
+
 // ServerTypeFactory manages server type creation
 type ServerTypeFactory struct {
 	types map[string]func() ServerTypeInterface
@@ -34,8 +65,17 @@ func RegisterServerType(name string, factory func() ServerTypeInterface) {
 	globalFactory.types[strings.ToLower(name)] = factory
 }
 
-// GetServerType returns the appropriate ServerTypeInterface implementation
+// GetServerType returns the appropriate ServerTypeInterface implementation.
+// <TemplateRoot>/<type>/type.yaml があればその定義を使い、なければ組み込みのタイプを使います。
 func GetServerType(serverType string) (ServerTypeInterface, error) {
+	impl, err := loadYAMLServerType(serverType)
+	if err != nil {
+		return nil, err
+	}
+	if impl != nil {
+		return impl, nil
+	}
+
 	factory, exists := globalFactory.types[strings.ToLower(serverType)]
 	if !exists {
 		return nil, fmt.Errorf("サポートされていないサーバータイプ: %s", serverType)
@@ -43,53 +83,193 @@ func GetServerType(serverType string) (ServerTypeInterface, error) {
 	return factory(), nil
 }
 
-// GetRegisteredTypes returns all registered server type names
+// GetRegisteredTypes returns all server type names, built-in and type.yaml, sorted by name
 func GetRegisteredTypes() []string {
-	types := make([]string, 0, len(globalFactory.types))
-	for name := range globalFactory.types {
-		types = append(types, name)
+	infos := ListServerTypes()
+	types := make([]string, 0, len(infos))
+	for _, info := range infos {
+		types = append(types, info.Name)
 	}
 	return types
 }
 
 // Server は、管理用のJSONファイルに保存するサーバー情報の構造体です。
 type Server struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
-	Address string `json:"address"` // 例: "myserver:25565"
+	Name string `json:"name"`
+	// Type はサーバータイプ (forge, paper など) です。スキーマ 1 では version という名前でした。
+	Type string `json:"type"`
+	// MCVersion は作成したとき (mcctl upgrade で変更した場合はそのとき) の Minecraft のバージョンです。
+	// 実際に使われるのはサービスの VERSION で、RuntimeInfo はそちらを優先します。
+	MCVersion string `json:"mcVersion,omitempty"`
+	// LoaderVersion は Forge や Fabric ローダーのバージョンです。
+	LoaderVersion string `json:"loaderVersion,omitempty"`
+	// Memory はサーバーに割り当てるメモリ (例: "4G") です。
+	Memory    string     `json:"memory,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	Address   string     `json:"address"` // 例: "myserver:25565"
+	// Labels は、サーバーを分類するための自由なラベルです。
+	Labels map[string]string `json:"labels,omitempty"`
+	// Proxies は、サーバーを登録したプロキシの名前 (mcctl.yaml の proxies[].name) です。
+	Proxies []string `json:"proxies,omitempty"`
+	// Alerts は、アラートルールのしきい値をサーバーごとに上書きします。
+	Alerts *AlertThresholds `json:"alerts,omitempty"`
 }
 
-// SaveServerConfig は、管理用JSONファイル（例: servers.json）にサーバー情報を保存します。
-// この関数は velocity.toml とは無関係で、問題なく動作します。
-func SaveServerConfig(jsonPath string, s Server) error {
-	var servers []Server
+// AlertThresholds は `mcctl alerts generate` が使うしきい値です。
+// ゼロ値の項目は既定値が使われます。
+type AlertThresholds struct {
+	DownFor     string  `json:"downFor,omitempty"`     // 停止とみなすまでの時間 (例: "2m")
+	MinTPS      float64 `json:"minTps,omitempty"`      // これを下回るとTPS低下とみなす
+	MemoryRatio float64 `json:"memoryRatio,omitempty"` // コンテナのメモリ上限に対する使用率
+	MaxRestarts int     `json:"maxRestarts,omitempty"` // 15分間の再起動回数の上限
+}
+
+// LoadServers は、管理用JSONファイルからサーバー一覧を読み込みます。
+// ファイルが存在しない場合は空の一覧を返します。
+// 古いスキーマのファイルはメモリ上で変換して読み込みます。ファイル自体は SaveServers で書き込むときに変換します。
+func LoadServers(jsonPath string) ([]Server, error) {
+	data, err := vfs.ReadFile(jsonPath)
+	if err != nil {
+		// ファイルが存在しないエラー以外は、予期せぬエラーとして返す
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("管理用JSONの読み込みに失敗しました: %w", err)
+	}
 
-	data, err := os.ReadFile(jsonPath)
 	// ファイルが存在する場合、既存のデータを読み込む
-	if err == nil {
-		if err := json.Unmarshal(data, &servers); err != nil {
-			return fmt.Errorf("管理用JSONのパースに失敗しました: %w", err)
+	doc, _, err := decodeServersFile(data)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Servers, nil
+}
+
+// FindServer は、管理用JSONファイルから名前が一致するサーバーを探します。
+func FindServer(jsonPath, name string) (Server, bool, error) {
+	servers, err := LoadServers(jsonPath)
+	if err != nil {
+		return Server{}, false, err
+	}
+	for _, s := range servers {
+		if s.Name == name {
+			return s, true, nil
 		}
-		// ファイルが存在しないエラー以外は、予期せぬエラーとして返す
-	} else if !os.IsNotExist(err) {
-		return fmt.Errorf("管理用JSONの読み込みに失敗しました: %w", err)
+	}
+	return Server{}, false, nil
+}
+
+// SaveServerConfig は、管理用JSONファイル（例: servers.json）にサーバー情報を保存します。
+// この関数は velocity.toml とは無関係で、問題なく動作します。
+func SaveServerConfig(jsonPath string, s Server) error {
+	servers, err := LoadServers(jsonPath)
+	if err != nil {
+		return err
 	}
 
 	// 新しいサーバー情報をスライスに追加
 	servers = append(servers, s)
+	return SaveServers(jsonPath, servers)
+}
 
+// SaveServers は、管理用JSONファイルをサーバー一覧で置き換えます。常に最新のスキーマで書き込みます。
+// 既存のファイルが古いスキーマの場合は、変更前の内容を <jsonPath>.v<from>.bak に残します。
+func SaveServers(jsonPath string, servers []Server) error {
+	if _, err := backupOldServersFile(jsonPath); err != nil {
+		return err
+	}
+	if servers == nil {
+		servers = []Server{}
+	}
 	// 整形したJSON形式で書き出す
-	updated, err := json.MarshalIndent(servers, "", "  ")
+	updated, err := json.MarshalIndent(ServersFile{SchemaVersion: ServersSchemaVersion, Servers: servers}, "", "  ")
 	if err != nil {
 		return fmt.Errorf("JSONへのエンコードに失敗しました: %w", err)
 	}
 
-	return os.WriteFile(jsonPath, updated, 0644)
+	return vfs.WriteFile(jsonPath, append(updated, '\n'), 0644)
+}
+
+// AddVelocityServerConfig は velocity.toml の servers と forced-hosts (<name>.<domain>) にサーバーを登録します。
+func AddVelocityServerConfig(tomlPath, serverName, address, domain string) error {
+	return UpdateVelocityConfig(tomlPath, func(config map[string]interface{}) error {
+		// 'servers' セクションを安全に取得・更新する
+		VelocitySection(config, "servers")[serverName] = address
+
+		// 'forced-hosts' セクションを安全に取得・更新する
+		//    TOMLのキーはハイフンを含む "forced-hosts" なので注意
+		VelocitySection(config, "forced-hosts")[serverName+"."+domain] = []string{serverName}
+		return nil
+	})
+}
+
+// AddVelocityFallback は、velocity.toml の [servers] に fallback のアドレスを登録し、
+// forced-hosts と try の中で serverName の直後に fallback を追加します。
+// Velocity は serverName に接続できないとき、リストの次のサーバーに接続します。
+// serverName が [servers] にないか、すでに登録済みの場合はファイルを書き換えず、false を返します。
+func AddVelocityFallback(tomlPath, serverName, fallback, address string) (bool, error) {
+	changed := false
+	err := UpdateVelocityConfig(tomlPath, func(config map[string]interface{}) error {
+		servers := VelocitySection(config, "servers")
+		if _, ok := servers[serverName]; !ok {
+			return errUnchanged
+		}
+		if current, _ := servers[fallback].(string); current != address {
+			servers[fallback] = address
+			changed = true
+		}
+
+		forcedHosts := VelocitySection(config, "forced-hosts")
+		for host, targets := range forcedHosts {
+			if list, ok := insertAfter(targets, serverName, fallback); ok {
+				forcedHosts[host] = list
+				changed = true
+			}
+		}
+		for _, section := range []map[string]interface{}{config, servers} {
+			if list, ok := insertAfter(section["try"], serverName, fallback); ok {
+				section["try"] = list
+				changed = true
+			}
+		}
+		if !changed {
+			return errUnchanged
+		}
+		return nil
+	})
+	if err == errUnchanged {
+		return false, nil
+	}
+	return changed, err
+}
+
+// insertAfter は、TOML の配列 list に after があり item がない場合に、after の直後に item を挿入します。
+func insertAfter(list interface{}, after, item string) ([]interface{}, bool) {
+	items, ok := list.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	at := -1
+	for i, v := range items {
+		if v == item {
+			return nil, false
+		}
+		if v == after {
+			at = i
+		}
+	}
+	if at < 0 {
+		return nil, false
+	}
+	inserted := append(append(append([]interface{}{}, items[:at+1]...), item), items[at+1:]...)
+	return inserted, true
 }
 
-func AddVelocityServerConfig(tomlPath, serverName, address string) error {
+// UpdateVelocityConfig は velocity.toml を汎用的なマップとして読み込み、update で変更して書き戻します。
+// ファイルが存在しない場合は空の設定から作成します。
+func UpdateVelocityConfig(tomlPath string, update func(config map[string]interface{}) error) error {
 	// 1. velocity.toml を読み込む
-	content, err := os.ReadFile(tomlPath)
+	content, err := vfs.ReadFile(tomlPath)
 	if err != nil {
 		// ファイルが存在しない場合はエラーとせず、空の内容として新規作成フローに進む
 		if !os.IsNotExist(err) {
@@ -104,45 +284,39 @@ func AddVelocityServerConfig(tomlPath, serverName, address string) error {
 		// このデコードが失敗する場合、TOMLの構文自体に問題がある可能性が高い
 		return fmt.Errorf("TOMLのパースに失敗しました: %w", err)
 	}
-
-	// 3. 'servers' セクションを安全に取得・更新する
-	var servers map[string]interface{}
-	if serversRaw, exists := config["servers"]; exists {
-		// 型アサーションでマップに変換
-		servers, _ = serversRaw.(map[string]interface{})
+	if config == nil {
+		config = make(map[string]interface{})
 	}
-	if servers == nil { // セクションが存在しないか、型が違う場合は新規作成
-		servers = make(map[string]interface{})
-		config["servers"] = servers
-	}
-	servers[serverName] = address
 
-	// 4. 'forced-hosts' セクションを安全に取得・更新する
-	//    TOMLのキーはハイフンを含む "forced-hosts" なので注意
-	var forcedHosts map[string]interface{}
-	if forcedHostsRaw, exists := config["forced-hosts"]; exists {
-		forcedHosts, _ = forcedHostsRaw.(map[string]interface{})
-	}
-	if forcedHosts == nil { // セクションが存在しないか、型が違う場合は新規作成
-		forcedHosts = make(map[string]interface{})
-		config["forced-hosts"] = forcedHosts
+	// 3. 呼び出し元で servers や forced-hosts を更新する
+	if err := update(config); err != nil {
+		return err
 	}
-	// 新しいforced-hostを追加
-	forcedHosts[serverName+".example.com"] = []string{serverName}
 
-	// 5. 更新したマップをTOML形式に変換してファイルに書き込む
+	// 4. 更新したマップをTOML形式に変換してファイルに書き込む
 	updatedContent, err := toml.Marshal(config)
 	if err != nil {
 		return fmt.Errorf("TOMLへのエンコードに失敗しました: %w", err)
 	}
 
-	if err := os.WriteFile(tomlPath, updatedContent, 0644); err != nil {
+	if err := vfs.WriteFile(tomlPath, updatedContent, 0644); err != nil {
 		return fmt.Errorf("velocity.tomlへの書き込みに失敗しました: %w", err)
 	}
 
 	return nil
 }
 
+// VelocitySection は config のテーブル name を返します。
+// セクションが存在しないか、型が違う場合は新規作成します。
+func VelocitySection(config map[string]interface{}, name string) map[string]interface{} {
+	section, _ := config[name].(map[string]interface{})
+	if section == nil {
+		section = make(map[string]interface{})
+		config[name] = section
+	}
+	return section
+}
+
 // DockerComposeService represents a service in docker-compose.yml
 type DockerComposeService struct {
 	Build struct {
@@ -159,26 +333,147 @@ type DockerComposeService struct {
 	StdinOpen     bool        `yaml:"stdin_open,omitempty"`
 }
 
+// ContainerName returns the container_name mcctl assigns to a server's service.
+// cAdvisor のメトリクスの name ラベルもこの値になります。
+func ContainerName(serverName string) string {
+	return fmt.Sprintf("minecraft-%s-server", serverName)
+}
+
+// ServiceContainerName は、files のうち serviceName を定義している docker-compose.yml での、サービスのコンテナ名を返します。
+// container_name がない場合は docker compose の既定の名前 (<プロジェクト名>-<サービス名>-1) です。
+// adopt したサーバーや手作業で作ったサーバーは ContainerName(serviceName) と違う名前のことがあります。
+// サービスが見つからない場合は ContainerName(serviceName) を返します。
+func ServiceContainerName(files []string, serviceName string) string {
+	for _, file := range files {
+		data, err := vfs.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var compose DockerCompose
+		if err := yaml.Unmarshal(data, &compose); err != nil {
+			continue
+		}
+		service, ok := compose.Services[serviceName]
+		if !ok {
+			continue
+		}
+		if service.ContainerName != "" {
+			return service.ContainerName
+		}
+		project := compose.Name
+		if project == "" {
+			dir, err := filepath.Abs(filepath.Dir(file))
+			if err != nil {
+				continue
+			}
+			project = composeProjectName(filepath.Base(dir))
+		}
+		return fmt.Sprintf("%s-%s-1", project, serviceName)
+	}
+	return ContainerName(serviceName)
+}
+
+// composeProjectName は、docker compose と同じく、ディレクトリ名を小文字にして英数字、- と _ 以外を除きます。
+func composeProjectName(dir string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, dir)
+}
+
 // DockerCompose represents the structure of docker-compose.yml
 type DockerCompose struct {
-	Version  string                          `yaml:"version"`
+	Version  string                          `yaml:"version,omitempty"`
+	Name     string                          `yaml:"name,omitempty"`
 	Services map[string]DockerComposeService `yaml:"services"`
 	Networks map[string]interface{}          `yaml:"networks,omitempty"`
 	Volumes  map[string]interface{}          `yaml:"volumes,omitempty"`
 }
 
+// HasDockerComposeService reports whether serviceName is defined in the given docker-compose.yml.
+func HasDockerComposeService(dockerComposePath, serviceName string) (bool, error) {
+	data, err := vfs.ReadFile(dockerComposePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("docker-compose.ymlの読み込みに失敗しました: %w", err)
+	}
+	var compose DockerCompose
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return false, fmt.Errorf("docker-compose.ymlのパースに失敗しました: %w", err)
+	}
+	_, ok := compose.Services[serviceName]
+	return ok, nil
+}
+
+// ServiceOptions は、サーバータイプの既定値に加えてサービスに設定する値です。
+type ServiceOptions struct {
+	// Environment は "KEY=value" 形式で、同じキーの既定値を上書きします。
+	Environment []string
+	// Volumes はサーバータイプの既定のボリュームの後に追加されます。
+	Volumes []string
+}
+
 // AddDockerComposeService adds a new Minecraft server service to docker-compose.yml
 func AddDockerComposeService(dockerComposePath, serverName, serverType string) error {
+	return AddDockerComposeServiceWithOptions(dockerComposePath, serverName, serverType, ServiceOptions{})
+}
+
+// AddDockerComposeServiceWithOptions adds a service like AddDockerComposeService,
+// applying opts on top of the server type defaults.
+func AddDockerComposeServiceWithOptions(dockerComposePath, serverName, serverType string, opts ServiceOptions) error {
+	// Create new service using the interface
+	newService, err := NewDockerComposeService(serverName, serverType, opts)
+	if err != nil {
+		return err
+	}
+
+	return UpdateDockerCompose(dockerComposePath, func(compose *DockerCompose) error {
+		// Add new service to compose
+		compose.Services[serverName] = newService
+		return nil
+	})
+}
+
+// NewDockerComposeService returns the service mcctl generates for a server of serverType.
+func NewDockerComposeService(serverName, serverType string, opts ServiceOptions) (DockerComposeService, error) {
 	// Get the appropriate server type implementation
 	serverTypeImpl, err := GetServerType(serverType)
 	if err != nil {
-		return err
+		return DockerComposeService{}, err
 	}
 
+	return DockerComposeService{
+		Build: struct {
+			Context    string `yaml:"context"`
+			Dockerfile string `yaml:"dockerfile"`
+		}{
+			Context:    serverTypeImpl.GetTemplatePath(),
+			Dockerfile: "Dockerfile",
+		},
+		ContainerName: ContainerName(serverName),
+		Environment:   mergeEnvironment(serverTypeImpl.GetEnvironment(), opts.Environment),
+		Volumes:       append(serverTypeImpl.GetVolumes(serverName), opts.Volumes...),
+		Networks:      []string{"home-network"},
+		Restart:       "unless-stopped",
+		TTY:           true,
+		StdinOpen:     true,
+	}, nil
+}
+
+// UpdateDockerCompose reads docker-compose.yml, lets update modify it and writes it back.
+// The file is created with the home-network network if it doesn't exist.
+func UpdateDockerCompose(dockerComposePath string, update func(compose *DockerCompose) error) error {
 	// Read existing docker-compose.yml
 	var compose DockerCompose
 
-	data, err := os.ReadFile(dockerComposePath)
+	data, err := vfs.ReadFile(dockerComposePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Create basic docker-compose.yml structure if it doesn't exist
@@ -205,27 +500,10 @@ func AddDockerComposeService(dockerComposePath, serverName, serverType string) e
 		compose.Services = make(map[string]DockerComposeService)
 	}
 
-	// Create new service using the interface
-	newService := DockerComposeService{
-		Build: struct {
-			Context    string `yaml:"context"`
-			Dockerfile string `yaml:"dockerfile"`
-		}{
-			Context:    serverTypeImpl.GetTemplatePath(),
-			Dockerfile: "Dockerfile",
-		},
-		ContainerName: fmt.Sprintf("minecraft-%s-server", serverName),
-		Environment:   serverTypeImpl.GetEnvironment(),
-		Volumes:       serverTypeImpl.GetVolumes(serverName),
-		Networks:      []string{"home-network"},
-		Restart:       "unless-stopped",
-		TTY:           true,
-		StdinOpen:     true,
+	if err := update(&compose); err != nil {
+		return err
 	}
 
-	// Add new service to compose
-	compose.Services[serverName] = newService
-
 	// Marshal back to YAML
 	updatedData, err := yaml.Marshal(&compose)
 	if err != nil {
@@ -233,56 +511,206 @@ func AddDockerComposeService(dockerComposePath, serverName, serverType string) e
 	}
 
 	// Write back to file
-	if err := os.WriteFile(dockerComposePath, updatedData, 0644); err != nil {
+	if err := vfs.WriteFile(dockerComposePath, updatedData, 0644); err != nil {
 		return fmt.Errorf("docker-compose.ymlの書き込みに失敗しました: %w", err)
 	}
 
 	return nil
 }
 
-// CreateServerDirectory creates the server directory structure and copies template files
-func CreateServerDirectory(serverName, serverType string) error {
+// ServiceEnvironment は、サーバータイプの既定値に opts を重ねたサービスの環境変数を返します。
+func ServiceEnvironment(serverType string, opts ServiceOptions) (map[string]string, error) {
+	serverTypeImpl, err := GetServerType(serverType)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	for _, e := range mergeEnvironment(serverTypeImpl.GetEnvironment(), opts.Environment) {
+		k, v, _ := strings.Cut(e, "=")
+		env[k] = v
+	}
+	return env, nil
+}
+
+// mergeEnvironment overrides entries of base with entries of overrides that have the same key.
+func mergeEnvironment(base, overrides []string) []string {
+	merged := append([]string(nil), base...)
+	for _, env := range overrides {
+		key, _, _ := strings.Cut(env, "=")
+		replaced := false
+		for i, b := range merged {
+			if k, _, _ := strings.Cut(b, "="); k == key {
+				merged[i] = env
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, env)
+		}
+	}
+	return merged
+}
+
+// DockerComposeEnvironment は、サービスの environment をマップとして返します。
+// サービスが存在しない場合は nil を返します。
+func DockerComposeEnvironment(dockerComposePath, serviceName string) (map[string]string, error) {
+	data, err := vfs.ReadFile(dockerComposePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("docker-compose.ymlの読み込みに失敗しました: %w", err)
+	}
+	var compose DockerCompose
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil, fmt.Errorf("docker-compose.ymlのパースに失敗しました: %w", err)
+	}
+	service, ok := compose.Services[serviceName]
+	if !ok {
+		return nil, nil
+	}
+
+	env := make(map[string]string)
+	switch e := service.Environment.(type) {
+	case []interface{}:
+		for _, item := range e {
+			k, v, _ := strings.Cut(fmt.Sprint(item), "=")
+			env[k] = v
+		}
+	case map[string]interface{}:
+		for k, v := range e {
+			env[k] = fmt.Sprint(v)
+		}
+	}
+	return env, nil
+}
+
+// SetDockerComposeEnvironment は、サービスの environment の set のキーを置き換え (なければ追加し)、unset のキーを削除します。
+// 変更する前の値を返します。設定されていなかったキーの値は空です。
+func SetDockerComposeEnvironment(dockerComposePath, serviceName string, set map[string]string, unset []string) (map[string]string, error) {
+	previous := make(map[string]string)
+	err := UpdateDockerCompose(dockerComposePath, func(compose *DockerCompose) error {
+		service, ok := compose.Services[serviceName]
+		if !ok {
+			return fmt.Errorf("サービス %s が %s に見つかりません", serviceName, dockerComposePath)
+		}
+		changed := func(key string) bool {
+			_, ok := set[key]
+			return ok || slices.Contains(unset, key)
+		}
+
+		switch e := service.Environment.(type) {
+		case map[string]interface{}:
+			for key := range e {
+				if changed(key) {
+					previous[key] = fmt.Sprint(e[key])
+					delete(e, key)
+				}
+			}
+			for key, value := range set {
+				e[key] = value
+			}
+		default:
+			var env []interface{}
+			if list, ok := e.([]interface{}); ok {
+				env = list
+			}
+			kept := make([]interface{}, 0, len(env)+len(set))
+			replaced := make(map[string]bool)
+			for _, item := range env {
+				key, value, _ := strings.Cut(fmt.Sprint(item), "=")
+				if changed(key) {
+					previous[key] = value
+					if v, ok := set[key]; ok && !replaced[key] {
+						kept = append(kept, key+"="+v)
+						replaced[key] = true
+					}
+					continue
+				}
+				kept = append(kept, item)
+			}
+			keys := make([]string, 0, len(set))
+			for key := range set {
+				if !replaced[key] {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				kept = append(kept, key+"="+set[key])
+			}
+			service.Environment = kept
+		}
+		compose.Services[serviceName] = service
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// CreateServerDirectory creates the server directory structure and copies template files.
+// テンプレートファイルは template.yaml の継承をたどって組み立て、overlays を指定した順に重ねます。
+func CreateServerDirectory(serverName, serverType string, overlays ...string) error {
 	// Get the appropriate server type implementation
 	serverTypeImpl, err := GetServerType(serverType)
 	if err != nil {
 		return err
 	}
 
-	serverDir := fmt.Sprintf("minecraft/servers/%s", serverName)
-	templateDir := fmt.Sprintf("minecraft/template/%s", serverType)
+	serverDir := fmt.Sprintf("%s/servers/%s", MinecraftDir, serverName)
+	chain, err := TemplateChain(serverType)
+	if err != nil {
+		return err
+	}
 
 	// Create server directory
-	if err := os.MkdirAll(serverDir, 0755); err != nil {
+	if err := vfs.MkdirAll(serverDir, 0755); err != nil {
 		return fmt.Errorf("サーバーディレクトリの作成に失敗しました: %w", err)
 	}
 
 	// Create subdirectories using the interface
 	for _, subdir := range serverTypeImpl.GetSubdirectories() {
-		if err := os.MkdirAll(fmt.Sprintf("%s/%s", serverDir, subdir), 0755); err != nil {
+		if err := vfs.MkdirAll(fmt.Sprintf("%s/%s", serverDir, subdir), 0755); err != nil {
 			return fmt.Errorf("サブディレクトリ %s の作成に失敗しました: %w", subdir, err)
 		}
 	}
 
 	// Copy template files using the interface
 	for _, file := range serverTypeImpl.GetTemplateFiles() {
-		src := fmt.Sprintf("%s/%s", templateDir, file)
-		dst := fmt.Sprintf("%s/%s", serverDir, file)
-
-		if err := copyFile(src, dst); err != nil {
+		data, err := RenderTemplateFile(chain, file)
+		if err != nil {
+			return fmt.Errorf("テンプレートファイル %s のコピーに失敗しました: %w", file, err)
+		}
+		if err := vfs.WriteFile(fmt.Sprintf("%s/%s", serverDir, file), data, 0644); err != nil {
 			return fmt.Errorf("テンプレートファイル %s のコピーに失敗しました: %w", file, err)
 		}
 	}
 
+	for _, overlay := range overlays {
+		if err := ApplyOverlay(serverDir, overlay); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// copyFile copies a file from src to dst
-func copyFile(src, dst string) error {
-	data, err := os.ReadFile(src)
-	if err != nil {
-		return err
+// ServerDirectory returns the data directory of a server.
+// mcctl で作成したサーバーは <MinecraftDir>/servers/<name> に、
+// 手作業で作成されたサーバーは <MinecraftDir>/<name> に置かれています。
+func ServerDirectory(serverName string) string {
+	managed := fmt.Sprintf("%s/servers/%s", MinecraftDir, serverName)
+	if _, err := vfs.Stat(managed); err == nil {
+		return managed
 	}
-	return os.WriteFile(dst, data, 0644)
+	legacy := fmt.Sprintf("%s/%s", MinecraftDir, serverName)
+	if _, err := vfs.Stat(legacy); err == nil {
+		return legacy
+	}
+	return managed
 }
 
 // ForgeServerType implements ServerTypeInterface for Forge servers
@@ -355,6 +783,45 @@ func (p *PaperServerType) GetTemplateFiles() []string {
 	return []string{"ops.json", "whitelist.json", "server.properties", "paper-global.yml"}
 }
 
+func (p *PaperServerType) PaperCompatible() bool {
+	return true
+}
+
+// FabricServerType implements ServerTypeInterface for Fabric servers
+type FabricServerType struct{}
+
+func (f *FabricServerType) GetEnvironment() []string {
+	return []string{
+		"EULA=true",
+		"TYPE=FABRIC",
+		"VERSION=1.20.1",
+		"MEMORY=4G",
+	}
+}
+
+func (f *FabricServerType) GetVolumes(serverName string) []string {
+	return []string{
+		fmt.Sprintf("./servers/%s/world:/data/world", serverName),
+		fmt.Sprintf("./servers/%s/mods:/data/mods", serverName),
+		fmt.Sprintf("./servers/%s/config:/data/config", serverName),
+		fmt.Sprintf("./servers/%s/ops.json:/data/ops.json", serverName),
+		fmt.Sprintf("./servers/%s/server.properties:/data/server.properties", serverName),
+		fmt.Sprintf("./servers/%s/whitelist.json:/data/whitelist.json", serverName),
+	}
+}
+
+func (f *FabricServerType) GetTemplatePath() string {
+	return "./template/fabric"
+}
+
+func (f *FabricServerType) GetSubdirectories() []string {
+	return []string{"world", "mods", "config"}
+}
+
+func (f *FabricServerType) GetTemplateFiles() []string {
+	return []string{"ops.json", "whitelist.json", "server.properties"}
+}
+
 // VanillaServerType implements ServerTypeInterface for Vanilla servers
 type VanillaServerType struct{}
 
@@ -391,6 +858,7 @@ func (v *VanillaServerType) GetTemplateFiles() []string {
 // init registers all default server types
 func init() {
 	RegisterServerType("forge", func() ServerTypeInterface { return &ForgeServerType{} })
+	RegisterServerType("fabric", func() ServerTypeInterface { return &FabricServerType{} })
 	RegisterServerType("paper", func() ServerTypeInterface { return &PaperServerType{} })
 	RegisterServerType("vanilla", func() ServerTypeInterface { return &VanillaServerType{} })
 }
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mcctl/internal/vfs"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateMetaFileName は、テンプレートの継承を宣言するファイルの名前です。
+//
+//	extends: base
+//
+// 親テンプレートのファイルを引き継ぎ、子テンプレートには異なるファイルだけを置きます。
+// .properties ファイルはキー単位で重ねるため、子には異なるキーだけを書けば十分です。
+const TemplateMetaFileName = "template.yaml"
+
+// OverlayRoot は、名前付きオーバーレイ (hardcore, creative など) を置くディレクトリです。
+func OverlayRoot() string {
+	return filepath.Join(TemplateRoot(), "overlays")
+}
+
+// TemplateMeta は template.yaml の内容です。
+type TemplateMeta struct {
+	Extends string `yaml:"extends,omitempty"`
+}
+
+// TemplateChain は、テンプレートのディレクトリを最上位の親から順に返します。
+func TemplateChain(name string) ([]string, error) {
+	var chain []string
+	seen := make(map[string]bool)
+	for name != "" {
+		name = strings.ToLower(name)
+		if seen[name] {
+			return nil, fmt.Errorf("テンプレート %s の継承が循環しています", name)
+		}
+		seen[name] = true
+
+		dir := filepath.Join(TemplateRoot(), name)
+		if _, err := vfs.Stat(dir); err != nil {
+			return nil, fmt.Errorf("テンプレート %s が見つかりません", name)
+		}
+		chain = append([]string{dir}, chain...)
+
+		data, err := vfs.ReadFile(filepath.Join(dir, TemplateMetaFileName))
+		if err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			return nil, err
+		}
+		var meta TemplateMeta
+		if err := yaml.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("%s/%s のパースに失敗しました: %w", dir, TemplateMetaFileName, err)
+		}
+		name = meta.Extends
+	}
+	return chain, nil
+}
+
+// RenderTemplateFile は、継承の連鎖をたどってテンプレートファイルの内容を組み立てます。
+// .properties ファイルは親から順にキーを重ね、それ以外のファイルは最も子に近いものを使います。
+func RenderTemplateFile(chain []string, file string) ([]byte, error) {
+	var content []byte
+	found := false
+	for _, dir := range chain {
+		data, err := vfs.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if found && isProperties(file) {
+			content = MergeProperties(content, data)
+		} else {
+			content = data
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("%s がテンプレートにありません", file)
+	}
+	return content, nil
+}
+
+func isProperties(file string) bool {
+	return strings.HasSuffix(file, ".properties")
+}
+
+// MergeProperties は、base の行の順序とコメントを保ったまま overlay のキーで値を置き換えます。
+// base にないキーは末尾に追加します。
+func MergeProperties(base, overlay []byte) []byte {
+	overrides := make(map[string]string)
+	var order []string
+	for _, line := range strings.Split(string(overlay), "\n") {
+		key, ok := propertyKey(line)
+		if !ok {
+			continue
+		}
+		if _, dup := overrides[key]; !dup {
+			order = append(order, key)
+		}
+		overrides[key] = strings.TrimRight(line, "\r")
+	}
+
+	var buf bytes.Buffer
+	applied := make(map[string]bool)
+	lines := strings.Split(strings.TrimRight(string(base), "\n"), "\n")
+	for _, line := range lines {
+		if key, ok := propertyKey(line); ok {
+			if v, ok := overrides[key]; ok {
+				line = v
+				applied[key] = true
+			}
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	for _, key := range order {
+		if !applied[key] {
+			buf.WriteString(overrides[key])
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+// propertyKey returns the key of a "key=value" line, skipping comments and blank lines.
+func propertyKey(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' || line[0] == '!' {
+		return "", false
+	}
+	key, _, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSpace(key), true
+}
+
+// ListOverlays はオーバーレイの名前を返します。
+func ListOverlays() []string {
+	entries, err := os.ReadDir(OverlayRoot())
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyOverlay は、オーバーレイのファイルをサーバーディレクトリに重ねます。
+// .properties ファイルは既存のファイルにキー単位で重ね、それ以外のファイルは上書きします。
+func ApplyOverlay(serverDir, overlay string) error {
+	root := filepath.Join(OverlayRoot(), overlay)
+	if strings.ContainsAny(overlay, `/\`) || overlay == "" || overlay == "." || overlay == ".." {
+		return fmt.Errorf("不正なオーバーレイ名です: %s", overlay)
+	}
+	if _, err := vfs.Stat(root); err != nil {
+		return fmt.Errorf("オーバーレイ %s が見つかりません", overlay)
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(serverDir, rel)
+		if d.IsDir() {
+			return vfs.MkdirAll(dst, 0755)
+		}
+		if d.Name() == ".gitkeep" {
+			return nil
+		}
+
+		data, err := vfs.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if isProperties(d.Name()) {
+			if current, err := vfs.ReadFile(dst); err == nil {
+				data = MergeProperties(current, data)
+			}
+		}
+		if err := vfs.WriteFile(dst, data, 0644); err != nil {
+			return fmt.Errorf("オーバーレイ %s の %s の書き込みに失敗しました: %w", overlay, rel, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mcctl/internal/vfs"
+)
+
+func TestTemplateChain(t *testing.T) {
+	useMinecraftDir(t)
+	writeTemplateFile(t, "base/server.properties", "motd=base\n")
+	writeTemplateFile(t, "paper/"+TemplateMetaFileName, "extends: base\n")
+	writeTemplateFile(t, "survival/"+TemplateMetaFileName, "extends: Paper\n")
+	writeTemplateFile(t, "loop-a/"+TemplateMetaFileName, "extends: loop-b\n")
+	writeTemplateFile(t, "loop-b/"+TemplateMetaFileName, "extends: loop-a\n")
+	writeTemplateFile(t, "self/"+TemplateMetaFileName, "extends: self\n")
+	writeTemplateFile(t, "orphan/"+TemplateMetaFileName, "extends: missing\n")
+	writeTemplateFile(t, "broken/"+TemplateMetaFileName, "extends: [\n")
+
+	dir := func(name string) string { return filepath.Join(TemplateRoot(), name) }
+	tests := []struct {
+		name    string
+		want    []string
+		wantErr string
+	}{
+		{name: "base", want: []string{dir("base")}},
+		{name: "paper", want: []string{dir("base"), dir("paper")}},
+		// 大文字小文字を区別しない
+		{name: "Survival", want: []string{dir("base"), dir("paper"), dir("survival")}},
+		{name: "loop-a", wantErr: "循環"},
+		{name: "self", wantErr: "循環"},
+		{name: "orphan", wantErr: "missing が見つかりません"},
+		{name: "unknown", wantErr: "unknown が見つかりません"},
+		{name: "broken", wantErr: "パース"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TemplateChain(tt.name)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("TemplateChain(%q) = %v, %v, want %q を含むエラー", tt.name, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TemplateChain(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateFile(t *testing.T) {
+	useMinecraftDir(t)
+	writeTemplateFile(t, "base/server.properties", "# base\nmotd=base\ndifficulty=easy\npvp=true\n")
+	writeTemplateFile(t, "base/ops.json", `["base"]`)
+	writeTemplateFile(t, "base/eula.txt", "eula=true\n")
+	writeTemplateFile(t, "paper/"+TemplateMetaFileName, "extends: base\n")
+	writeTemplateFile(t, "paper/server.properties", "difficulty=normal\nview-distance=8\n")
+	writeTemplateFile(t, "paper/ops.json", `["paper"]`)
+	writeTemplateFile(t, "survival/"+TemplateMetaFileName, "extends: paper\n")
+	writeTemplateFile(t, "survival/server.properties", "difficulty=hard\nmotd=survival\n")
+
+	chain, err := TemplateChain("survival")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file    string
+		want    string
+		wantErr bool
+	}{
+		// .properties は親から順にキーを重ね、親の順序とコメントを保つ
+		{file: "server.properties", want: "# base\nmotd=survival\ndifficulty=hard\npvp=true\nview-distance=8\n"},
+		// それ以外は最も子に近いテンプレートのもの
+		{file: "ops.json", want: `["paper"]`},
+		{file: "eula.txt", want: "eula=true\n"},
+		{file: "whitelist.json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := RenderTemplateFile(chain, tt.file)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("RenderTemplateFile(%s) = %q, want error", tt.file, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("RenderTemplateFile(%s) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeProperties(t *testing.T) {
+	tests := []struct {
+		name          string
+		base, overlay string
+		want          string
+	}{
+		{
+			name:    "値の置き換えと追加",
+			base:    "motd=base\npvp=true\n",
+			overlay: "pvp=false\nhardcore=true\n",
+			want:    "motd=base\npvp=false\nhardcore=true\n",
+		},
+		{
+			name:    "コメントと空行を保つ",
+			base:    "#Minecraft server properties\n\nmotd=base\n! comment\n",
+			overlay: "# overlay のコメントは無視する\nmotd=overlay\n",
+			want:    "#Minecraft server properties\n\nmotd=overlay\n! comment\n",
+		},
+		{
+			name:    "キーの前後の空白と CRLF",
+			base:    "motd = base\r\npvp=true\r\n",
+			overlay: "motd=overlay\r\n",
+			want:    "motd=overlay\npvp=true\r\n",
+		},
+		{
+			name:    "overlay で重複したキーは最後の値",
+			base:    "motd=base\n",
+			overlay: "motd=a\nmotd=b\nnew=1\nnew=2\n",
+			want:    "motd=b\nnew=2\n",
+		},
+		{
+			name:    "空の overlay",
+			base:    "motd=base\n",
+			overlay: "",
+			want:    "motd=base\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeProperties([]byte(tt.base), []byte(tt.overlay)); string(got) != tt.want {
+				t.Errorf("MergeProperties() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOverlay(t *testing.T) {
+	useMinecraftDir(t)
+	writeTemplateFile(t, "overlays/hardcore/server.properties", "hardcore=true\ndifficulty=hard\n")
+	writeTemplateFile(t, "overlays/hardcore/config/paper-world.yml", "hardcore: true\n")
+	writeTemplateFile(t, "overlays/hardcore/.gitkeep", "")
+	writeTemplateFile(t, "overlays/creative/server.properties", "gamemode=creative\ndifficulty=peaceful\n")
+	writeTemplateFile(t, "overlays/creative/ops.json", `["builder"]`)
+	if err := os.MkdirAll(filepath.Join(OverlayRoot(), "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := ListOverlays(), []string{"creative", "empty", "hardcore"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListOverlays() = %v, want %v", got, want)
+	}
+
+	serverDir := filepath.Join(t.TempDir(), "survival")
+	os.MkdirAll(serverDir, 0755)
+	os.WriteFile(filepath.Join(serverDir, "server.properties"), []byte("motd=survival\ndifficulty=easy\n"), 0644)
+	os.WriteFile(filepath.Join(serverDir, "ops.json"), []byte(`["admin"]`), 0644)
+
+	// 後から適用したオーバーレイが優先される
+	for _, overlay := range []string{"hardcore", "creative"} {
+		if err := ApplyOverlay(serverDir, overlay); err != nil {
+			t.Fatalf("ApplyOverlay(%s) = %v", overlay, err)
+		}
+	}
+	want := map[string]string{
+		"server.properties":      "motd=survival\ndifficulty=peaceful\nhardcore=true\ngamemode=creative\n",
+		"ops.json":               `["builder"]`,
+		"config/paper-world.yml": "hardcore: true\n",
+	}
+	for rel, content := range want {
+		if data, _ := os.ReadFile(filepath.Join(serverDir, rel)); string(data) != content {
+			t.Errorf("%s = %q, want %q", rel, data, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(serverDir, ".gitkeep")); !os.IsNotExist(err) {
+		t.Error(".gitkeep がコピーされました")
+	}
+
+	for _, name := range []string{"missing", "", ".", "..", "../overlays/hardcore", `a\b`} {
+		if err := ApplyOverlay(serverDir, name); err == nil {
+			t.Errorf("ApplyOverlay(%q) が成功しました", name)
+		}
+	}
+}
+
+func TestApplyOverlayRecording(t *testing.T) {
+	useMinecraftDir(t)
+	writeTemplateFile(t, "overlays/hardcore/server.properties", "hardcore=true\n")
+	writeTemplateFile(t, "overlays/hardcore/config/paper-world.yml", "hardcore: true\n")
+
+	serverDir := filepath.Join(t.TempDir(), "survival")
+	os.MkdirAll(serverDir, 0755)
+	os.WriteFile(filepath.Join(serverDir, "server.properties"), []byte("motd=survival\n"), 0644)
+
+	vfs.Record()
+	defer vfs.Discard()
+	// server.properties を記録中に書き換えてからオーバーレイを重ねる (mcctl add と同じ順序)
+	vfs.WriteFile(filepath.Join(serverDir, "server.properties"), []byte("motd=recorded\n"), 0644)
+	if err := ApplyOverlay(serverDir, "hardcore"); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(serverDir, "server.properties")); string(data) != "motd=survival\n" {
+		t.Errorf("記録中にディスクの server.properties が変わりました: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(serverDir, "config")); !os.IsNotExist(err) {
+		t.Error("記録中に config ディレクトリが作られました")
+	}
+	if data, _ := vfs.ReadFile(filepath.Join(serverDir, "server.properties")); string(data) != "motd=recorded\nhardcore=true\n" {
+		t.Errorf("記録した server.properties = %q", data)
+	}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateRoot は、サーバータイプごとのテンプレートを置くディレクトリです。
+func TemplateRoot() string {
+	return filepath.Join(MinecraftDir, "template")
+}
+
+// TypeFileName は、テンプレートディレクトリに置くサーバータイプ定義のファイル名です。
+const TypeFileName = "type.yaml"
+
+// BuiltinSource は、Go で組み込まれたサーバータイプの TypeInfo.Source です。
+const BuiltinSource = "builtin"
+
+// TypeDefinition は type.yaml の内容です。
+//
+//	description: Purpur サーバー
+//	environment:
+//	  - EULA=true
+//	  - TYPE=PURPUR
+//	  - VERSION=1.20.1
+//	volumes:
+//	  - world:/data/world        # ./servers/<name>/world にマウントされます
+//	  - plugins:/data/plugins
+//	subdirectories: [world, plugins]
+//	templateFiles: [ops.json, whitelist.json, server.properties]
+//	paperCompatible: true        # Paper の tps、mspt コマンドが使える
+type TypeDefinition struct {
+	Description    string   `yaml:"description,omitempty"`
+	Environment    []string `yaml:"environment"`
+	Volumes        []string `yaml:"volumes"`
+	Subdirectories []string `yaml:"subdirectories"`
+	TemplateFiles  []string `yaml:"templateFiles"`
+	// PaperCompatible は Paper のフォークなど、Paper の独自コマンドが使えるタイプで true にします。
+	PaperCompatible bool `yaml:"paperCompatible,omitempty"`
+}
+
+// YAMLServerType implements ServerTypeInterface from a type.yaml definition.
+type YAMLServerType struct {
+	Name       string
+	Definition TypeDefinition
+}
+
+func (t *YAMLServerType) GetEnvironment() []string {
+	return append([]string(nil), t.Definition.Environment...)
+}
+
+func (t *YAMLServerType) GetVolumes(serverName string) []string {
+	volumes := make([]string, 0, len(t.Definition.Volumes))
+	for _, v := range t.Definition.Volumes {
+		volumes = append(volumes, fmt.Sprintf("./servers/%s/%s", serverName, v))
+	}
+	return volumes
+}
+
+func (t *YAMLServerType) GetTemplatePath() string {
+	return "./template/" + t.Name
+}
+
+func (t *YAMLServerType) GetSubdirectories() []string {
+	return append([]string(nil), t.Definition.Subdirectories...)
+}
+
+func (t *YAMLServerType) GetTemplateFiles() []string {
+	return append([]string(nil), t.Definition.TemplateFiles...)
+}
+
+func (t *YAMLServerType) PaperCompatible() bool {
+	return t.Definition.PaperCompatible
+}
+
+// typeDefinitionPath returns the path of the type.yaml for serverType.
+func typeDefinitionPath(serverType string) string {
+	return filepath.Join(TemplateRoot(), strings.ToLower(serverType), TypeFileName)
+}
+
+// LoadTypeDefinition は type.yaml を読み込んで検証します。
+func LoadTypeDefinition(path string) (TypeDefinition, error) {
+	var def TypeDefinition
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return def, err
+	}
+	if err := yaml.Unmarshal(data, &def); err != nil {
+		return def, fmt.Errorf("%s のパースに失敗しました: %w", path, err)
+	}
+	for _, env := range def.Environment {
+		if !strings.Contains(env, "=") {
+			return def, fmt.Errorf("%s: environment は KEY=value の形式で指定してください: %s", path, env)
+		}
+	}
+	for _, v := range def.Volumes {
+		src, _, ok := strings.Cut(v, ":")
+		if !ok || src == "" || filepath.IsAbs(src) || strings.HasPrefix(filepath.Clean(src), "..") {
+			return def, fmt.Errorf("%s: volumes はサーバーディレクトリからの相対パス:コンテナ内のパス の形式で指定してください: %s", path, v)
+		}
+	}
+	return def, nil
+}
+
+// loadYAMLServerType は <TemplateRoot>/<type>/type.yaml を読み込みます。
+// ファイルがない場合は nil を返します。
+func loadYAMLServerType(serverType string) (ServerTypeInterface, error) {
+	if serverType == "" || strings.ContainsAny(serverType, `/\.`) {
+		return nil, nil
+	}
+	path := typeDefinitionPath(serverType)
+	def, err := LoadTypeDefinition(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &YAMLServerType{Name: strings.ToLower(serverType), Definition: def}, nil
+}
+
+// yamlTypeNames は type.yaml があるテンプレートディレクトリの名前を返します。
+func yamlTypeNames() []string {
+	paths, _ := filepath.Glob(filepath.Join(TemplateRoot(), "*", TypeFileName))
+	names := make([]string, 0, len(paths))
+	for _, p := range paths {
+		names = append(names, filepath.Base(filepath.Dir(p)))
+	}
+	return names
+}
+
+// TypeInfo は `mcctl types list` で表示するサーバータイプの情報です。
+type TypeInfo struct {
+	Name        string
+	Description string
+	// Source は type.yaml のパス、または組み込みの場合は BuiltinSource です。
+	Source string
+	// Shadows は、type.yaml が同じ名前の組み込みタイプを上書きしているかどうかです。
+	Shadows bool
+	// Err は type.yaml の読み込みに失敗した場合のエラーです。
+	Err error
+}
+
+// ListServerTypes は、組み込みのタイプと type.yaml で定義されたタイプを名前順に返します。
+func ListServerTypes() []TypeInfo {
+	infos := make(map[string]TypeInfo)
+	for name := range globalFactory.types {
+		infos[name] = TypeInfo{Name: name, Source: BuiltinSource}
+	}
+	for _, name := range yamlTypeNames() {
+		path := typeDefinitionPath(name)
+		def, err := LoadTypeDefinition(path)
+		_, builtin := globalFactory.types[name]
+		infos[name] = TypeInfo{Name: name, Description: def.Description, Source: path, Shadows: builtin, Err: err}
+	}
+
+	list := make([]TypeInfo, 0, len(infos))
+	for _, info := range infos {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
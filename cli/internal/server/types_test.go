@@ -0,0 +1,188 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useMinecraftDir は MinecraftDir をテスト用のディレクトリに切り替え、テストの終わりに戻します。
+func useMinecraftDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	old := MinecraftDir
+	MinecraftDir = dir
+	t.Cleanup(func() { MinecraftDir = old })
+	return dir
+}
+
+// writeTemplateFile は <MinecraftDir>/template/<rel> に content を書き込みます。
+func writeTemplateFile(t *testing.T, rel, content string) {
+	t.Helper()
+	path := filepath.Join(TemplateRoot(), rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const purpurType = `description: Purpur サーバー
+environment:
+  - EULA=true
+  - TYPE=PURPUR
+volumes:
+  - world:/data/world
+  - plugins:/data/plugins
+subdirectories: [world, plugins]
+templateFiles: [server.properties]
+paperCompatible: true
+`
+
+func TestLoadTypeDefinition(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "正しい定義", content: purpurType},
+		{name: "空の定義", content: ""},
+		{name: "パースできない", content: "environment: [\n", wantErr: "パース"},
+		{name: "KEY=value でない環境変数", content: "environment: [EULA]\n", wantErr: "KEY=value"},
+		{name: "コンテナ内のパスがないボリューム", content: "volumes: [world]\n", wantErr: "volumes"},
+		{name: "絶対パスのボリューム", content: "volumes: ['/srv/world:/data/world']\n", wantErr: "volumes"},
+		{name: "サーバーディレクトリの外を指すボリューム", content: "volumes: ['../other/world:/data/world']\n", wantErr: "volumes"},
+		{name: "空のソースのボリューム", content: "volumes: [':/data/world']\n", wantErr: "volumes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), TypeFileName)
+			os.WriteFile(path, []byte(tt.content), 0644)
+			_, err := LoadTypeDefinition(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("LoadTypeDefinition() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadTypeDefinition() = %v, want %q を含むエラー", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetServerTypeFromYAML(t *testing.T) {
+	useMinecraftDir(t)
+	writeTemplateFile(t, "purpur/"+TypeFileName, purpurType)
+
+	impl, err := GetServerType("Purpur")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := impl.GetEnvironment(), []string{"EULA=true", "TYPE=PURPUR"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetEnvironment() = %v, want %v", got, want)
+	}
+	want := []string{"./servers/lobby/world:/data/world", "./servers/lobby/plugins:/data/plugins"}
+	if got := impl.GetVolumes("lobby"); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetVolumes() = %v, want %v", got, want)
+	}
+	if got := impl.GetTemplatePath(); got != "./template/purpur" {
+		t.Errorf("GetTemplatePath() = %q", got)
+	}
+	if got := impl.GetSubdirectories(); !reflect.DeepEqual(got, []string{"world", "plugins"}) {
+		t.Errorf("GetSubdirectories() = %v", got)
+	}
+	if got := impl.GetTemplateFiles(); !reflect.DeepEqual(got, []string{"server.properties"}) {
+		t.Errorf("GetTemplateFiles() = %v", got)
+	}
+	if !IsPaperCompatible("purpur") {
+		t.Error("paperCompatible: true の purpur が Paper 互換になっていません")
+	}
+
+	// 返した値を変更しても定義は変わらない
+	impl.GetEnvironment()[0] = "EULA=false"
+	if got := impl.GetEnvironment()[0]; got != "EULA=true" {
+		t.Errorf("GetEnvironment() の結果を変更すると定義が変わりました: %q", got)
+	}
+}
+
+func TestGetServerTypeLookup(t *testing.T) {
+	useMinecraftDir(t)
+	// paper の type.yaml は組み込みの paper を上書きする
+	writeTemplateFile(t, "paper/"+TypeFileName, "environment: [EULA=true, TYPE=PAPER, VERSION=1.21]\n")
+	writeTemplateFile(t, "broken/"+TypeFileName, "environment: [EULA]\n")
+
+	tests := []struct {
+		name    string
+		typ     string
+		wantEnv []string
+		wantErr bool
+	}{
+		{name: "type.yaml が組み込みより優先される", typ: "paper", wantEnv: []string{"EULA=true", "TYPE=PAPER", "VERSION=1.21"}},
+		{name: "type.yaml がない組み込みタイプ", typ: "FORGE", wantEnv: (&ForgeServerType{}).GetEnvironment()},
+		{name: "不正な type.yaml", typ: "broken", wantErr: true},
+		{name: "未知のタイプ", typ: "spigot", wantErr: true},
+		{name: "パス区切りを含むタイプ", typ: "../paper", wantErr: true},
+		{name: "空のタイプ", typ: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			impl, err := GetServerType(tt.typ)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("GetServerType(%q) = %T, want error", tt.typ, impl)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := impl.GetEnvironment(); !reflect.DeepEqual(got, tt.wantEnv) {
+				t.Errorf("GetEnvironment() = %v, want %v", got, tt.wantEnv)
+			}
+		})
+	}
+	// paperCompatible を書かずに上書きした paper は Paper 互換にならない
+	if IsPaperCompatible("paper") {
+		t.Error("paperCompatible のない type.yaml の paper が Paper 互換になっています")
+	}
+}
+
+func TestListServerTypes(t *testing.T) {
+	useMinecraftDir(t)
+	writeTemplateFile(t, "purpur/"+TypeFileName, purpurType)
+	writeTemplateFile(t, "paper/"+TypeFileName, "description: 独自の Paper\n")
+	writeTemplateFile(t, "broken/"+TypeFileName, "volumes: [world]\n")
+	// type.yaml のないテンプレートディレクトリはタイプにならない
+	writeTemplateFile(t, "base/server.properties", "motd=base\n")
+
+	got := make(map[string]TypeInfo)
+	var names []string
+	for _, info := range ListServerTypes() {
+		got[info.Name] = info
+		names = append(names, info.Name)
+	}
+	if want := []string{"broken", "fabric", "forge", "paper", "purpur", "vanilla"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("names = %v, want %v", names, want)
+	}
+	if !reflect.DeepEqual(GetRegisteredTypes(), names) {
+		t.Errorf("GetRegisteredTypes() = %v, want %v", GetRegisteredTypes(), names)
+	}
+
+	if info := got["forge"]; info.Source != BuiltinSource || info.Shadows || info.Err != nil {
+		t.Errorf("forge = %+v", info)
+	}
+	if info := got["purpur"]; info.Source != filepath.Join(TemplateRoot(), "purpur", TypeFileName) || info.Shadows || info.Description != "Purpur サーバー" {
+		t.Errorf("purpur = %+v", info)
+	}
+	if info := got["paper"]; !info.Shadows || info.Description != "独自の Paper" {
+		t.Errorf("paper = %+v, want 組み込みを上書き", info)
+	}
+	if info := got["broken"]; info.Err == nil {
+		t.Errorf("broken = %+v, want エラー", info)
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+func TestAddVelocityFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "velocity.toml")
+	os.WriteFile(path, []byte(`try = ['lobby', 'large']
+
+[forced-hosts]
+'large.example.com' = ['large']
+'lobby.example.com' = ['lobby']
+
+[servers]
+large = 'large-paper:25565'
+lobby = 'lobby:25565'
+`), 0644)
+
+	changed, err := AddVelocityFallback(path, "large", "large-sleeping", "host.docker.internal:25600")
+	if err != nil || !changed {
+		t.Fatalf("AddVelocityFallback() = %v, %v", changed, err)
+	}
+	var config struct {
+		Try         []string            `toml:"try"`
+		ForcedHosts map[string][]string `toml:"forced-hosts"`
+		Servers     map[string]string   `toml:"servers"`
+	}
+	data, _ := os.ReadFile(path)
+	if err := toml.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"lobby", "large", "large-sleeping"}; !reflect.DeepEqual(config.Try, want) {
+		t.Errorf("try = %v, want %v", config.Try, want)
+	}
+	if want := []string{"large", "large-sleeping"}; !reflect.DeepEqual(config.ForcedHosts["large.example.com"], want) {
+		t.Errorf("forced-hosts.large = %v, want %v", config.ForcedHosts["large.example.com"], want)
+	}
+	if want := []string{"lobby"}; !reflect.DeepEqual(config.ForcedHosts["lobby.example.com"], want) {
+		t.Errorf("forced-hosts.lobby = %v, want %v", config.ForcedHosts["lobby.example.com"], want)
+	}
+	if got := config.Servers["large-sleeping"]; got != "host.docker.internal:25600" {
+		t.Errorf("servers.large-sleeping = %q", got)
+	}
+
+	// 2回目は変更なし
+	if changed, err := AddVelocityFallback(path, "large", "large-sleeping", "host.docker.internal:25600"); err != nil || changed {
+		t.Errorf("2回目の AddVelocityFallback() = %v, %v, want false", changed, err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(data) {
+		t.Errorf("変更がないのに velocity.toml が書き換えられました")
+	}
+	// [servers] にないサーバーは登録しない
+	if changed, err := AddVelocityFallback(path, "creative", "creative-sleeping", "host.docker.internal:25601"); err != nil || changed {
+		t.Errorf("AddVelocityFallback(creative) = %v, %v, want false", changed, err)
+	}
+}
@@ -0,0 +1,75 @@
+package slp
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// chatComponent は、MOTD などで使われるチャットコンポーネントのうち表示に必要な部分です。
+type chatComponent struct {
+	Text      string            `json:"text"`
+	Translate string            `json:"translate"`
+	Extra     []json.RawMessage `json:"extra"`
+}
+
+// ChatText はチャットコンポーネントを装飾なしの文字列に変換します。
+// 文字列・オブジェクト・配列のいずれの形式も受け付け、§ による書式コードは取り除きます。
+func ChatText(raw json.RawMessage) string {
+	var b strings.Builder
+	appendChat(&b, raw)
+	return StripFormatting(b.String())
+}
+
+func appendChat(b *strings.Builder, raw json.RawMessage) {
+	if len(raw) == 0 {
+		return
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			b.WriteString(s)
+		}
+	case '[':
+		var parts []json.RawMessage
+		if json.Unmarshal(raw, &parts) == nil {
+			for _, part := range parts {
+				appendChat(b, part)
+			}
+		}
+	case '{':
+		var c chatComponent
+		if json.Unmarshal(raw, &c) != nil {
+			return
+		}
+		if c.Text != "" {
+			b.WriteString(c.Text)
+		} else {
+			b.WriteString(c.Translate)
+		}
+		for _, extra := range c.Extra {
+			appendChat(b, extra)
+		}
+	}
+}
+
+// StripFormatting は "§a" のような書式コードを取り除きます。
+func StripFormatting(s string) string {
+	if !strings.ContainsRune(s, '§') {
+		return s
+	}
+	var b strings.Builder
+	skip := false
+	for _, r := range s {
+		if skip {
+			skip = false
+			continue
+		}
+		if r == '§' {
+			skip = true
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
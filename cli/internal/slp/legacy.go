@@ -0,0 +1,99 @@
+package slp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// legacyProtocol は 1.6.4 のプロトコル番号です。
+const legacyProtocol = 78
+
+// PingLegacy は 1.6 形式のレガシーピング (0xFE 0x01) でステータスを取得します。
+// サンプルプレイヤーとアイコンは取得できません。
+func PingLegacy(address string, timeout time.Duration) (*Response, error) {
+	host, port, err := splitAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))), timeout)
+	if err != nil {
+		return nil, fmt.Errorf("サーバーに接続できません: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	// MC|PingHost プラグインメッセージ
+	var body bytes.Buffer
+	body.WriteByte(legacyProtocol)
+	writeUTF16(&body, host)
+	binary.Write(&body, binary.BigEndian, int32(port))
+
+	var req bytes.Buffer
+	req.Write([]byte{0xFE, 0x01, 0xFA})
+	writeUTF16(&req, "MC|PingHost")
+	binary.Write(&req, binary.BigEndian, int16(body.Len()))
+	req.Write(body.Bytes())
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	var header [3]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, fmt.Errorf("レガシーピング応答の受信に失敗しました: %w", err)
+	}
+	latency := time.Since(start)
+	if header[0] != 0xFF {
+		return nil, fmt.Errorf("不正なレガシーピング応答です")
+	}
+	length := binary.BigEndian.Uint16(header[1:])
+	units := make([]uint16, length)
+	if err := binary.Read(conn, binary.BigEndian, units); err != nil {
+		return nil, fmt.Errorf("レガシーピング応答の受信に失敗しました: %w", err)
+	}
+
+	return parseLegacy(string(utf16.Decode(units)), latency)
+}
+
+// parseLegacy parses "§1\x00protocol\x00version\x00motd\x00online\x00max".
+// 1.4 より前のサーバーは "motd§online§max" を返すため、そちらにも対応する。
+func parseLegacy(s string, latency time.Duration) (*Response, error) {
+	resp := &Response{Latency: latency, Legacy: true}
+
+	var motd, online, max string
+	if strings.HasPrefix(s, "§1\x00") {
+		fields := strings.Split(s, "\x00")
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("不正なレガシーピング応答です")
+		}
+		resp.Version.Protocol, _ = strconv.Atoi(fields[1])
+		resp.Version.Name = fields[2]
+		motd, online, max = fields[3], fields[4], fields[5]
+	} else {
+		fields := strings.Split(s, "§")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("不正なレガシーピング応答です")
+		}
+		motd = strings.Join(fields[:len(fields)-2], "§")
+		online, max = fields[len(fields)-2], fields[len(fields)-1]
+	}
+
+	resp.Players.Online, _ = strconv.Atoi(online)
+	resp.Players.Max, _ = strconv.Atoi(max)
+	resp.Description, _ = json.Marshal(motd)
+	return resp, nil
+}
+
+func writeUTF16(buf *bytes.Buffer, s string) {
+	units := utf16.Encode([]rune(s))
+	binary.Write(buf, binary.BigEndian, int16(len(units)))
+	binary.Write(buf, binary.BigEndian, units)
+}
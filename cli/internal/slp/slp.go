@@ -0,0 +1,158 @@
+// Package slp は Minecraft の Server List Ping (サーバーリストに表示される情報の取得) を実装します。
+// 1.7 以降のハンドシェイク + ステータスJSON方式と、1.6 のレガシーピングに対応します。
+package slp
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcctl/internal/mcproto"
+)
+
+// DefaultPort is used when the address has no port.
+const DefaultPort = 25565
+
+// protocolVersion はハンドシェイクで名乗るプロトコル番号です。
+// ステータス取得ではサーバーは値を問わないため、-1 (不明) を送ります。
+const protocolVersion = -1
+
+// Response はサーバーのステータスです。
+type Response struct {
+	Version struct {
+		Name     string `json:"name"`
+		Protocol int    `json:"protocol"`
+	} `json:"version"`
+	Players struct {
+		Max    int      `json:"max"`
+		Online int      `json:"online"`
+		Sample []Player `json:"sample,omitempty"`
+	} `json:"players"`
+	// Description はチャットコンポーネント (文字列またはオブジェクト) のままの MOTD です。
+	Description json.RawMessage `json:"description"`
+	// Favicon は "data:image/png;base64,..." 形式のアイコンです。
+	Favicon string `json:"favicon,omitempty"`
+
+	// Latency はピングの往復時間です。
+	Latency time.Duration `json:"-"`
+	// Legacy はレガシーピングで取得した場合に true になります。
+	Legacy bool `json:"-"`
+}
+
+// Player はステータスに含まれるプレイヤーのサンプルです。
+type Player struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// MOTD は Description を装飾なしの文字列に変換して返します。
+func (r *Response) MOTD() string {
+	return ChatText(r.Description)
+}
+
+// FaviconPNG はアイコンをデコードして PNG のバイト列を返します。
+func (r *Response) FaviconPNG() ([]byte, error) {
+	const prefix = "data:image/png;base64,"
+	if r.Favicon == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(r.Favicon, prefix) {
+		return nil, fmt.Errorf("未対応のアイコン形式です")
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimPrefix(r.Favicon, prefix))
+}
+
+// Ping はサーバーのステータスを取得します。
+// 最新のプロトコルで応答がない場合は 1.6 のレガシーピングを試します。
+func Ping(address string, timeout time.Duration) (*Response, error) {
+	resp, err := PingModern(address, timeout)
+	if err == nil {
+		return resp, nil
+	}
+	legacy, legacyErr := PingLegacy(address, timeout)
+	if legacyErr != nil {
+		return nil, err
+	}
+	return legacy, nil
+}
+
+// splitAddress splits address into host and port, applying DefaultPort.
+func splitAddress(address string) (string, uint16, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, DefaultPort, nil
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("不正なポート番号: %s", portStr)
+	}
+	return host, uint16(port), nil
+}
+
+// PingModern は 1.7 以降のハンドシェイクとステータス要求でステータスを取得します。
+func PingModern(address string, timeout time.Duration) (*Response, error) {
+	host, port, err := splitAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))), timeout)
+	if err != nil {
+		return nil, fmt.Errorf("サーバーに接続できません: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	handshake := mcproto.Handshake{
+		ProtocolVersion: protocolVersion,
+		ServerAddress:   host,
+		ServerPort:      port,
+		NextState:       mcproto.StateStatus,
+	}
+	if err := mcproto.WritePacket(conn, 0x00, handshake.Encode()); err != nil {
+		return nil, err
+	}
+	if err := mcproto.WritePacket(conn, 0x00, nil); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	id, payload, err := mcproto.ReadPacket(r)
+	if err != nil {
+		return nil, fmt.Errorf("ステータス応答の受信に失敗しました: %w", err)
+	}
+	if id != 0x00 {
+		return nil, fmt.Errorf("予期しないパケットID: 0x%02x", id)
+	}
+	data, err := mcproto.ReadString(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp Response
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		return nil, fmt.Errorf("ステータスJSONのパースに失敗しました: %w", err)
+	}
+
+	token := time.Now().UnixNano()
+	start := time.Now()
+	if err := mcproto.WritePacket(conn, 0x01, binary.BigEndian.AppendUint64(nil, uint64(token))); err != nil {
+		return nil, err
+	}
+	id, payload, err = mcproto.ReadPacket(r)
+	if err != nil {
+		return nil, fmt.Errorf("ピング応答の受信に失敗しました: %w", err)
+	}
+	resp.Latency = time.Since(start)
+	var echoed [8]byte
+	if _, err := io.ReadFull(payload, echoed[:]); err != nil || id != 0x01 || int64(binary.BigEndian.Uint64(echoed[:])) != token {
+		return nil, fmt.Errorf("不正なピング応答です")
+	}
+	return &resp, nil
+}
@@ -0,0 +1,183 @@
+package slp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"mcctl/internal/mcproto"
+)
+
+const pingDelay = 20 * time.Millisecond
+
+var faviconPNG = []byte("\x89PNG\r\n\x1a\nfake")
+
+// statusJSON はモダンなサーバーが返すステータスです。MOTD はチャットコンポーネントのオブジェクトです。
+var statusJSON = `{
+	"version": {"name": "Paper 1.20.1", "protocol": 763},
+	"players": {"max": 20, "online": 2, "sample": [{"name": "Steve", "id": "00000000-0000-0000-0000-000000000001"}]},
+	"description": {"text": "§aWelcome ", "extra": [{"text": "to "}, {"translate": "lobby", "color": "gold"}, "§l!"]},
+	"favicon": "data:image/png;base64,` + base64.StdEncoding.EncodeToString(faviconPNG) + `"
+}`
+
+// fakeServer は、modern が true ならモダンなプロトコルに、そうでなければレガシーピングだけに応答する
+// テスト用のサーバーを起動し、アドレスを返します。
+func fakeServer(t *testing.T, modern bool) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				first, err := r.Peek(1)
+				if err != nil {
+					return
+				}
+				if first[0] == 0xFE {
+					serveLegacy(t, r, conn)
+				} else if modern {
+					serveModern(t, r, conn)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveModern(t *testing.T, r *bufio.Reader, w io.Writer) {
+	id, payload, err := mcproto.ReadPacket(r)
+	if err != nil || id != 0x00 {
+		t.Errorf("handshake: id=%d err=%v", id, err)
+		return
+	}
+	h, err := mcproto.DecodeHandshake(payload)
+	if err != nil || h.NextState != mcproto.StateStatus {
+		t.Errorf("handshake: %+v err=%v", h, err)
+		return
+	}
+	if id, _, err := mcproto.ReadPacket(r); err != nil || id != 0x00 {
+		t.Errorf("status request: id=%d err=%v", id, err)
+		return
+	}
+	if err := mcproto.WritePacket(w, 0x00, mcproto.AppendString(nil, statusJSON)); err != nil {
+		return
+	}
+
+	id, payload, err = mcproto.ReadPacket(r)
+	if err != nil || id != 0x01 {
+		t.Errorf("ping: id=%d err=%v", id, err)
+		return
+	}
+	token, _ := io.ReadAll(payload)
+	time.Sleep(pingDelay)
+	mcproto.WritePacket(w, 0x01, token)
+}
+
+func serveLegacy(t *testing.T, r *bufio.Reader, w io.Writer) {
+	var header [3]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil || header != [3]byte{0xFE, 0x01, 0xFA} {
+		t.Errorf("legacy ping header: %x err=%v", header, err)
+		return
+	}
+	// MC|PingHost の残りを読み捨てる
+	var n int16
+	binary.Read(r, binary.BigEndian, &n)
+	io.CopyN(io.Discard, r, int64(n)*2)
+	binary.Read(r, binary.BigEndian, &n)
+	io.CopyN(io.Discard, r, int64(n))
+
+	units := utf16.Encode([]rune("§1\x00127\x001.6.4\x00A §cLegacy§r Server\x003\x0010"))
+	var resp bytes.Buffer
+	resp.WriteByte(0xFF)
+	binary.Write(&resp, binary.BigEndian, uint16(len(units)))
+	binary.Write(&resp, binary.BigEndian, units)
+	w.Write(resp.Bytes())
+}
+
+func TestPingModern(t *testing.T) {
+	resp, err := PingModern(fakeServer(t, true), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Legacy {
+		t.Error("Legacy = true, want false")
+	}
+	if resp.Version.Name != "Paper 1.20.1" || resp.Version.Protocol != 763 {
+		t.Errorf("Version = %+v", resp.Version)
+	}
+	if resp.Players.Online != 2 || resp.Players.Max != 20 || len(resp.Players.Sample) != 1 || resp.Players.Sample[0].Name != "Steve" {
+		t.Errorf("Players = %+v", resp.Players)
+	}
+	if got, want := resp.MOTD(), "Welcome to lobby!"; got != want {
+		t.Errorf("MOTD() = %q, want %q", got, want)
+	}
+	png, err := resp.FaviconPNG()
+	if err != nil || !bytes.Equal(png, faviconPNG) {
+		t.Errorf("FaviconPNG() = %q, %v", png, err)
+	}
+	if resp.Latency < pingDelay {
+		t.Errorf("Latency = %v, want >= %v", resp.Latency, pingDelay)
+	}
+}
+
+func TestPingLegacy(t *testing.T) {
+	resp, err := PingLegacy(fakeServer(t, false), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Legacy {
+		t.Error("Legacy = false, want true")
+	}
+	if resp.Version.Name != "1.6.4" || resp.Version.Protocol != 127 {
+		t.Errorf("Version = %+v", resp.Version)
+	}
+	if resp.Players.Online != 3 || resp.Players.Max != 10 {
+		t.Errorf("Players = %+v", resp.Players)
+	}
+	if got, want := resp.MOTD(), "A Legacy Server"; got != want {
+		t.Errorf("MOTD() = %q, want %q", got, want)
+	}
+	if png, err := resp.FaviconPNG(); png != nil || err != nil {
+		t.Errorf("FaviconPNG() = %q, %v, want no icon", png, err)
+	}
+}
+
+// TestPingFallsBackToLegacy は、モダンなプロトコルに応答しないサーバーでレガシーピングを使うことを確認します。
+func TestPingFallsBackToLegacy(t *testing.T) {
+	resp, err := Ping(fakeServer(t, false), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Legacy || resp.Players.Online != 3 {
+		t.Errorf("resp = %+v, want legacy response", resp)
+	}
+}
+
+func TestChatText(t *testing.T) {
+	tests := []struct{ raw, want string }{
+		{`"§aplain"`, "plain"},
+		{`{"text":"a","extra":["b",{"text":"c"}]}`, "abc"},
+		{`[{"text":"x"},"y"]`, "xy"},
+	}
+	for _, tt := range tests {
+		if got := ChatText([]byte(tt.raw)); got != tt.want {
+			t.Errorf("ChatText(%s) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,516 @@
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"mcctl/internal/filelock"
+	"mcctl/internal/packfs"
+)
+
+const (
+	// regionBlockSize はリージョンファイル (.mca/.mcr) の分割サイズです。
+	// リージョンファイルは 4KiB セクタ単位でチャンクを配置するため、
+	// セクタ境界に揃えた小さめのブロックにすると変更のないチャンクを共有しやすくなります。
+	regionBlockSize = 64 * 1024
+	// defaultBlockSize はその他のファイルの分割サイズです。
+	defaultBlockSize = 1024 * 1024
+)
+
+// Store は、内容アドレス方式でブロックを保存するバックアップストアです。
+//
+// ディレクトリ構成:
+//
+//	<root>/blocks/<hash[:2]>/<hash>   gzip 圧縮されたブロック
+//	<root>/snapshots/<server>/<id>.json   スナップショットのマニフェスト
+//	<root>/lock   Create と Restore (共有) と GC (排他) のロックファイル
+type Store struct {
+	Root string
+}
+
+// Manifest は、1つのスナップショットに含まれるファイルとブロックの一覧です。
+type Manifest struct {
+	ID        string    `json:"id"`
+	Server    string    `json:"server"`
+	CreatedAt time.Time `json:"createdAt"`
+	Dirs      []string  `json:"dirs"`
+	Files     []File    `json:"files"`
+}
+
+// File は、スナップショット内の1ファイルです。Blocks はファイル先頭からの順に並びます。
+type File struct {
+	Path   string      `json:"path"`
+	Mode   os.FileMode `json:"mode"`
+	Size   int64       `json:"size"`
+	Blocks []string    `json:"blocks"`
+}
+
+// Stats summarizes how much data a snapshot actually added to the store.
+type Stats struct {
+	Files        int
+	Blocks       int
+	NewBlocks    int
+	TotalBytes   int64
+	StoredBytes  int64
+	ReusedBlocks int
+}
+
+// Open returns a Store rooted at root, creating the directory layout if needed.
+func Open(root string) (*Store, error) {
+	for _, dir := range []string{"blocks", "snapshots"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			return nil, fmt.Errorf("ストアディレクトリの作成に失敗しました: %w", err)
+		}
+	}
+	return &Store{Root: root}, nil
+}
+
+func (s *Store) blockPath(hash string) string {
+	return filepath.Join(s.Root, "blocks", hash[:2], hash)
+}
+
+// lock は、ブロックを追加・参照する操作 (exclusive が false) と GC (exclusive が true) が
+// 同時に実行されないようにストアをロックします。
+// 作成中のスナップショットのブロックはまだマニフェストから参照されていないため、GC に削除されてしまいます。
+func (s *Store) lock(exclusive bool) (func(), error) {
+	path := filepath.Join(s.Root, "lock")
+	if exclusive {
+		return filelock.Lock(path)
+	}
+	return filelock.RLock(path)
+}
+
+func (s *Store) manifestPath(server, id string) string {
+	return filepath.Join(s.Root, "snapshots", server, id+".json")
+}
+
+// Create は srcDir のスナップショットを作成します。
+// 既にストアにあるブロックは書き込まず、マニフェストから参照するだけです。
+func (s *Store) Create(server, srcDir string, now time.Time) (Manifest, Stats, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return Manifest{}, Stats{}, err
+	}
+	defer unlock()
+
+	manifest := Manifest{
+		ID:        now.Format("20060102-150405"),
+		Server:    server,
+		CreatedAt: now,
+	}
+	var stats Stats
+
+	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			manifest.Dirs = append(manifest.Dirs, rel)
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := s.storeFile(path, rel, info, &stats)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		manifest.Files = append(manifest.Files, file)
+		return nil
+	})
+	if err != nil {
+		return Manifest{}, Stats{}, fmt.Errorf("スナップショットの作成に失敗しました: %w", err)
+	}
+
+	if err := s.createManifest(&manifest); err != nil {
+		return Manifest{}, Stats{}, err
+	}
+	return manifest, stats, nil
+}
+
+// storeFile splits the file into blocks and writes the ones not yet in the store.
+func (s *Store) storeFile(path, rel string, info os.FileInfo, stats *Stats) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+
+	blockSize := defaultBlockSize
+	ext := strings.ToLower(filepath.Ext(rel))
+	if ext == ".mca" || ext == ".mcr" {
+		blockSize = regionBlockSize
+	}
+
+	file := File{Path: rel, Mode: info.Mode().Perm(), Size: info.Size()}
+	buf := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			hash, written, werr := s.putBlock(buf[:n])
+			if werr != nil {
+				return File{}, werr
+			}
+			file.Blocks = append(file.Blocks, hash)
+			stats.Blocks++
+			stats.TotalBytes += int64(n)
+			if written > 0 {
+				stats.NewBlocks++
+				stats.StoredBytes += written
+			} else {
+				stats.ReusedBlocks++
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return File{}, err
+		}
+	}
+	stats.Files++
+	return file, nil
+}
+
+// putBlock stores data under its SHA-256 and returns the number of bytes
+// written, which is zero when the block was already present.
+func (s *Store) putBlock(data []byte) (string, int64, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	path := s.blockPath(hash)
+
+	if _, err := os.Stat(path); err == nil {
+		return hash, 0, nil
+	}
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(data); err != nil {
+		return "", 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return "", 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", 0, err
+	}
+	// 途中で中断されても壊れたブロックが残らないよう、一時ファイルに書いてからリネームする。
+	// 同じブロックを同時に書く Create があっても衝突しないよう、一時ファイルの名前は毎回変える
+	tmp, err := os.CreateTemp(filepath.Dir(path), hash+".tmp-*")
+	if err != nil {
+		return "", 0, err
+	}
+	_, err = tmp.Write(compressed.Bytes())
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", 0, err
+	}
+	return hash, int64(compressed.Len()), nil
+}
+
+// readBlock returns the decompressed contents of a block.
+func (s *Store) readBlock(hash string) ([]byte, error) {
+	f, err := os.Open(s.blockPath(hash))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
+
+// createManifest は新しいマニフェストを書き出します。
+// 同じ秒に作ったスナップショットを上書きしないよう、ID が既にある場合は -2, -3, ... を付けて m.ID を更新します。
+func (s *Store) createManifest(m *Manifest) error {
+	base := m.ID
+	if err := os.MkdirAll(filepath.Dir(s.manifestPath(m.Server, base)), 0755); err != nil {
+		return fmt.Errorf("マニフェストディレクトリの作成に失敗しました: %w", err)
+	}
+	for seq := 2; ; seq++ {
+		data, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return fmt.Errorf("マニフェストのエンコードに失敗しました: %w", err)
+		}
+		f, err := os.OpenFile(s.manifestPath(m.Server, m.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			m.ID = fmt.Sprintf("%s-%d", base, seq)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("マニフェストの作成に失敗しました: %w", err)
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return fmt.Errorf("マニフェストの書き込みに失敗しました: %w", err)
+		}
+		return nil
+	}
+}
+
+// Load はスナップショットのマニフェストを読み込みます。
+func (s *Store) Load(server, id string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(s.manifestPath(server, id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, fmt.Errorf("スナップショット %s/%s が見つかりません", server, id)
+		}
+		return m, fmt.Errorf("マニフェストの読み込みに失敗しました: %w", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("マニフェストのパースに失敗しました: %w", err)
+	}
+	return m, nil
+}
+
+// List は server のスナップショットを新しい順に返します。
+// server が空の場合はすべてのサーバーのスナップショットを返します。
+func (s *Store) List(server string) ([]Manifest, error) {
+	pattern := filepath.Join(s.Root, "snapshots", "*", "*.json")
+	if server != "" {
+		pattern = filepath.Join(s.Root, "snapshots", server, "*.json")
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := make([]Manifest, 0, len(paths))
+	for _, path := range paths {
+		serverName := filepath.Base(filepath.Dir(path))
+		m, err := s.Load(serverName, strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].CreatedAt.After(manifests[j].CreatedAt)
+	})
+	return manifests, nil
+}
+
+// Forget はスナップショットのマニフェストを削除します。
+// ブロック自体は GC で回収されるまで残ります。
+func (s *Store) Forget(server, id string) error {
+	if err := os.Remove(s.manifestPath(server, id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("スナップショット %s/%s が見つかりません", server, id)
+		}
+		return err
+	}
+	return nil
+}
+
+// Restore は dstDir をスナップショットの内容で置き換えます。
+// 一時ディレクトリに復元してから入れ替えるため、失敗した場合 dstDir は変更しません。
+// dstDir の外を指すパスを含むマニフェストは拒否します。
+func (s *Store) Restore(m Manifest, dstDir string) error {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.MkdirAll(filepath.Dir(dstDir), 0755); err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dstDir), "."+filepath.Base(dstDir)+"-restore-")
+	if err != nil {
+		return fmt.Errorf("一時ディレクトリの作成に失敗しました: %w", err)
+	}
+	// MkdirTemp は 0700 で作成するため、サーバーディレクトリと同じ権限に戻す
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	if err := s.restoreFiles(m, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+
+	oldDir := tmpDir + "-old"
+	if err := os.Rename(dstDir, oldDir); err != nil && !os.IsNotExist(err) {
+		os.RemoveAll(tmpDir)
+		return fmt.Errorf("%s の退避に失敗しました: %w", dstDir, err)
+	}
+	if err := os.Rename(tmpDir, dstDir); err != nil {
+		os.Rename(oldDir, dstDir)
+		os.RemoveAll(tmpDir)
+		return fmt.Errorf("%s の置き換えに失敗しました: %w", dstDir, err)
+	}
+	return os.RemoveAll(oldDir)
+}
+
+// restoreFiles は、マニフェストのディレクトリとファイルを空のディレクトリ dstDir に書き出します。
+func (s *Store) restoreFiles(m Manifest, dstDir string) error {
+	for _, dir := range m.Dirs {
+		target, err := packfs.SafeJoin(dstDir, dir)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return fmt.Errorf("ディレクトリ %s の作成に失敗しました: %w", dir, err)
+		}
+	}
+
+	for _, file := range m.Files {
+		target, err := packfs.SafeJoin(dstDir, file.Path)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, file.Mode)
+		if err != nil {
+			return fmt.Errorf("ファイル %s の作成に失敗しました: %w", file.Path, err)
+		}
+		for _, hash := range file.Blocks {
+			data, err := s.readBlock(hash)
+			if err != nil {
+				out.Close()
+				return fmt.Errorf("ブロック %s の読み込みに失敗しました: %w", hash, err)
+			}
+			if _, err := out.Write(data); err != nil {
+				out.Close()
+				return err
+			}
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Problem は Verify で見つかった不整合です。
+type Problem struct {
+	Snapshot string
+	Path     string
+	Block    string
+	Err      error
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: ブロック %s: %v", p.Snapshot, p.Path, p.Block, p.Err)
+}
+
+// Verify は、マニフェストが参照するすべてのブロックが存在し、
+// 内容がハッシュと一致することを確認します。
+func (s *Store) Verify(manifests []Manifest) []Problem {
+	checked := make(map[string]error)
+	var problems []Problem
+
+	for _, m := range manifests {
+		for _, file := range m.Files {
+			for _, hash := range file.Blocks {
+				err, done := checked[hash]
+				if !done {
+					var data []byte
+					data, err = s.readBlock(hash)
+					if err == nil {
+						sum := sha256.Sum256(data)
+						if hex.EncodeToString(sum[:]) != hash {
+							err = fmt.Errorf("ハッシュが一致しません")
+						}
+					}
+					checked[hash] = err
+				}
+				if err != nil {
+					problems = append(problems, Problem{
+						Snapshot: m.Server + "/" + m.ID,
+						Path:     file.Path,
+						Block:    hash,
+						Err:      err,
+					})
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// GC は、どのスナップショットからも参照されていないブロックを削除し、
+// 削除したブロック数と解放したバイト数を返します。
+// 実行中は Create と Restore を待たせます。
+func (s *Store) GC(dryRun bool) (int, int64, error) {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer unlock()
+
+	manifests, err := s.List("")
+	if err != nil {
+		return 0, 0, err
+	}
+	referenced := make(map[string]bool)
+	for _, m := range manifests {
+		for _, file := range m.Files {
+			for _, hash := range file.Blocks {
+				referenced[hash] = true
+			}
+		}
+	}
+
+	var removed int
+	var freed int64
+	err = filepath.Walk(filepath.Join(s.Root, "blocks"), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || referenced[info.Name()] {
+			return nil
+		}
+		removed++
+		freed += info.Size()
+		if dryRun {
+			return nil
+		}
+		return os.Remove(path)
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("ブロックの削除に失敗しました: %w", err)
+	}
+	return removed, freed, nil
+}
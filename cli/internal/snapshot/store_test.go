@@ -0,0 +1,153 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRestoreReplacesDirectory(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := t.TempDir()
+	os.MkdirAll(filepath.Join(src, "world", "region"), 0755)
+	os.WriteFile(filepath.Join(src, "server.properties"), []byte("motd=snapshot\n"), 0644)
+	os.WriteFile(filepath.Join(src, "world", "region", "r.0.0.mca"), []byte("region"), 0644)
+
+	m, _, err := store.Create("survival", src, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "survival")
+	os.MkdirAll(dst, 0755)
+	os.WriteFile(filepath.Join(dst, "server.properties"), []byte("motd=current\n"), 0644)
+	os.WriteFile(filepath.Join(dst, "added-later.txt"), []byte("x"), 0644)
+
+	if err := store.Restore(m, dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "server.properties")); string(data) != "motd=snapshot\n" {
+		t.Errorf("server.properties = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "world", "region", "r.0.0.mca")); string(data) != "region" {
+		t.Errorf("r.0.0.mca = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "added-later.txt")); !os.IsNotExist(err) {
+		t.Errorf("スナップショットにないファイルが残っています")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(dst)); len(entries) != 1 {
+		t.Errorf("一時ディレクトリが残っています: %v", entries)
+	}
+}
+
+func TestRestoreRejectsEscapingPath(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "level.dat"), []byte("level"), 0644)
+	m, _, err := store.Create("survival", src, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := t.TempDir()
+	dst := filepath.Join(parent, "survival")
+	os.MkdirAll(dst, 0755)
+	os.WriteFile(filepath.Join(dst, "level.dat"), []byte("current"), 0644)
+
+	m.Files = append(m.Files, File{Path: "../escaped.txt", Mode: 0644, Blocks: m.Files[0].Blocks})
+	if err := store.Restore(m, dst); err == nil {
+		t.Fatal("Restore() で dstDir の外を指すパスが受け付けられました")
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escaped.txt")); !os.IsNotExist(err) {
+		t.Errorf("dstDir の外にファイルが書き込まれました")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "level.dat")); string(data) != "current" {
+		t.Errorf("失敗した復元で dstDir が変更されました: level.dat = %q", data)
+	}
+}
+
+func TestCreateSameSecond(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := t.TempDir()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+
+	var ids []string
+	for _, content := range []string{"first", "second", "third"} {
+		os.WriteFile(filepath.Join(src, "level.dat"), []byte(content), 0644)
+		m, _, err := store.Create("survival", src, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, m.ID)
+	}
+	if want := []string{"20240501-120000", "20240501-120000-2", "20240501-120000-3"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("IDs = %v, want %v", ids, want)
+	}
+
+	// 最初のスナップショットが上書きされていないこと
+	m, err := store.Load("survival", ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "survival")
+	if err := store.Restore(m, dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "level.dat")); string(data) != "first" {
+		t.Errorf("level.dat = %q, want first", data)
+	}
+}
+
+func TestPutBlockConcurrent(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, defaultBlockSize)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := store.putBlock(data); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("putBlock() error = %v", err)
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	got, err := store.readBlock(hash)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("readBlock() = %d bytes, %v", len(got), err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(store.blockPath(hash)))
+	if len(entries) != 1 {
+		t.Errorf("一時ファイルが残っています: %v", entries)
+	}
+}
@@ -0,0 +1,217 @@
+// Package upgrade は、サーバーの Minecraft バージョンを変更する前の確認と、元に戻すための記録を扱います。
+//
+// 確認するのは、ダウングレード (ワールドのデータが失われる)、メジャーバージョンの飛ばし、
+// mods.lock と plugins.lock に記録された jar が新しいバージョンに対応しているかです。
+package upgrade
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcctl/internal/mods"
+)
+
+// RecordFileName は、最後のアップグレードの記録をバックアップディレクトリに置くときの名前です。
+const RecordFileName = "upgrade.json"
+
+// LoaderVersionKeys は、Minecraft のバージョンごとに決まるローダーのバージョンの環境変数です。
+// バージョンを変えるときは削除し、itzg/minecraft-server に新しいバージョンに合うものを選ばせます。
+var LoaderVersionKeys = []string{"FORGE_VERSION", "NEOFORGE_VERSION", "FABRIC_LOADER_VERSION"}
+
+// Action は、jar をアップグレードでどう扱うかです。
+type Action string
+
+const (
+	ActionKeep         Action = "keep"         // 今のバージョンのまま使える
+	ActionUpdate       Action = "update"       // 対応するバージョンに入れ替える
+	ActionIncompatible Action = "incompatible" // 対応するバージョンがない
+	ActionUnknown      Action = "unknown"      // ソースに情報がなく確認できない
+	ActionSkipped      Action = "skipped"      // バージョン情報を持たないソースから入れたため確認しない
+)
+
+// Jar は、ロックファイルに記録された MOD またはプラグイン1つの計画です。
+type Jar struct {
+	Kind    string // "mod" または "plugin"
+	ID      string
+	Current string
+	// Release は ActionUpdate のときの入れ替え先です。
+	Release mods.Release
+	Action  Action
+	Reason  string
+}
+
+// Plan はアップグレードの計画です。
+type Plan struct {
+	Server string
+	Type   string
+	From   string
+	To     string
+	Jars   []Jar
+}
+
+// Downgrade reports whether the plan moves to an older Minecraft version.
+func (p *Plan) Downgrade() bool {
+	return mods.CompareVersions(p.To, p.From) < 0
+}
+
+// SkippedMajors は、From と To の間で飛ばすメジャーバージョン (例: 1.18 -> 1.20 の 1.19) です。
+// 1.x 以外の形式のバージョンは比べられないため、空を返します。
+func (p *Plan) SkippedMajors() []string {
+	from, okFrom := majorVersion(p.From)
+	to, okTo := majorVersion(p.To)
+	if !okFrom || !okTo {
+		return nil
+	}
+	if from > to {
+		from, to = to, from
+	}
+	var skipped []string
+	for v := from + 1; v < to; v++ {
+		skipped = append(skipped, fmt.Sprintf("1.%d", v))
+	}
+	return skipped
+}
+
+// majorVersion は "1.18.2" の 18 を返します。
+func majorVersion(v string) (int, bool) {
+	parts := strings.Split(v, ".")
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// Skipped は、ソースで確認できずに確認を省略した jar です。
+func (p *Plan) Skipped() []Jar {
+	var skipped []Jar
+	for _, j := range p.Jars {
+		if j.Action == ActionSkipped {
+			skipped = append(skipped, j)
+		}
+	}
+	return skipped
+}
+
+// Blockers は、--force なしではアップグレードを止める理由です。
+func (p *Plan) Blockers() []string {
+	var blockers []string
+	if p.Downgrade() {
+		blockers = append(blockers, fmt.Sprintf("%s から %s へのダウングレードです。新しいバージョンで保存したワールドは古いバージョンで読めず、データが失われることがあります", p.From, p.To))
+	}
+	// ActionSkipped は確認できないだけで、非対応とわかったわけではないため止めない
+	for _, j := range p.Jars {
+		switch j.Action {
+		case ActionIncompatible, ActionUnknown:
+			blockers = append(blockers, fmt.Sprintf("%s %s: %s", j.Kind, j.ID, j.Reason))
+		}
+	}
+	return blockers
+}
+
+// CheckJars は、ロックファイルのエントリーが Minecraft mcVersion とローダー loader で使えるかを確認します。
+// 今のバージョンが使えない場合は、エントリーの Source を source で解決したソースから対応する最新のリリースを探します。
+// source が解決できないエントリー (mrpack や CurseForge から取り込んだもの) は ActionSkipped になります。
+// 戻り値は entries と同じ順序です。
+func CheckJars(kind string, source func(name string) (mods.Source, error), entries []mods.Entry, mcVersion, loader string) []Jar {
+	jars := make([]Jar, 0, len(entries))
+	for _, e := range entries {
+		jar := Jar{Kind: kind, ID: e.ID, Current: e.Version}
+		src, err := source(e.Source)
+		if err != nil {
+			jar.Action = ActionSkipped
+			jar.Reason = fmt.Sprintf("ソース %s のバージョン情報がないため確認できません", e.Source)
+			jars = append(jars, jar)
+			continue
+		}
+		releases, err := src.Versions(e.ID)
+		if err != nil {
+			jar.Action = ActionUnknown
+			jar.Reason = fmt.Sprintf("%s で確認できません: %v", src.Name(), err)
+			jars = append(jars, jar)
+			continue
+		}
+
+		jar.Action = ActionIncompatible
+		jar.Reason = fmt.Sprintf("Minecraft %s (%s) に対応するバージョンが %s にありません", mcVersion, loader, src.Name())
+		for _, r := range releases {
+			if r.Version == e.Version && r.Compatible(mcVersion, loader) {
+				jar.Action, jar.Reason = ActionKeep, ""
+				break
+			}
+		}
+		if jar.Action != ActionKeep {
+			// releases は新しい順なので、最初に見つかったものが対応する最新のリリース
+			for _, r := range releases {
+				if r.Compatible(mcVersion, loader) {
+					jar.Action, jar.Reason, jar.Release = ActionUpdate, "", r
+					break
+				}
+			}
+		}
+		jars = append(jars, jar)
+	}
+	return jars
+}
+
+// Record は、mcctl upgrade --rollback で元に戻すための記録です。
+type Record struct {
+	Server string `json:"server"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	// Backup はアップグレードの前に作ったバックアップのアーカイブです。
+	Backup string `json:"backup"`
+	// Environment は、変更する前のサービスの環境変数です。空の値は設定されていなかったことを表します。
+	Environment map[string]string `json:"environment"`
+	CreatedAt   time.Time         `json:"createdAt"`
+}
+
+// RecordPath は、サーバーの最後のアップグレードの記録のパスです。
+func RecordPath(backupDir string) string {
+	return filepath.Join(backupDir, RecordFileName)
+}
+
+// SaveRecord は、backupDir にアップグレードの記録を書き出します。
+func SaveRecord(backupDir string, r Record) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("アップグレードの記録のエンコードに失敗しました: %w", err)
+	}
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(RecordPath(backupDir), append(data, '\n'), 0644)
+}
+
+// LoadRecord は、backupDir のアップグレードの記録を読み込みます。記録がない場合は false を返します。
+func LoadRecord(backupDir string) (Record, bool, error) {
+	data, err := os.ReadFile(RecordPath(backupDir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Record{}, false, nil
+		}
+		return Record{}, false, fmt.Errorf("アップグレードの記録の読み込みに失敗しました: %w", err)
+	}
+	var r Record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Record{}, false, fmt.Errorf("%s のパースに失敗しました: %w", RecordPath(backupDir), err)
+	}
+	return r, true, nil
+}
+
+// RemoveRecord は、backupDir のアップグレードの記録を削除します。
+func RemoveRecord(backupDir string) error {
+	if err := os.Remove(RecordPath(backupDir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
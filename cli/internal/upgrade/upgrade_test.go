@@ -0,0 +1,54 @@
+package upgrade
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mcctl/internal/mods"
+)
+
+func TestCheckJarsSkipsUnverifiableSources(t *testing.T) {
+	src := &mods.LocalSource{Dir: t.TempDir()}
+	for _, r := range []mods.Release{
+		{ID: "jei", Version: "1.0.0", GameVersions: []string{"1.20.1"}},
+		{ID: "jei", Version: "2.0.0", GameVersions: []string{"1.21"}},
+	} {
+		jar := filepath.Join(t.TempDir(), "jei-"+r.Version+".jar")
+		os.WriteFile(jar, []byte(r.Version), 0644)
+		if _, err := src.Add(jar, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source := func(name string) (mods.Source, error) {
+		if name == "local" || name == "" {
+			return src, nil
+		}
+		return nil, fmt.Errorf("未対応のソースです: %s", name)
+	}
+
+	entries := []mods.Entry{
+		{ID: "jei", Version: "1.0.0", Source: "local"},
+		{ID: "lithium", Filename: "lithium.jar", Source: "mrpack"},
+		{ID: "238222", Filename: "create.jar", Source: "curseforge"},
+	}
+	jars := CheckJars("mod", source, entries, "1.21", "fabric")
+	want := []Action{ActionUpdate, ActionSkipped, ActionSkipped}
+	for i, j := range jars {
+		if j.ID != entries[i].ID || j.Action != want[i] {
+			t.Errorf("jars[%d] = %s %s, want %s %s", i, j.ID, j.Action, entries[i].ID, want[i])
+		}
+	}
+	if jars[0].Release.Version != "2.0.0" {
+		t.Errorf("jei の入れ替え先 = %s, want 2.0.0", jars[0].Release.Version)
+	}
+
+	plan := &Plan{From: "1.20.1", To: "1.21", Jars: jars}
+	if blockers := plan.Blockers(); len(blockers) != 0 {
+		t.Errorf("Blockers() = %v, want none", blockers)
+	}
+	if skipped := plan.Skipped(); len(skipped) != 2 {
+		t.Errorf("Skipped() = %v, want 2 jars", skipped)
+	}
+}
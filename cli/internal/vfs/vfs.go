@@ -0,0 +1,452 @@
+// Package vfs は mcctl が管理するファイルの読み書きを、ディスクまたは記録用のオーバーレイに振り分けます。
+//
+// 記録中 (Record) は書き込みをメモリに溜め、読み込みは溜めた内容をディスクより優先して返します。
+// 溜めた変更は Changes で確認し、Commit でディスクに書き出すか Discard で捨てます。
+// plan や --dry-run は、実際の処理を記録中に実行して差分を表示します。
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kind は変更の種類です。
+type Kind string
+
+const (
+	Create Kind = "create"
+	Modify Kind = "modify"
+	Delete Kind = "delete"
+	Mkdir  Kind = "mkdir"
+	Move   Kind = "move"
+)
+
+// Change は記録した1つの変更です。Path はカレントディレクトリからの相対パスです。
+// To は Move の移動先です。
+type Change struct {
+	Path string
+	Kind Kind
+	Old  []byte
+	New  []byte
+	To   string
+}
+
+type entry struct {
+	data    []byte
+	perm    fs.FileMode
+	dir     bool
+	removed bool
+}
+
+// pendingMove は記録した Rename です。
+type pendingMove struct {
+	from, to string
+}
+
+var (
+	mu        sync.Mutex
+	recording bool
+	overlay   map[string]*entry
+	moves     []pendingMove
+)
+
+// Record は書き込みの記録を始めます。すでに記録中の場合は何もしません。
+func Record() {
+	mu.Lock()
+	defer mu.Unlock()
+	if !recording {
+		recording = true
+		overlay = make(map[string]*entry)
+		moves = nil
+	}
+}
+
+// Recording reports whether writes are being recorded instead of applied.
+func Recording() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return recording
+}
+
+// Discard は記録した変更を捨て、記録を終えます。
+func Discard() {
+	mu.Lock()
+	defer mu.Unlock()
+	recording = false
+	overlay = nil
+	moves = nil
+}
+
+// Commit は記録した変更をディスクに書き出し、記録を終えます。
+// ディレクトリの作成、ファイルの書き込み、移動、削除の順に行い、内容の変わらないファイルは書き込みません。
+//
+// 書き込むファイルはすべて一時ファイルに書いてから最後にまとめてリネームし、削除するものも退避してから消します。
+// 途中で失敗した場合は、それまでの変更を元に戻してエラーを返します (記録は残ります)。
+func Commit() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !recording {
+		return nil
+	}
+	if err := commit(sortedPaths()); err != nil {
+		return err
+	}
+	recording = false
+	overlay = nil
+	moves = nil
+	return nil
+}
+
+// commit writes the overlay to disk, undoing everything it did if any step fails.
+func commit(paths []string) (err error) {
+	// rollback は失敗したときに逆順に実行し、cleanup は成功したときに実行する
+	var rollback, cleanup []func()
+	defer func() {
+		if err != nil {
+			for i := len(rollback) - 1; i >= 0; i-- {
+				rollback[i]()
+			}
+			return
+		}
+		for _, f := range cleanup {
+			f()
+		}
+	}()
+
+	for _, p := range paths {
+		e := overlay[p]
+		if !e.dir || e.removed {
+			continue
+		}
+		if _, err := os.Stat(p); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(p, e.perm); err != nil {
+			return err
+		}
+		dir := p
+		rollback = append(rollback, func() { os.Remove(dir) })
+	}
+
+	type pending struct{ path, tmp string }
+	var writes []pending
+	for _, p := range paths {
+		e := overlay[p]
+		if e.dir || e.removed {
+			continue
+		}
+		if old, err := os.ReadFile(p); err == nil && bytes.Equal(old, e.data) {
+			continue
+		}
+		tmp, err := writeTemp(p, e.data, e.perm)
+		if err != nil {
+			return err
+		}
+		rollback = append(rollback, func() { os.Remove(tmp) })
+		writes = append(writes, pending{path: p, tmp: tmp})
+	}
+	for _, w := range writes {
+		path := w.path
+		old, readErr := os.ReadFile(path)
+		info, statErr := os.Stat(path)
+		if err := os.Rename(w.tmp, path); err != nil {
+			return err
+		}
+		if readErr == nil && statErr == nil {
+			mode := info.Mode().Perm()
+			rollback = append(rollback, func() {
+				os.WriteFile(path, old, mode)
+				os.Chmod(path, mode)
+			})
+		} else {
+			rollback = append(rollback, func() { os.Remove(path) })
+		}
+	}
+
+	for _, m := range moves {
+		from, to := m.from, m.to
+		if _, err := os.Stat(to); err == nil {
+			return &fs.PathError{Op: "rename", Path: to, Err: fs.ErrExist}
+		}
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+		rollback = append(rollback, func() { os.Rename(to, from) })
+	}
+
+	// 中身から先に退避されるよう、深いパスから処理する。
+	// 退避したものは、すべて成功してから削除する。
+	for i := len(paths) - 1; i >= 0; i-- {
+		path := paths[i]
+		if !overlay[path].removed {
+			continue
+		}
+		if entries, err := os.ReadDir(path); err == nil && len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: path, Err: errors.New("directory not empty")}
+		}
+		backup := fmt.Sprintf("%s.mcctl-removed-%d", path, os.Getpid())
+		if err := os.Rename(path, backup); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		rollback = append(rollback, func() { os.Rename(backup, path) })
+		cleanup = append(cleanup, func() { os.RemoveAll(backup) })
+	}
+	return nil
+}
+
+// writeTemp は、name と同じディレクトリの一時ファイルに data を書き込み、そのパスを返します。
+func writeTemp(name string, data []byte, perm fs.FileMode) (string, error) {
+	// 既存のファイルの権限は変えない (os.WriteFile と同じ)
+	if info, err := os.Stat(name); err == nil {
+		perm = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp-")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// Changes は記録した変更をパス順に返します。ディスクと同じ内容の書き込みは含みません。
+func Changes() []Change {
+	mu.Lock()
+	defer mu.Unlock()
+	var changes []Change
+	for _, p := range sortedPaths() {
+		e := overlay[p]
+		c := Change{Path: display(p)}
+		info, statErr := os.Stat(p)
+		exists := statErr == nil
+		switch {
+		case e.removed:
+			if !exists {
+				continue
+			}
+			c.Kind = Delete
+			if !info.IsDir() {
+				c.Old, _ = os.ReadFile(p)
+			}
+		case e.dir:
+			if exists {
+				continue
+			}
+			c.Kind = Mkdir
+		default:
+			c.New = e.data
+			if !exists {
+				c.Kind = Create
+				break
+			}
+			old, err := os.ReadFile(p)
+			if err == nil && bytes.Equal(old, e.data) {
+				continue
+			}
+			c.Kind = Modify
+			c.Old = old
+		}
+		changes = append(changes, c)
+	}
+	for _, m := range moves {
+		changes = append(changes, Change{Path: display(m.from), Kind: Move, To: display(m.to)})
+	}
+	return changes
+}
+
+// ReadFile は os.ReadFile と同じですが、記録中は記録した内容を優先します。
+func ReadFile(name string) ([]byte, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if e := lookup(name); e != nil {
+		switch {
+		case e.removed:
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		case e.dir:
+			return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+		}
+		return append([]byte(nil), e.data...), nil
+	}
+	return os.ReadFile(name)
+}
+
+// WriteFile は os.WriteFile と同じですが、記録中はディスクに書き込みません。
+func WriteFile(name string, data []byte, perm fs.FileMode) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !recording {
+		return os.WriteFile(name, data, perm)
+	}
+	if !isDir(filepath.Dir(name)) {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if info, err := stat(name); err == nil && info.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+	overlay[key(name)] = &entry{data: append([]byte(nil), data...), perm: perm}
+	return nil
+}
+
+// MkdirAll は os.MkdirAll と同じですが、記録中はディスクに作成しません。
+func MkdirAll(path string, perm fs.FileMode) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !recording {
+		return os.MkdirAll(path, perm)
+	}
+	var missing []string
+	for p := key(path); ; p = filepath.Dir(p) {
+		info, err := stat(p)
+		if err == nil {
+			if !info.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: p, Err: errors.New("not a directory")}
+			}
+			break
+		}
+		missing = append(missing, p)
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	for _, p := range missing {
+		overlay[p] = &entry{dir: true, perm: perm}
+	}
+	return nil
+}
+
+// Remove は os.Remove と同じですが、記録中はディスクから削除しません。
+func Remove(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !recording {
+		return os.Remove(name)
+	}
+	if _, err := stat(name); err != nil {
+		return err
+	}
+	overlay[key(name)] = &entry{removed: true}
+	return nil
+}
+
+// Rename は os.Rename と同じですが、記録中はディスク上で移動せず、Commit のときに移動します。
+// 移動先がすでにある場合はエラーを返します。
+// 記録中の ReadFile や Stat は移動を反映しないため、移動したものは Commit まで元のパスで扱います。
+func Rename(from, to string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !recording {
+		return os.Rename(from, to)
+	}
+	if _, err := stat(from); err != nil {
+		return &fs.PathError{Op: "rename", Path: from, Err: fs.ErrNotExist}
+	}
+	if _, err := stat(to); err == nil {
+		return &fs.PathError{Op: "rename", Path: to, Err: fs.ErrExist}
+	}
+	moves = append(moves, pendingMove{from: key(from), to: key(to)})
+	return nil
+}
+
+// Stat は os.Stat と同じですが、記録中は記録した内容を優先します。
+func Stat(name string) (fs.FileInfo, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return stat(name)
+}
+
+func stat(name string) (fs.FileInfo, error) {
+	if e := lookup(name); e != nil {
+		if e.removed {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+		}
+		return fileInfo{name: filepath.Base(name), entry: e}, nil
+	}
+	return os.Stat(name)
+}
+
+func isDir(name string) bool {
+	info, err := stat(name)
+	return err == nil && info.IsDir()
+}
+
+func lookup(name string) *entry {
+	if !recording {
+		return nil
+	}
+	return overlay[key(name)]
+}
+
+func key(name string) string {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return filepath.Clean(name)
+	}
+	return abs
+}
+
+func display(abs string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return abs
+	}
+	if rel, err := filepath.Rel(wd, abs); err == nil {
+		return rel
+	}
+	return abs
+}
+
+func sortedPaths() []string {
+	paths := make([]string, 0, len(overlay))
+	for p := range overlay {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+type fileInfo struct {
+	name  string
+	entry *entry
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return int64(len(fi.entry.data)) }
+func (fi fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fileInfo) IsDir() bool        { return fi.entry.dir }
+func (fi fileInfo) Sys() interface{}   { return nil }
+
+func (fi fileInfo) Mode() fs.FileMode {
+	if fi.entry.dir {
+		return fs.ModeDir | fi.entry.perm
+	}
+	return fi.entry.perm
+}
+
+// String は変更を "create minecraft/servers/foo/server.properties" のように表します。
+func (c Change) String() string {
+	if c.Kind == Move {
+		return fmt.Sprintf("%s %s -> %s", c.Kind, c.Path, c.To)
+	}
+	return fmt.Sprintf("%s %s", c.Kind, c.Path)
+}
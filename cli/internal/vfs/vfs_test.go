@@ -0,0 +1,146 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommit(t *testing.T) {
+	dir := t.TempDir()
+	a, b, gone := filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub", "b.txt"), filepath.Join(dir, "gone.txt")
+	os.WriteFile(a, []byte("old"), 0600)
+	os.WriteFile(gone, []byte("gone"), 0644)
+
+	Record()
+	WriteFile(a, []byte("new"), 0644)
+	MkdirAll(filepath.Dir(b), 0755)
+	WriteFile(b, []byte("b"), 0644)
+	Remove(gone)
+	if err := Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if Recording() {
+		t.Error("Commit の後も記録中です")
+	}
+
+	if data, _ := os.ReadFile(a); string(data) != "new" {
+		t.Errorf("a.txt = %q", data)
+	}
+	if info, _ := os.Stat(a); info.Mode().Perm() != 0600 {
+		t.Errorf("a.txt の権限が %v に変わりました", info.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(b); string(data) != "b" {
+		t.Errorf("sub/b.txt = %q", data)
+	}
+	if _, err := os.Stat(gone); !os.IsNotExist(err) {
+		t.Error("gone.txt が削除されていません")
+	}
+	assertNoLeftovers(t, dir)
+}
+
+func TestCommitRollsBackOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c, gone := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt"), filepath.Join(dir, "new", "c.txt"), filepath.Join(dir, "gone.txt")
+	os.WriteFile(a, []byte("old"), 0644)
+	os.WriteFile(gone, []byte("gone"), 0644)
+
+	Record()
+	defer Discard()
+	WriteFile(a, []byte("new"), 0644)
+	WriteFile(b, []byte("b"), 0644)
+	MkdirAll(filepath.Dir(c), 0755)
+	WriteFile(c, []byte("c"), 0644)
+	Remove(gone)
+
+	// 記録した後に b.txt がディレクトリになり、リネームできなくなった
+	os.MkdirAll(filepath.Join(b, "child"), 0755)
+
+	if err := Commit(); err == nil {
+		t.Fatal("Commit() が成功しました")
+	}
+	if data, _ := os.ReadFile(a); string(data) != "old" {
+		t.Errorf("a.txt が元に戻っていません: %q", data)
+	}
+	if _, err := os.Stat(filepath.Dir(c)); !os.IsNotExist(err) {
+		t.Error("作成したディレクトリが残っています")
+	}
+	if data, _ := os.ReadFile(gone); string(data) != "gone" {
+		t.Errorf("gone.txt が元に戻っていません: %q", data)
+	}
+	assertNoLeftovers(t, dir)
+}
+
+func assertNoLeftovers(t *testing.T, dir string) {
+	t.Helper()
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") || strings.Contains(e.Name(), ".mcctl-removed-") {
+			t.Errorf("一時ファイルが残っています: %s", e.Name())
+		}
+	}
+}
+
+func TestRename(t *testing.T) {
+	dir := t.TempDir()
+	from, to := filepath.Join(dir, "large"), filepath.Join(dir, "large-paper")
+	os.MkdirAll(filepath.Join(from, "world"), 0755)
+	os.WriteFile(filepath.Join(from, "server.properties"), []byte("motd=large"), 0644)
+	os.MkdirAll(filepath.Join(dir, "taken"), 0755)
+
+	Record()
+	if err := Rename(from, to); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rename(filepath.Join(dir, "missing"), filepath.Join(dir, "other")); err == nil {
+		t.Error("存在しないディレクトリの Rename() が成功しました")
+	}
+	if err := Rename(from, filepath.Join(dir, "taken")); err == nil {
+		t.Error("移動先がある Rename() が成功しました")
+	}
+
+	// 記録中はディスク上で移動しない
+	if _, err := os.Stat(to); !os.IsNotExist(err) {
+		t.Errorf("記録中に移動しました: %v", err)
+	}
+	changes := Changes()
+	if len(changes) != 1 || changes[0].Kind != Move || !strings.HasSuffix(changes[0].To, "large-paper") {
+		t.Fatalf("Changes() = %v", changes)
+	}
+
+	if err := Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(to, "server.properties")); string(data) != "motd=large" {
+		t.Errorf("移動先の server.properties = %q", data)
+	}
+	if _, err := os.Stat(from); !os.IsNotExist(err) {
+		t.Errorf("移動元が残っています: %v", err)
+	}
+}
+
+func TestRenameRollsBackOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	from, to, b := filepath.Join(dir, "large"), filepath.Join(dir, "large-paper"), filepath.Join(dir, "b.txt")
+	os.MkdirAll(from, 0755)
+
+	Record()
+	defer Discard()
+	WriteFile(b, []byte("b"), 0644)
+	Rename(from, to)
+
+	// 記録した後に移動先が作られた
+	os.MkdirAll(to, 0755)
+
+	if err := Commit(); err == nil {
+		t.Fatal("Commit() が成功しました")
+	}
+	if _, err := os.Stat(from); err != nil {
+		t.Errorf("移動元がありません: %v", err)
+	}
+	if _, err := os.Stat(b); !os.IsNotExist(err) {
+		t.Error("書き込んだ b.txt が元に戻っていません")
+	}
+	assertNoLeftovers(t, dir)
+}
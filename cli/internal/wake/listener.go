@@ -0,0 +1,179 @@
+// Package wake は、停止中のサーバーの代わりに接続を受け付け、
+// プレイヤーがログインしようとしたときにサーバーを起動する仕組みを実装します。
+package wake
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"mcctl/internal/mcproto"
+)
+
+// connTimeout は、1つの接続の処理にかける最大時間です。
+const connTimeout = 10 * time.Second
+
+// Listener は、Minecraft のハンドシェイクとステータスプロトコルを話す最小限のサーバーです。
+// ステータス要求には MOTD を返し、ログイン要求では OnLogin を呼んでから切断メッセージを返します。
+type Listener struct {
+	// MOTD returns the description shown in the client's server list.
+	MOTD func() string
+	// DisconnectMessage returns the reason shown to a player who tried to log in.
+	DisconnectMessage func() string
+	// OnLogin is called (in its own goroutine) when a player tries to log in.
+	OnLogin func(player string)
+
+	ln     net.Listener
+	wg     sync.WaitGroup
+	closed chan struct{}
+}
+
+// Listen starts accepting connections on address.
+func (l *Listener) Listen(address string) error {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	l.ln = ln
+	l.closed = make(chan struct{})
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				select {
+				case <-l.closed:
+					return
+				default:
+				}
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() {
+					continue
+				}
+				return
+			}
+			l.wg.Add(1)
+			go func() {
+				defer l.wg.Done()
+				l.handle(conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the listener is bound to.
+func (l *Listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// Close はリスナーを閉じ、処理中の接続が終わるのを待ちます。
+func (l *Listener) Close() error {
+	close(l.closed)
+	err := l.ln.Close()
+	l.wg.Wait()
+	return err
+}
+
+func (l *Listener) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(connTimeout))
+	r := bufio.NewReader(conn)
+
+	// 1.6 以前のクライアントは 0xFE で始まるレガシーピングを送ってくる。
+	// 対応はしないが、不正なパケットとして扱わずに静かに切断する。
+	if first, err := r.Peek(1); err != nil || first[0] == 0xFE {
+		return
+	}
+
+	id, payload, err := mcproto.ReadPacket(r)
+	if err != nil || id != 0x00 {
+		return
+	}
+	handshake, err := mcproto.DecodeHandshake(payload)
+	if err != nil {
+		return
+	}
+
+	switch handshake.NextState {
+	case mcproto.StateStatus:
+		l.handleStatus(conn, r, handshake.ProtocolVersion)
+	case mcproto.StateLogin:
+		l.handleLogin(conn, r)
+	}
+}
+
+type statusResponse struct {
+	Version struct {
+		Name     string `json:"name"`
+		Protocol int32  `json:"protocol"`
+	} `json:"version"`
+	Players struct {
+		Max    int `json:"max"`
+		Online int `json:"online"`
+	} `json:"players"`
+	Description chat `json:"description"`
+}
+
+type chat struct {
+	Text  string `json:"text"`
+	Color string `json:"color,omitempty"`
+}
+
+func (l *Listener) handleStatus(conn net.Conn, r *bufio.Reader, protocol int32) {
+	for {
+		id, payload, err := mcproto.ReadPacket(r)
+		if err != nil {
+			return
+		}
+		switch id {
+		case 0x00: // Status Request
+			var status statusResponse
+			status.Version.Name = "Sleeping"
+			// クライアントと同じプロトコル番号を返し、「バージョンが違う」表示を避ける
+			status.Version.Protocol = protocol
+			status.Description = chat{Text: l.MOTD(), Color: "gray"}
+			data, err := json.Marshal(status)
+			if err != nil {
+				return
+			}
+			if err := mcproto.WritePacket(conn, 0x00, mcproto.AppendString(nil, string(data))); err != nil {
+				return
+			}
+		case 0x01: // Ping Request
+			var token [8]byte
+			if _, err := io.ReadFull(payload, token[:]); err != nil {
+				return
+			}
+			mcproto.WritePacket(conn, 0x01, token[:])
+			return
+		default:
+			return
+		}
+	}
+}
+
+func (l *Listener) handleLogin(conn net.Conn, r *bufio.Reader) {
+	id, payload, err := mcproto.ReadPacket(r)
+	if err != nil || id != 0x00 {
+		return
+	}
+	player, _ := mcproto.ReadString(payload)
+
+	if l.OnLogin != nil {
+		go l.OnLogin(player)
+	}
+
+	reason, err := json.Marshal(chat{Text: l.DisconnectMessage(), Color: "yellow"})
+	if err != nil {
+		return
+	}
+	// Login Disconnect (0x00)
+	mcproto.WritePacket(conn, 0x00, mcproto.AppendString(nil, string(reason)))
+}
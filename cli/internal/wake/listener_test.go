@@ -0,0 +1,135 @@
+package wake
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"mcctl/internal/mcproto"
+)
+
+// dial は l.handle に net.Pipe の片側を渡し、もう片側をクライアントとして返します。
+func dial(t *testing.T, l *Listener) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	client, conn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.handle(conn)
+	}()
+	t.Cleanup(func() {
+		client.Close()
+		<-done
+	})
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	return client, bufio.NewReader(client)
+}
+
+func handshake(t *testing.T, w io.Writer, nextState int32) {
+	t.Helper()
+	h := mcproto.Handshake{ProtocolVersion: 765, ServerAddress: "large.example.com", ServerPort: 25565, NextState: nextState}
+	if err := mcproto.WritePacket(w, 0x00, h.Encode()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListenerStatus(t *testing.T) {
+	l := &Listener{MOTD: func() string { return "large はスリープ中です" }}
+	client, r := dial(t, l)
+
+	handshake(t, client, mcproto.StateStatus)
+	if err := mcproto.WritePacket(client, 0x00, nil); err != nil {
+		t.Fatal(err)
+	}
+	id, payload, err := mcproto.ReadPacket(r)
+	if err != nil || id != 0x00 {
+		t.Fatalf("status response: id=%#x, %v", id, err)
+	}
+	data, err := mcproto.ReadString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status statusResponse
+	if err := json.Unmarshal([]byte(data), &status); err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+	if status.Version.Protocol != 765 || status.Description.Text != "large はスリープ中です" {
+		t.Errorf("status = %+v", status)
+	}
+
+	token := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	if err := mcproto.WritePacket(client, 0x01, token); err != nil {
+		t.Fatal(err)
+	}
+	id, payload, err = mcproto.ReadPacket(r)
+	if err != nil || id != 0x01 {
+		t.Fatalf("pong: id=%#x, %v", id, err)
+	}
+	if got, _ := io.ReadAll(payload); string(got) != string(token) {
+		t.Errorf("pong = %v, want %v", got, token)
+	}
+}
+
+func TestListenerLogin(t *testing.T) {
+	players := make(chan string, 1)
+	l := &Listener{
+		DisconnectMessage: func() string { return "起動しています" },
+		OnLogin:           func(player string) { players <- player },
+	}
+	client, r := dial(t, l)
+
+	handshake(t, client, mcproto.StateLogin)
+	// Login Start: 名前のあとに UUID が続く (1.20.2 以降)
+	start := append(mcproto.AppendString(nil, "Steve"), make([]byte, 16)...)
+	if err := mcproto.WritePacket(client, 0x00, start); err != nil {
+		t.Fatal(err)
+	}
+	id, payload, err := mcproto.ReadPacket(r)
+	if err != nil || id != 0x00 {
+		t.Fatalf("disconnect: id=%#x, %v", id, err)
+	}
+	data, _ := mcproto.ReadString(payload)
+	var reason chat
+	if err := json.Unmarshal([]byte(data), &reason); err != nil || reason.Text != "起動しています" {
+		t.Errorf("reason = %s, %v", data, err)
+	}
+
+	select {
+	case player := <-players:
+		if player != "Steve" {
+			t.Errorf("OnLogin(%q), want Steve", player)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnLogin が呼ばれませんでした")
+	}
+}
+
+func TestListenerClosesUnsupportedConnections(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w io.Writer)
+	}{
+		{"legacy ping", func(w io.Writer) { w.Write([]byte{0xFE, 0x01}) }},
+		{"not a handshake", func(w io.Writer) { mcproto.WritePacket(w, 0x05, nil) }},
+		{"unknown next state", func(w io.Writer) {
+			mcproto.WritePacket(w, 0x00, mcproto.Handshake{ProtocolVersion: 765, NextState: 3}.Encode())
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Listener{
+				MOTD:              func() string { return "" },
+				DisconnectMessage: func() string { return "" },
+				OnLogin:           func(string) { t.Error("OnLogin が呼ばれました") },
+			}
+			client, r := dial(t, l)
+			go tt.write(client)
+			if _, err := r.ReadByte(); err == nil {
+				t.Error("応答が返されました")
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package wake
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"mcctl/internal/docker"
+	"mcctl/internal/rcon"
+	"mcctl/internal/server"
+)
+
+const (
+	// checkInterval はコンテナの状態とプレイヤー数を確認する間隔です。
+	checkInterval = time.Minute
+	rconTimeout   = 5 * time.Second
+	// startupTimeout は、起動してから RCON が応答するまで待つ時間です。
+	// これを過ぎると起動中の状態を解除し、次のログインで起動し直せるようにします。
+	startupTimeout = 5 * time.Minute
+)
+
+// Config は、スリープさせるサーバーの設定です (スケジュールファイルの sleep セクション)。
+type Config struct {
+	Server string `yaml:"server"`
+	// Listen は、サーバーが停止している間に代わりに接続を受け付けるアドレスです (例: ":25600")。
+	Listen string `yaml:"listen"`
+	// ProxyAddress は、Velocity から Listen に接続するアドレスです (例: "host.docker.internal:25600")。
+	// 指定すると、デーモンは velocity.toml に ProxyServerName() のサーバーとして登録し、
+	// forced-hosts と try でこのサーバーの次に接続するようにします。
+	ProxyAddress string `yaml:"proxyAddress,omitempty"`
+	// IdleMinutes は、プレイヤーが0人の状態がこの分数続いたらサーバーを停止します。
+	// 0 の場合は自動停止せず、停止中のサーバーを起こすだけです。
+	IdleMinutes int `yaml:"idleMinutes,omitempty"`
+	// MOTD は、スリープ中にサーバーリストに表示するメッセージです。
+	MOTD string `yaml:"motd,omitempty"`
+}
+
+// ProxyServerName は、Velocity に代理のリスナーを登録するときのサーバー名です。
+func (c Config) ProxyServerName() string {
+	return c.Server + "-sleeping"
+}
+
+// Sleeper は1台のサーバーについて、アイドル時の停止とログイン時の起動を管理します。
+type Sleeper struct {
+	Config      Config
+	Compose     docker.Compose
+	ServersPath string
+	Log         io.Writer
+
+	mu        sync.Mutex
+	listener  *Listener
+	starting  bool
+	startedAt time.Time
+	idleSince time.Time
+	// rconFailing は、起動中でないのにプレイヤー数を取得できなかったことを記録し、同じエラーを毎回表示しないようにします。
+	rconFailing bool
+}
+
+// Run は ctx がキャンセルされるまでサーバーを監視します。
+func (s *Sleeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	defer s.closeListener()
+
+	for {
+		s.check()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Sleeper) check() {
+	s.mu.Lock()
+	timedOut := s.starting && time.Since(s.startedAt) >= startupTimeout
+	if timedOut {
+		s.starting = false
+	}
+	s.mu.Unlock()
+	if timedOut {
+		s.logf("起動してから%d分経っても応答がないため、起動中の状態を解除します\n", int(startupTimeout/time.Minute))
+	}
+
+	running, err := s.Compose.IsRunning(s.Config.Server)
+	if err != nil {
+		s.logf("コンテナの状態を取得できませんでした: %v\n", err)
+		return
+	}
+
+	if !running {
+		s.mu.Lock()
+		starting := s.starting
+		s.mu.Unlock()
+		if !starting {
+			s.openListener()
+		}
+		return
+	}
+
+	players, err := s.playerCount()
+	if err != nil {
+		s.idleSince = time.Time{}
+		s.mu.Lock()
+		starting := s.starting
+		s.mu.Unlock()
+		// 起動中でまだ応答しないのは正常。それ以外は RCON の設定の誤りなどで、アイドル時に停止できない
+		if !starting && !s.rconFailing {
+			s.logf("エラー: プレイヤー数を取得できないため、アイドル時に停止できません (RCON の設定を確認してください): %v\n", err)
+		}
+		s.rconFailing = !starting
+		return
+	}
+	if s.rconFailing {
+		s.logf("プレイヤー数を取得できるようになりました\n")
+		s.rconFailing = false
+	}
+
+	// サーバーが応答するようになったので、代理のリスナーは不要
+	s.mu.Lock()
+	s.starting = false
+	s.mu.Unlock()
+	s.closeListener()
+
+	if players > 0 || s.Config.IdleMinutes <= 0 {
+		s.idleSince = time.Time{}
+		return
+	}
+	if s.idleSince.IsZero() {
+		s.idleSince = time.Now()
+		return
+	}
+	if idle := time.Since(s.idleSince); idle >= time.Duration(s.Config.IdleMinutes)*time.Minute {
+		s.logf("%d分間プレイヤーがいないため停止します\n", s.Config.IdleMinutes)
+		s.command("save-all")
+		if err := s.Compose.Stop(s.Config.Server); err != nil {
+			s.logf("停止に失敗しました: %v\n", err)
+			return
+		}
+		s.idleSince = time.Time{}
+		s.openListener()
+	}
+}
+
+func (s *Sleeper) openListener() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return
+	}
+
+	l := &Listener{
+		MOTD: func() string {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.starting {
+				return fmt.Sprintf("%s は起動中です…", s.Config.Server)
+			}
+			if s.Config.MOTD != "" {
+				return s.Config.MOTD
+			}
+			return fmt.Sprintf("%s はスリープ中です。ログインすると起動します", s.Config.Server)
+		},
+		DisconnectMessage: func() string {
+			return "サーバーを起動しています。30秒ほど待ってから再接続してください"
+		},
+		OnLogin: s.wake,
+	}
+	if err := l.Listen(s.Config.Listen); err != nil {
+		s.logf("%s で待ち受けできませんでした: %v\n", s.Config.Listen, err)
+		return
+	}
+	s.listener = l
+	s.logf("スリープ中です。%s で接続を待ち受けます\n", s.Config.Listen)
+}
+
+func (s *Sleeper) closeListener() {
+	s.mu.Lock()
+	l := s.listener
+	s.listener = nil
+	s.mu.Unlock()
+	if l != nil {
+		l.Close()
+	}
+}
+
+// wake はログインしようとしたプレイヤーがいたときにコンテナを起動します。
+func (s *Sleeper) wake(player string) {
+	s.mu.Lock()
+	if s.starting {
+		s.mu.Unlock()
+		return
+	}
+	s.starting = true
+	s.startedAt = time.Now()
+	s.mu.Unlock()
+
+	s.logf("%s がログインしようとしたため起動します\n", player)
+	if err := s.Compose.Start(s.Config.Server); err != nil {
+		s.logf("起動に失敗しました: %v\n", err)
+		s.mu.Lock()
+		s.starting = false
+		s.mu.Unlock()
+	}
+}
+
+func (s *Sleeper) command(command string) (string, error) {
+	address, password, err := server.RCONTarget(s.ServersPath, s.Config.Server)
+	if err != nil {
+		return "", err
+	}
+	client, err := rcon.Dial(address, password, rconTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	return client.Command(command)
+}
+
+var (
+	formatCode = regexp.MustCompile(`§.`)
+	// playerCountPatterns は `list` の出力の形式です。最初のグループがオンラインのプレイヤー数です。
+	playerCountPatterns = []*regexp.Regexp{
+		// Vanilla, Paper: "There are 3 of a max of 20 players online: a, b, c"
+		regexp.MustCompile(`There are (\d+) of a max(?:imum)? of \d+ players online`),
+		// 古い CraftBukkit: "There are 3/20 players online:"
+		regexp.MustCompile(`There are (\d+)/\d+ players online`),
+		// EssentialsX: "There are 3 out of maximum 20 players online."
+		regexp.MustCompile(`There are (\d+) out of maximum \d+ players online`),
+	}
+)
+
+// playerCount は `list` コマンドの出力からオンラインのプレイヤー数を取得します。
+func (s *Sleeper) playerCount() (int, error) {
+	out, err := s.command("list")
+	if err != nil {
+		return 0, err
+	}
+	return parsePlayerCount(out)
+}
+
+// parsePlayerCount は、色コードを除いた `list` の出力からオンラインのプレイヤー数を取り出します。
+// プレイヤー名に数字が含まれていても、最大人数と取り違えないよう、形式ごとに決まった位置の数を使います。
+func parsePlayerCount(out string) (int, error) {
+	plain := formatCode.ReplaceAllString(out, "")
+	for _, pattern := range playerCountPatterns {
+		if m := pattern.FindStringSubmatch(plain); m != nil {
+			return strconv.Atoi(m[1])
+		}
+	}
+	return 0, fmt.Errorf("list の出力を解釈できません: %q", out)
+}
+
+func (s *Sleeper) logf(format string, args ...interface{}) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, "[sleep:%s] "+format, append([]interface{}{s.Config.Server}, args...)...)
+	}
+}
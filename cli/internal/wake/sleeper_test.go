@@ -0,0 +1,31 @@
+package wake
+
+import "testing"
+
+func TestParsePlayerCount(t *testing.T) {
+	tests := []struct {
+		out  string
+		want int
+	}{
+		{"There are 0 of a max of 20 players online: ", 0},
+		{"There are 3 of a max of 20 players online: Steve, Alex, Player1", 3},
+		// プレイヤー名の数字や最大人数を取り違えない
+		{"There are 1 of a max of 100 players online: 2b2t_fan", 1},
+		{"There are 2 of a maximum of 50 players online: a, b", 2},
+		{"There are 4/20 players online:", 4},
+		{"§6There are §c5§6 out of maximum §c20§6 players online.", 5},
+		{"§6There are §c0§6 of a max of §c10§6 players online: ", 0},
+	}
+	for _, tt := range tests {
+		got, err := parsePlayerCount(tt.out)
+		if err != nil || got != tt.want {
+			t.Errorf("parsePlayerCount(%q) = %d, %v, want %d", tt.out, got, err, tt.want)
+		}
+	}
+
+	for _, out := range []string{"", "Unknown command", "[12:00:00 INFO]: 20 players", "There are 12 of a maximum 50 players online"} {
+		if _, err := parsePlayerCount(out); err == nil {
+			t.Errorf("parsePlayerCount(%q) にエラーがありません", out)
+		}
+	}
+}